@@ -0,0 +1,69 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nuln/dbase/internal/atomicfile"
+)
+
+// Backupper is implemented by drivers that can take a consistent snapshot
+// of a live database in its native file format.
+type Backupper interface {
+	// Backup writes a snapshot to w.
+	Backup(ctx context.Context, w io.Writer) error
+
+	// BackupTo writes a snapshot to the file at path, replacing it
+	// atomically.
+	BackupTo(ctx context.Context, path string) error
+}
+
+// Backup writes a snapshot of db to w. It returns [ErrNotSupported] if the
+// driver does not implement [Backupper].
+func Backup(ctx context.Context, db Database, w io.Writer) error {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return err
+	}
+	b, ok := db.(Backupper)
+	if !ok {
+		return fmt.Errorf("%w: %s cannot back up", ErrNotSupported, db.Driver())
+	}
+	return b.Backup(ctx, w)
+}
+
+// BackupTo writes a snapshot of db to the file at path. It returns
+// [ErrNotSupported] if the driver does not implement [Backupper].
+func BackupTo(ctx context.Context, db Database, path string) error {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return err
+	}
+	b, ok := db.(Backupper)
+	if !ok {
+		return fmt.Errorf("%w: %s cannot back up", ErrNotSupported, db.Driver())
+	}
+	return b.BackupTo(ctx, path)
+}
+
+// Restore replaces the database file at path with a snapshot read from r,
+// as written by [Backupper.Backup]. The database must not be open. The file
+// is replaced atomically and stale SQLite journal files next to it are
+// removed, so the restored file is opened as is.
+func Restore(r io.Reader, path string) error {
+	err := atomicfile.Write(path, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("dbase: restore %s: %w", path, err)
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		if err := os.Remove(path + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("dbase: restore %s: %w", path, err)
+		}
+	}
+	return nil
+}
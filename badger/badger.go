@@ -0,0 +1,696 @@
+// Package badger provides a [dbase.Database] implementation backed by
+// BadgerDB, an LSM-tree key-value store suited to write-heavy workloads.
+// Importing this package registers the "badger" driver.
+//
+//	import _ "github.com/nuln/dbase/badger"
+//
+// Models are stored as JSON under their struct type name, like the bolt
+// driver. Struct tags shared with Storm declare the primary key and
+// secondary indexes, so the same models work with both drivers:
+//
+//	type User struct {
+//	    ID    uint   `storm:"id,increment"`
+//	    Name  string `storm:"index"`
+//	    Email string `storm:"unique"`
+//	}
+package badger
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/record"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+func init() {
+	dbase.Register("badger", func(cfg *dbase.Config) (dbase.Database, error) {
+		if len(cfg.Replicas) > 0 {
+			return nil, fmt.Errorf("dbase/badger: replicas: %w", dbase.ErrNotSupported)
+		}
+		var opts Options
+		if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return Open(cfg.Path, opts)
+	}, dbase.WithDescription("BadgerDB directory, or :memory:"),
+		dbase.Requires("Path"), dbase.WithOptions(Options{}), dbase.WithCapabilities(capabilities))
+}
+
+// capabilities of the badger driver. Conditions are checked against the
+// decoded records, after index keys narrowed the candidates where they can.
+var capabilities = dbase.Capabilities{
+	Operators:          dbase.AllOperators,
+	Or:                 true,
+	PartialUpdate:      true,
+	AtomicTransactions: true,
+	NestedTransactions: dbase.NestedJoin,
+}
+
+// Options configures a Badger database. The driver reads them from
+// [dbase.Config.Options] by the keys in their option tags.
+type Options struct {
+	// ReadOnly opens an existing database without taking the write lock,
+	// so several processes can read it. Writes fail.
+	ReadOnly bool `option:"read_only"`
+
+	// SyncWrites syncs every write to disk before it returns instead of
+	// relying on the operating system to flush it.
+	SyncWrites bool `option:"sync_writes"`
+}
+
+// DB implements [dbase.Database] using BadgerDB.
+// Transaction-scoped instances share the root handle and carry the
+// active read-write transaction.
+type DB struct {
+	root *badger.DB
+	txn  *badger.Txn // nil outside of Transaction
+}
+
+// New opens (or creates) a Badger database in the directory at path with
+// default options. The special path ":memory:" opens an in-memory database.
+func New(path string) (*DB, error) {
+	return Open(path, Options{})
+}
+
+// Open is like [New] with the given options.
+func Open(path string, opts Options) (*DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("dbase/badger: path is required")
+	}
+
+	var bopts badger.Options
+	if path == ":memory:" {
+		bopts = badger.DefaultOptions("").WithInMemory(true)
+	} else {
+		bopts = badger.DefaultOptions(path)
+	}
+	bopts = bopts.WithReadOnly(opts.ReadOnly).WithSyncWrites(opts.SyncWrites)
+
+	bdb, err := badger.Open(bopts.WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("dbase/badger: open: %w", err)
+	}
+	return &DB{root: bdb}, nil
+}
+
+// FromBadger wraps an existing *badger.DB instance.
+func FromBadger(bdb *badger.DB) *DB {
+	return &DB{root: bdb}
+}
+
+// Badger returns the underlying *badger.DB for advanced operations.
+func (d *DB) Badger() *badger.DB { return d.root }
+
+// Driver implements [dbase.Database].
+func (d *DB) Driver() string { return "badger" }
+
+// Capabilities implements [dbase.Capable].
+func (d *DB) Capabilities() dbase.Capabilities { return capabilities }
+
+func (d *DB) Create(ctx context.Context, model any) error {
+	return record.Write(ctx, model, record.Create, d.write)
+}
+
+func (d *DB) Get(ctx context.Context, model any, id any) error {
+	m, v, err := record.Inspect(model)
+	if err != nil {
+		return err
+	}
+	idKey, err := encodeField(m.ID, id)
+	if err != nil {
+		return err
+	}
+	return d.view(func(txn *badger.Txn) error {
+		found, err := load(txn, dataKey(m.Name, idKey), v.Addr().Interface())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return dbase.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (d *DB) Update(ctx context.Context, model any) error {
+	return record.Write(ctx, model, record.Update, d.write)
+}
+
+// UpdateFields loads the stored record, copies only the named fields from
+// model into it and writes it back, keeping indexes in sync.
+func (d *DB) UpdateFields(ctx context.Context, model any, fields ...string) error {
+	if len(fields) == 0 {
+		return d.Update(ctx, model)
+	}
+	m, v, err := record.Inspect(model)
+	if err != nil {
+		return err
+	}
+	selected := make([]*schema.Field, 0, len(fields))
+	for _, name := range fields {
+		f := m.Field(name)
+		if f == nil {
+			return fmt.Errorf("dbase/badger: %s has no field %q", m.Name, name)
+		}
+		selected = append(selected, f)
+	}
+
+	if err := dbase.RunBeforeUpdateHooks(ctx, model); err != nil {
+		return err
+	}
+	err = d.update(func(txn *badger.Txn) error {
+		idKey, err := encodeValue(m.ID.Value(v))
+		if err != nil {
+			return err
+		}
+		current := m.New()
+		found, err := load(txn, dataKey(m.Name, idKey), current.Interface())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return dbase.ErrNotFound
+		}
+		for _, f := range selected {
+			f.Value(current).Set(f.Value(v))
+		}
+		if err := put(txn, m, current.Elem(), record.Update); err != nil {
+			return err
+		}
+		v.Set(current.Elem())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return dbase.RunAfterUpdateHooks(ctx, model)
+}
+
+func (d *DB) Save(ctx context.Context, model any) error {
+	return record.Write(ctx, model, record.Save, d.write)
+}
+
+func (d *DB) Delete(ctx context.Context, model any, id any) error {
+	m, _, err := record.Inspect(model)
+	if err != nil {
+		return err
+	}
+	idKey, err := encodeField(m.ID, id)
+	if err != nil {
+		return err
+	}
+	if err := dbase.RunBeforeDeleteHooks(ctx, model); err != nil {
+		return err
+	}
+	err = d.update(func(txn *badger.Txn) error {
+		current := m.New()
+		key := dataKey(m.Name, idKey)
+		found, err := load(txn, key, current.Interface())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return dbase.ErrNotFound
+		}
+		if err := dropIndexes(txn, m, current.Elem(), idKey); err != nil {
+			return err
+		}
+		return txn.Delete(key)
+	})
+	if err != nil {
+		return err
+	}
+	return dbase.RunAfterDeleteHooks(ctx, model)
+}
+
+func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
+	slice, isPtr, err := schema.Slice(results)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	m, err := schema.OfType(slice.Type())
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+
+	var items []reflect.Value
+	err = d.view(func(txn *badger.Txn) error {
+		items, err = selectRecords(txn, m, query)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	out := reflect.MakeSlice(slice.Type(), 0, len(items))
+	for _, item := range items {
+		if isPtr {
+			out = reflect.Append(out, item)
+		} else {
+			out = reflect.Append(out, item.Elem())
+		}
+	}
+	slice.Set(out)
+	return nil
+}
+
+func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error {
+	m, v, err := record.Inspect(result)
+	if err != nil {
+		return err
+	}
+	q := dbase.Query{}
+	if query != nil {
+		q = *query
+	}
+	q.Limit = 1
+
+	var items []reflect.Value
+	err = d.view(func(txn *badger.Txn) error {
+		items, err = selectRecords(txn, m, &q)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return dbase.ErrNotFound
+	}
+	v.Set(items[0].Elem())
+	return nil
+}
+
+func (d *DB) Count(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	m, err := schema.Of(model)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	q := dbase.Query{}
+	if query != nil {
+		q.Conditions, q.Fields, q.Unique = query.Conditions, query.Fields, query.Unique
+	}
+
+	var items []reflect.Value
+	err = d.view(func(txn *badger.Txn) error {
+		items, err = selectRecords(txn, m, &q)
+		return err
+	})
+	return int64(len(items)), err
+}
+
+func (d *DB) Exists(ctx context.Context, model any, query *dbase.Query) (bool, error) {
+	count, err := d.Count(ctx, model, query)
+	return count > 0, err
+}
+
+// Transaction runs fn inside a single read-write Badger transaction.
+// Badger transactions are serializable; a conflicting concurrent write
+// makes the commit fail with [dbase.ErrTxFailed]. Nested calls join the
+// enclosing transaction.
+func (d *DB) Transaction(ctx context.Context, fn func(tx dbase.Database) error) error {
+	if d.txn != nil {
+		return fn(d)
+	}
+	err := d.root.Update(func(txn *badger.Txn) error {
+		return fn(&DB{root: d.root, txn: txn})
+	})
+	if errors.Is(err, badger.ErrConflict) {
+		return fmt.Errorf("%w: %v", dbase.ErrTxFailed, err)
+	}
+	return err
+}
+
+// Migrate records the index layout of each model and rebuilds the indexes
+// of existing records when index tags were added or removed.
+func (d *DB) Migrate(ctx context.Context, models ...any) error {
+	for _, model := range models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+		}
+		if err := d.migrate(m); err != nil {
+			return fmt.Errorf("dbase/badger: migrate %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+func (d *DB) Close() error {
+	if d.txn != nil {
+		return nil
+	}
+	return d.root.Close()
+}
+
+func (d *DB) Ping(ctx context.Context) error {
+	if d.root.IsClosed() {
+		return dbase.ErrClosed
+	}
+	return nil
+}
+
+// Stats implements [dbase.StatsReporter] with the size of the LSM tree and
+// value log, as last computed by Badger.
+func (d *DB) Stats(ctx context.Context) (*dbase.Stats, error) {
+	if d.root.IsClosed() {
+		return nil, dbase.ErrClosed
+	}
+	lsm, vlog := d.root.Size()
+	return &dbase.Stats{Driver: "badger", Size: lsm + vlog}, nil
+}
+
+// SyncSequence implements [dbase.Sequencer]. The sequence of a model only
+// moves when it hands out an ID, so after explicit IDs it is set to the
+// highest stored one.
+func (d *DB) SyncSequence(ctx context.Context, model any) error {
+	m, err := schema.Of(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	if !m.Increment {
+		return nil
+	}
+	return d.update(func(txn *badger.Txn) error {
+		items, err := selectRecords(txn, m, dbase.NewQuery().OrderByDesc(m.ID.Name).SetLimit(1))
+		if err != nil || len(items) == 0 {
+			return err
+		}
+		high, err := schema.Convert(m.ID.Interface(items[0].Elem()), reflect.TypeFor[int64]())
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", dbase.ErrInvalidModel, m.Name, err)
+		}
+		seq, err := currentSequence(txn, m.Name)
+		if err != nil || high.Int() <= 0 || seq >= uint64(high.Int()) {
+			return err
+		}
+		return txn.Set(spacePrefix(m.Name, spaceSeq), binary.BigEndian.AppendUint64(nil, uint64(high.Int())))
+	})
+}
+
+// --- helpers ---
+
+func (d *DB) view(fn func(txn *badger.Txn) error) error {
+	if d.txn != nil {
+		return fn(d.txn)
+	}
+	return d.root.View(fn)
+}
+
+func (d *DB) update(fn func(txn *badger.Txn) error) error {
+	if d.txn != nil {
+		return fn(d.txn)
+	}
+	err := d.root.Update(fn)
+	if errors.Is(err, badger.ErrConflict) {
+		return fmt.Errorf("%w: %v", dbase.ErrTxFailed, err)
+	}
+	return err
+}
+
+// write puts the struct v of m in its own transaction unless d is a
+// transaction scope; see [record.Write].
+func (d *DB) write(_ context.Context, m *schema.Model, v reflect.Value, mode record.Mode) error {
+	return d.update(func(txn *badger.Txn) error { return put(txn, m, v, mode) })
+}
+
+// put stores the struct v, assigning an ID from the model sequence when
+// needed and maintaining secondary and unique indexes.
+func put(txn *badger.Txn, m *schema.Model, v reflect.Value, mode record.Mode) error {
+	err := record.AssignID(m, v, mode, func() (any, error) { return nextSequence(txn, m.Name) })
+	if err != nil {
+		return err
+	}
+
+	idField := m.ID.Value(v)
+	idKey, err := encodeValue(idField)
+	if err != nil {
+		return err
+	}
+	key := dataKey(m.Name, idKey)
+
+	old := m.New()
+	exists, err := load(txn, key, old.Interface())
+	if err != nil {
+		return err
+	}
+	if err := mode.Check(m, idField.Interface(), exists); err != nil {
+		return err
+	}
+	if exists {
+		if err := dropIndexes(txn, m, old.Elem(), idKey); err != nil {
+			return err
+		}
+	}
+
+	if err := addIndexes(txn, m, v, idKey); err != nil {
+		return err
+	}
+	raw, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Errorf("dbase/badger: encode %s: %w", m.Name, err)
+	}
+	return txn.Set(key, raw)
+}
+
+func addIndexes(txn *badger.Txn, m *schema.Model, v reflect.Value, idKey []byte) error {
+	for _, f := range m.Indexes {
+		value, err := encodeValue(f.Value(v))
+		if err != nil {
+			return err
+		}
+		if !f.Unique {
+			if err := txn.Set(indexKey(m.Name, f.Name, value, idKey), nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		ukey := uniqueKey(m.Name, f.Name, value)
+		item, err := txn.Get(ukey)
+		switch {
+		case err == nil:
+			owner, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if string(owner) != string(idKey) {
+				return fmt.Errorf("%w: %s.%s must be unique", dbase.ErrAlreadyExists, m.Name, f.Name)
+			}
+		case !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+		if err := txn.Set(ukey, idKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(txn *badger.Txn, m *schema.Model, v reflect.Value, idKey []byte) error {
+	for _, f := range m.Indexes {
+		value, err := encodeValue(f.Value(v))
+		if err != nil {
+			return err
+		}
+		key := indexKey(m.Name, f.Name, value, idKey)
+		if f.Unique {
+			key = uniqueKey(m.Name, f.Name, value)
+		}
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load decodes the record at key into dst and reports whether it exists.
+func load(txn *badger.Txn, key []byte, dst any) (bool, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	err = item.Value(func(raw []byte) error {
+		return json.Unmarshal(raw, dst)
+	})
+	return err == nil, err
+}
+
+// nextSequence increments and returns the ID sequence of a bucket. The
+// read-modify-write is part of the enclosing transaction, so concurrent
+// writers conflict instead of reusing IDs.
+func nextSequence(txn *badger.Txn, bucket string) (uint64, error) {
+	seq, err := currentSequence(txn, bucket)
+	if err != nil {
+		return 0, err
+	}
+	seq++
+	return seq, txn.Set(spacePrefix(bucket, spaceSeq), binary.BigEndian.AppendUint64(nil, seq))
+}
+
+// currentSequence returns the last ID handed out by the sequence of a
+// bucket, or 0.
+func currentSequence(txn *badger.Txn, bucket string) (uint64, error) {
+	item, err := txn.Get(spacePrefix(bucket, spaceSeq))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	raw, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(raw), nil
+}
+
+// rebuildBatch is the number of records read per transaction when index
+// entries are rebuilt.
+const rebuildBatch = 1000
+
+// migrate compares the stored index layout of m with its struct tags and
+// rebuilds all index entries when they differ. Outside Transaction the
+// rebuild is split into transactions that fit Badger's size limits; the
+// layout is removed first and recorded last, so an interrupted rebuild
+// starts over at the next Migrate.
+func (d *DB) migrate(m *schema.Model) error {
+	want := make([]string, 0, len(m.Indexes))
+	for _, f := range m.Indexes {
+		kind := "index"
+		if f.Unique {
+			kind = "unique"
+		}
+		want = append(want, f.Name+":"+kind)
+	}
+	wantRaw, err := json.Marshal(want)
+	if err != nil {
+		return err
+	}
+
+	metaKey := spacePrefix(m.Name, spaceMeta)
+	var current bool
+	err = d.view(func(txn *badger.Txn) error {
+		item, err := txn.Get(metaKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		have, err := item.ValueCopy(nil)
+		current = string(have) == string(wantRaw)
+		return err
+	})
+	if err != nil || current {
+		return err
+	}
+
+	if err := d.update(func(txn *badger.Txn) error { return txn.Delete(metaKey) }); err != nil {
+		return err
+	}
+	for _, space := range []byte{spaceIndex, spaceUnique} {
+		err := d.batches(spacePrefix(m.Name, space), func(txn *badger.Txn, key, _ []byte) error {
+			return txn.Delete(key)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	dataPrefix := spacePrefix(m.Name, spaceData)
+	err = d.batches(dataPrefix, func(txn *badger.Txn, key, raw []byte) error {
+		rec := m.New()
+		if err := json.Unmarshal(raw, rec.Interface()); err != nil {
+			return err
+		}
+		return addIndexes(txn, m, rec.Elem(), key[len(dataPrefix):])
+	})
+	if err != nil {
+		return err
+	}
+	return d.update(func(txn *badger.Txn) error { return txn.Set(metaKey, wantRaw) })
+}
+
+// batches calls fn with a copy of each key and value under prefix, in
+// order. Outside Transaction it commits every rebuildBatch keys, or as soon
+// as a transaction is full, in which case fn is called again with the same
+// key in the next one; fn must therefore be idempotent. Inside Transaction
+// all calls share the enclosing transaction.
+func (d *DB) batches(prefix []byte, fn func(txn *badger.Txn, key, value []byte) error) error {
+	if d.txn != nil {
+		entries, err := collect(d.txn, prefix, prefix, 0)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := fn(d.txn, e[0], e[1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	seek := prefix
+	for seek != nil {
+		err := d.update(func(txn *badger.Txn) error {
+			entries, err := collect(txn, prefix, seek, rebuildBatch)
+			if err != nil {
+				return err
+			}
+			seek = nil
+			for i, e := range entries {
+				err := fn(txn, e[0], e[1])
+				if errors.Is(err, badger.ErrTxnTooBig) && i > 0 {
+					seek = e[0]
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if len(entries) == rebuildBatch {
+				seek = append(entries[len(entries)-1][0], 0)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect returns copies of the keys and values under prefix starting at
+// seek, at most limit of them unless limit is 0. Callers work on them
+// after the iterator is closed because read-write transactions allow only
+// one open iterator at a time.
+func collect(txn *badger.Txn, prefix, seek []byte, limit int) ([][2][]byte, error) {
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, PrefetchValues: true, PrefetchSize: 100})
+	defer it.Close()
+	var entries [][2][]byte
+	for it.Seek(seek); it.Valid() && (limit == 0 || len(entries) < limit); it.Next() {
+		item := it.Item()
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, [2][]byte{item.KeyCopy(nil), value})
+	}
+	return entries, nil
+}
+
+var (
+	_ dbase.Database      = (*DB)(nil)
+	_ dbase.StatsReporter = (*DB)(nil)
+	_ dbase.Sequencer     = (*DB)(nil)
+)
@@ -0,0 +1,111 @@
+package badger_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	bdg "github.com/dgraph-io/badger/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/badger"
+	"github.com/nuln/dbase/dbasetest"
+)
+
+func TestBadger(t *testing.T) {
+	db, err := badger.New(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to open badger: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	dbasetest.Suite(t, db)
+}
+
+type indexedModel struct {
+	ID    uint   `storm:"id,increment"`
+	Group string `storm:"index"`
+	Code  string `storm:"unique"`
+	Score int    `storm:"index"`
+}
+
+func TestBadgerIndexScans(t *testing.T) {
+	ctx := context.Background()
+	db, err := badger.New(":memory:")
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, db.Migrate(ctx, &indexedModel{}))
+	for i, g := range []string{"a", "b", "a", "c", "ab"} {
+		rec := &indexedModel{Group: g, Code: fmt.Sprintf("c%d", i), Score: i * 10}
+		require.NoError(t, db.Create(ctx, rec))
+	}
+
+	find := func(q *dbase.Query) []uint {
+		var out []indexedModel
+		require.NoError(t, db.Find(ctx, &out, q.OrderByAsc("ID")))
+		ids := make([]uint, 0, len(out))
+		for _, r := range out {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []uint{1, 3}, find(dbase.Eq("Group", "a")))
+	assert.Equal(t, []uint{2, 4}, find(dbase.In("Group", "b", "c")))
+	assert.Equal(t, []uint{1, 3, 5}, find(dbase.NewQuery().Where("Group", dbase.OpPrefix, "a")))
+	assert.Equal(t, []uint{3, 4, 5}, find(dbase.NewQuery().Where("Score", dbase.OpGreater, 10)))
+	assert.Equal(t, []uint{1, 2}, find(dbase.NewQuery().Where("Score", dbase.OpLessEqual, 10)))
+	assert.Equal(t, []uint{4}, find(dbase.Eq("Code", "c3")))
+	assert.Equal(t, []uint{2, 3}, find(dbase.NewQuery().Where("ID", dbase.OpGreaterEqual, 2).Where("ID", dbase.OpLess, 4)))
+	assert.Equal(t, []uint{1, 2}, find(dbase.Eq("Group", "a").Or("Group", dbase.OpEqual, "b").SetLimit(2)))
+
+	err = db.Create(ctx, &indexedModel{Group: "d", Code: "c0"})
+	assert.ErrorIs(t, err, dbase.ErrAlreadyExists)
+
+	rec := &indexedModel{}
+	require.NoError(t, db.Get(ctx, rec, 1))
+	rec.Group, rec.Score = "z", 99
+	require.NoError(t, db.UpdateFields(ctx, rec, "Group"))
+	require.NoError(t, db.Get(ctx, rec, 1))
+	assert.Equal(t, "z", rec.Group)
+	assert.Equal(t, 0, rec.Score)
+	assert.Equal(t, []uint{3}, find(dbase.Eq("Group", "a")))
+}
+
+func TestBadgerMigrateRebuildsInBatches(t *testing.T) {
+	ctx := context.Background()
+	// A small memtable makes Badger reject large transactions early.
+	bdb, err := bdg.Open(bdg.DefaultOptions("").WithInMemory(true).WithMemTableSize(1 << 20).WithValueThreshold(1 << 10).WithLogger(nil))
+	require.NoError(t, err)
+	db := badger.FromBadger(bdb)
+	defer func() { _ = db.Close() }()
+
+	const n = 5000
+	require.NoError(t, db.Migrate(ctx, &indexedModel{}))
+	for i := range n {
+		require.NoError(t, db.Create(ctx, &indexedModel{Group: fmt.Sprint(i % 7), Code: fmt.Sprint(i), Score: i}))
+	}
+
+	// Indexing Note as well rebuilds every index entry of the model.
+	type indexedModel struct {
+		ID    uint   `storm:"id,increment"`
+		Group string `storm:"index"`
+		Code  string `storm:"unique"`
+		Score int    `storm:"index"`
+		Note  string `storm:"index"`
+	}
+	require.NoError(t, db.Migrate(ctx, &indexedModel{}))
+
+	count, err := db.Count(ctx, &indexedModel{}, dbase.Eq("Group", "3"))
+	require.NoError(t, err)
+	assert.EqualValues(t, n/7, count)
+	count, err = db.Count(ctx, &indexedModel{}, dbase.Eq("Note", ""))
+	require.NoError(t, err)
+	assert.EqualValues(t, n, count)
+	var rec indexedModel
+	require.NoError(t, db.FindOne(ctx, &rec, dbase.Eq("Code", "4321")))
+	assert.Equal(t, 4321, rec.Score)
+}
@@ -0,0 +1,202 @@
+package badger
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// Key layout. Every model owns the key space starting with its bucket name
+// followed by a zero byte:
+//
+//	<bucket> 0x00 'd' <id>                          -> JSON record
+//	<bucket> 0x00 'i' <field> 0x00 <value> <id>     -> empty (secondary index)
+//	<bucket> 0x00 'u' <field> 0x00 <value>          -> <id> (unique index)
+//	<bucket> 0x00 's'                               -> uint64 ID sequence
+//	<bucket> 0x00 'm'                               -> JSON index metadata
+//
+// <id> and <value> are encoded with encodeValue, which preserves ordering
+// and is self-delimiting so index keys can be split back into their parts.
+const (
+	spaceData   = 'd'
+	spaceIndex  = 'i'
+	spaceUnique = 'u'
+	spaceSeq    = 's'
+	spaceMeta   = 'm'
+)
+
+// Value type tags. Tags order values of different types relative to each
+// other; within a type the encoding preserves natural ordering.
+const (
+	tagNil    = 0x01
+	tagFalse  = 0x02
+	tagTrue   = 0x03
+	tagInt    = 0x10
+	tagUint   = 0x11
+	tagFloat  = 0x12
+	tagTime   = 0x18
+	tagString = 0x20
+	tagBytes  = 0x21
+)
+
+var errBadKey = errors.New("dbase/badger: malformed key")
+
+func bucketPrefix(bucket string) []byte {
+	return append([]byte(bucket), 0)
+}
+
+func spacePrefix(bucket string, space byte) []byte {
+	return append(bucketPrefix(bucket), space)
+}
+
+func dataKey(bucket string, id []byte) []byte {
+	return append(spacePrefix(bucket, spaceData), id...)
+}
+
+func fieldPrefix(bucket string, space byte, field string) []byte {
+	p := spacePrefix(bucket, space)
+	p = append(p, field...)
+	return append(p, 0)
+}
+
+func indexKey(bucket, field string, value, id []byte) []byte {
+	k := fieldPrefix(bucket, spaceIndex, field)
+	k = append(k, value...)
+	return append(k, id...)
+}
+
+func uniqueKey(bucket, field string, value []byte) []byte {
+	return append(fieldPrefix(bucket, spaceUnique, field), value...)
+}
+
+// encodeValue encodes v into an order-preserving, self-delimiting key.
+func encodeValue(v reflect.Value) ([]byte, error) {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return []byte{tagNil}, nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return []byte{tagNil}, nil
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return appendUint(tagTime, uint64(t.UnixNano())^(1<<63)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return []byte{tagTrue}, nil
+		}
+		return []byte{tagFalse}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendUint(tagInt, uint64(v.Int())^(1<<63)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return appendUint(tagUint, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		bits := math.Float64bits(v.Float())
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return appendUint(tagFloat, bits), nil
+	case reflect.String:
+		return appendEscaped(tagString, []byte(v.String())), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendEscaped(tagBytes, v.Bytes()), nil
+		}
+	}
+
+	// Composite values are only usable for equality lookups.
+	raw, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("dbase/badger: encode %s: %w", v.Type(), err)
+	}
+	return appendEscaped(tagBytes, raw), nil
+}
+
+// encodeField converts value to the type of field f and encodes it, so that
+// query arguments of a different numeric type still hit the index.
+func encodeField(f *schema.Field, value any) ([]byte, error) {
+	v, err := schema.Convert(value, f.Type)
+	if err != nil {
+		return nil, fmt.Errorf("dbase/badger: field %s: %w", f.Name, err)
+	}
+	return encodeValue(v)
+}
+
+func appendUint(tag byte, u uint64) []byte {
+	b := make([]byte, 9)
+	b[0] = tag
+	binary.BigEndian.PutUint64(b[1:], u)
+	return b
+}
+
+// appendEscaped writes raw with 0x00 escaped as 0x00 0xFF and terminated by
+// 0x00 0x01, which keeps byte-wise ordering identical to the raw ordering.
+func appendEscaped(tag byte, raw []byte) []byte {
+	b := make([]byte, 0, len(raw)+3)
+	b = append(b, tag)
+	for _, c := range raw {
+		if c == 0 {
+			b = append(b, 0, 0xFF)
+			continue
+		}
+		b = append(b, c)
+	}
+	return append(b, 0, 0x01)
+}
+
+// escapedPrefix encodes s like appendEscaped but without the terminator, so
+// that it is a byte prefix of the encoding of every string starting with s.
+func escapedPrefix(s string) []byte {
+	b := appendEscaped(tagString, []byte(s))
+	return b[:len(b)-2]
+}
+
+// valueLen returns the length of the encoded value at the start of b.
+func valueLen(b []byte) (int, error) {
+	if len(b) == 0 {
+		return 0, errBadKey
+	}
+	switch b[0] {
+	case tagNil, tagFalse, tagTrue:
+		return 1, nil
+	case tagInt, tagUint, tagFloat, tagTime:
+		if len(b) < 9 {
+			return 0, errBadKey
+		}
+		return 9, nil
+	case tagString, tagBytes:
+		for i := 1; i+1 < len(b); i++ {
+			if b[i] != 0 {
+				continue
+			}
+			if b[i+1] == 0x01 {
+				return i + 2, nil
+			}
+			i++ // skip escaped zero
+		}
+	}
+	return 0, errBadKey
+}
+
+// splitIndexKey splits the remainder of a secondary index key (after the
+// field prefix) into its value and ID parts.
+func splitIndexKey(rest []byte) (value, id []byte, err error) {
+	n, err := valueLen(rest)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rest[:n], rest[n:], nil
+}
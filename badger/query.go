@@ -0,0 +1,277 @@
+package badger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/dgraph-io/badger/v4"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// selectRecords returns pointers to the records of m matching query, sorted
+// and paginated. When the query is a pure conjunction with a condition on
+// the primary key or an indexed field, candidates come from an index scan;
+// otherwise every record of the model is scanned. Candidates are always
+// re-checked against all conditions.
+func selectRecords(txn *badger.Txn, m *schema.Model, query *dbase.Query) ([]reflect.Value, error) {
+	q := dbase.Query{}
+	if query != nil {
+		q = *query
+	}
+
+	var items []reflect.Value
+	accept := func(rec reflect.Value) error {
+		ok, err := eval.Match(eval.StructGetter(m, rec), q.Conditions)
+		if err != nil {
+			return err
+		}
+		if ok {
+			items = append(items, rec)
+		}
+		return nil
+	}
+
+	ids, planned, err := planIndex(txn, m, q.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	if planned {
+		for _, id := range ids {
+			rec := m.New()
+			found, err := load(txn, dataKey(m.Name, id), rec.Interface())
+			if err != nil {
+				return nil, err
+			}
+			if !found {
+				continue
+			}
+			if err := accept(rec); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		it := txn.NewIterator(badger.IteratorOptions{
+			Prefix: spacePrefix(m.Name, spaceData), PrefetchValues: true, PrefetchSize: 100,
+		})
+		for it.Rewind(); it.Valid(); it.Next() {
+			rec := m.New()
+			err := it.Item().Value(func(raw []byte) error {
+				return json.Unmarshal(raw, rec.Interface())
+			})
+			if err == nil {
+				err = accept(rec)
+			}
+			if err != nil {
+				it.Close()
+				return nil, err
+			}
+		}
+		it.Close()
+	}
+
+	eval.Sort(items, q.OrderBy, func(rec reflect.Value) eval.Getter {
+		return eval.StructGetter(m, rec)
+	})
+	return eval.Select(items, m, &q)
+}
+
+// planIndex picks the most selective indexed condition of a conjunctive
+// query and returns the encoded IDs it yields. planned is false when no
+// index applies.
+func planIndex(txn *badger.Txn, m *schema.Model, conds []dbase.Condition) (ids [][]byte, planned bool, err error) {
+	for _, c := range conds {
+		if c.Or {
+			return nil, false, nil
+		}
+	}
+
+	// Equality lookups first, then ranges.
+	for _, pass := range [][]dbase.Operator{
+		{dbase.OpEqual, dbase.OpIn},
+		{dbase.OpGreater, dbase.OpGreaterEqual, dbase.OpLess, dbase.OpLessEqual, dbase.OpPrefix},
+	} {
+		for _, c := range conds {
+			f := m.Field(c.Field)
+			if f == nil || (f != m.ID && !f.Indexed) || !hasOperator(pass, c.Operator) {
+				continue
+			}
+			ids, ok, err := scanIndex(txn, m, f, c)
+			if err != nil || ok {
+				return ids, ok, err
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+func hasOperator(ops []dbase.Operator, op dbase.Operator) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// scanIndex returns the IDs matched by a single condition on field f.
+// ok is false when the condition value cannot be encoded for the index.
+func scanIndex(txn *badger.Txn, m *schema.Model, f *schema.Field, c dbase.Condition) (ids [][]byte, ok bool, err error) {
+	ix := indexSpace{m: m, f: f}
+
+	switch c.Operator {
+	case dbase.OpEqual:
+		target, err := encodeField(f, c.Value)
+		if err != nil {
+			return nil, false, nil
+		}
+		ids, err := ix.equal(txn, target)
+		return ids, true, err
+
+	case dbase.OpIn:
+		values := reflect.ValueOf(c.Value)
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return nil, false, nil
+		}
+		seen := make(map[string]bool)
+		for i := 0; i < values.Len(); i++ {
+			target, err := encodeField(f, values.Index(i).Interface())
+			if err != nil {
+				return nil, false, nil
+			}
+			found, err := ix.equal(txn, target)
+			if err != nil {
+				return nil, true, err
+			}
+			for _, id := range found {
+				if !seen[string(id)] {
+					seen[string(id)] = true
+					ids = append(ids, id)
+				}
+			}
+		}
+		return ids, true, nil
+
+	case dbase.OpPrefix:
+		s, isString := c.Value.(string)
+		if !isString || f.Type.Kind() != reflect.String {
+			return nil, false, nil
+		}
+		ids, err := ix.scan(txn, escapedPrefix(s), nil, func([]byte) (bool, bool) { return true, true })
+		return ids, true, err
+	}
+
+	target, err := encodeField(f, c.Value)
+	if err != nil {
+		return nil, false, nil
+	}
+	// keep reports whether an index value matches; more is false once the
+	// ordered scan has passed every possible match.
+	var seek []byte
+	var keep func(value []byte) (match, more bool)
+	switch c.Operator {
+	case dbase.OpGreater:
+		seek = target
+		keep = func(v []byte) (bool, bool) { return bytes.Compare(v, target) > 0, true }
+	case dbase.OpGreaterEqual:
+		seek = target
+		keep = func(v []byte) (bool, bool) { return true, true }
+	case dbase.OpLess:
+		keep = func(v []byte) (bool, bool) { n := bytes.Compare(v, target); return n < 0, n < 0 }
+	case dbase.OpLessEqual:
+		keep = func(v []byte) (bool, bool) { n := bytes.Compare(v, target); return n <= 0, n <= 0 }
+	default:
+		return nil, false, nil
+	}
+	ids, err = ix.scan(txn, nil, seek, keep)
+	return ids, true, err
+}
+
+// indexSpace abstracts over the three key layouts that can serve a lookup:
+// the data space itself (for the primary key), unique indexes and
+// secondary indexes.
+type indexSpace struct {
+	m *schema.Model
+	f *schema.Field
+}
+
+func (ix indexSpace) prefix() []byte {
+	switch {
+	case ix.f == ix.m.ID:
+		return spacePrefix(ix.m.Name, spaceData)
+	case ix.f.Unique:
+		return fieldPrefix(ix.m.Name, spaceUnique, ix.f.Name)
+	}
+	return fieldPrefix(ix.m.Name, spaceIndex, ix.f.Name)
+}
+
+// equal returns the IDs whose field value encodes to target.
+func (ix indexSpace) equal(txn *badger.Txn, target []byte) ([][]byte, error) {
+	if ix.f == ix.m.ID || ix.f.Unique {
+		item, err := txn.Get(append(ix.prefix(), target...))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if ix.f == ix.m.ID {
+			return [][]byte{target}, nil
+		}
+		id, err := item.ValueCopy(nil)
+		return [][]byte{id}, err
+	}
+	return ix.scan(txn, target, nil, func([]byte) (bool, bool) { return true, true })
+}
+
+// scan iterates the index entries whose encoded value starts with
+// valuePrefix, beginning at seek, and collects the IDs accepted by keep.
+func (ix indexSpace) scan(txn *badger.Txn, valuePrefix, seek []byte,
+	keep func(value []byte) (match, more bool)) ([][]byte, error) {
+	base := ix.prefix()
+	prefix := append(append([]byte{}, base...), valuePrefix...)
+
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, PrefetchValues: ix.f.Unique && ix.f != ix.m.ID})
+	defer it.Close()
+
+	start := prefix
+	if seek != nil {
+		start = append(append([]byte{}, base...), seek...)
+	}
+
+	var ids [][]byte
+	for it.Seek(start); it.Valid(); it.Next() {
+		item := it.Item()
+		rest := item.KeyCopy(nil)[len(base):]
+
+		var value, id []byte
+		switch {
+		case ix.f == ix.m.ID:
+			value, id = rest, rest
+		case ix.f.Unique:
+			owner, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, err
+			}
+			value, id = rest, owner
+		default:
+			var err error
+			if value, id, err = splitIndexKey(rest); err != nil {
+				return nil, err
+			}
+		}
+
+		match, more := keep(value)
+		if !more {
+			break
+		}
+		if match {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
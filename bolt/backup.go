@@ -0,0 +1,43 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/atomicfile"
+)
+
+// Backup implements [dbase.Backupper]. It copies the whole bolt file from a
+// read-only transaction, so writers are not blocked and the copy is
+// consistent. Views returned by From back up the whole file as well.
+func (d *DB) Backup(ctx context.Context, w io.Writer) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return fmt.Errorf("dbase/bolt: backup inside a transaction: %w", dbase.ErrNotSupported)
+	}
+	return d.file.View(func(tx *bbolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := tx.WriteTo(w); err != nil {
+			return fmt.Errorf("dbase/bolt: backup: %w", err)
+		}
+		return nil
+	})
+}
+
+// BackupTo implements [dbase.Backupper].
+func (d *DB) BackupTo(ctx context.Context, path string) error {
+	return atomicfile.Write(path, func(w io.Writer) error {
+		return d.Backup(ctx, w)
+	})
+}
+
+var _ dbase.Backupper = (*DB)(nil)
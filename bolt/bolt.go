@@ -7,19 +7,78 @@ package bolt
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"reflect"
+	"time"
 
 	"github.com/asdine/storm/v3"
+	"github.com/asdine/storm/v3/codec"
+	"github.com/asdine/storm/v3/codec/gob"
+	"github.com/asdine/storm/v3/codec/json"
 	"github.com/asdine/storm/v3/q"
+	"go.etcd.io/bbolt"
 
 	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/gate"
+	"github.com/nuln/dbase/internal/schema"
 )
 
 func init() {
 	dbase.Register("bolt", func(cfg *dbase.Config) (dbase.Database, error) {
-		return New(cfg.Path)
-	})
+		if len(cfg.Replicas) > 0 {
+			return nil, fmt.Errorf("dbase/bolt: replicas: %w", dbase.ErrNotSupported)
+		}
+		var opts Options
+		if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return Open(cfg.Path, opts)
+	}, dbase.WithDescription("BoltDB file, through Storm"),
+		dbase.Requires("Path"), dbase.WithOptions(Options{}), dbase.WithCapabilities(capabilities))
+}
+
+// capabilities of the bolt driver. Storm's matchers have no OR, NOT IN,
+// prefix or null tests, and treat a like pattern as a regular expression;
+// queries using them fail with [dbase.ErrNotSupported].
+var capabilities = dbase.Capabilities{
+	Operators: []dbase.Operator{
+		dbase.OpEqual, dbase.OpNotEqual, dbase.OpGreater, dbase.OpGreaterEqual,
+		dbase.OpLess, dbase.OpLessEqual, dbase.OpIn,
+	},
+	AtomicTransactions: true,
+}
+
+// Options configures a bolt database. The driver reads them from
+// [dbase.Config.Options] by the keys in their option tags.
+type Options struct {
+	// Timeout is how long to wait for the file lock held by another
+	// process. It defaults to one second; a negative value waits forever.
+	Timeout time.Duration `option:"timeout"`
+
+	// ReadOnly opens the file with a shared lock and rejects writes. The
+	// file must exist.
+	ReadOnly bool `option:"read_only"`
+
+	// NoSync skips fsync after each commit. It is faster but loses recent
+	// writes, and may corrupt the file, on a crash.
+	NoSync bool `option:"no_sync"`
+
+	// Codec encodes records: "json" (default) or "gob".
+	Codec string `option:"codec"`
+
+	// BatchSize, when positive, makes concurrent writes outside
+	// transactions share commits of up to this many writes (see
+	// bbolt.DB.Batch), waiting at most BatchDelay for a batch to fill.
+	BatchSize  int           `option:"batch_size"`
+	BatchDelay time.Duration `option:"batch_delay"`
+}
+
+// codecs are the storm codecs selectable by [Options.Codec].
+var codecs = map[string]codec.MarshalUnmarshaler{
+	"json": json.Codec,
+	"gob":  gob.Codec,
 }
 
 // DB implements [dbase.Database] using Storm (BoltDB).
@@ -28,40 +87,94 @@ func init() {
 type DB struct {
 	root *storm.DB  // root DB handle, nil for transaction nodes
 	node storm.Node // active node (root or transaction)
+	file *bbolt.DB  // underlying bolt file, nil for transaction nodes
+	gate *gate.Gate // shared with views, nil for transaction nodes
+	tx   *bbolt.Tx  // bolt transaction of transaction nodes
 }
 
-// New creates a new Storm-backed database.
+// New creates a new Storm-backed database with default options.
 func New(path string) (*DB, error) {
-	s, err := storm.Open(path)
+	return Open(path, Options{})
+}
+
+// Open opens (or creates) a Storm-backed database with the given options.
+func Open(path string, opts Options) (*DB, error) {
+	boltOpts := &bbolt.Options{Timeout: time.Second, ReadOnly: opts.ReadOnly, NoSync: opts.NoSync}
+	switch {
+	case opts.Timeout < 0:
+		boltOpts.Timeout = 0
+	case opts.Timeout > 0:
+		boltOpts.Timeout = opts.Timeout
+	}
+	stormOpts := []func(*storm.Options) error{storm.BoltOptions(0o600, boltOpts)}
+	if opts.Codec != "" {
+		c, ok := codecs[opts.Codec]
+		if !ok {
+			return nil, fmt.Errorf("dbase/bolt: unknown codec %q", opts.Codec)
+		}
+		stormOpts = append(stormOpts, storm.Codec(c))
+	}
+	if opts.BatchSize > 0 {
+		stormOpts = append(stormOpts, storm.Batch())
+	}
+
+	s, err := storm.Open(path, stormOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("dbase/bolt: open: %w", err)
 	}
-	return &DB{root: s, node: s}, nil
+	if opts.BatchSize > 0 {
+		s.Bolt.MaxBatchSize = opts.BatchSize
+		if opts.BatchDelay > 0 {
+			s.Bolt.MaxBatchDelay = opts.BatchDelay
+		}
+	}
+	return &DB{root: s, node: s, file: s.Bolt, gate: &gate.Gate{}}, nil
 }
 
 // FromStorm wraps an existing storm.DB instance.
 func FromStorm(s *storm.DB) *DB {
-	return &DB{root: s, node: s}
+	return &DB{root: s, node: s, file: s.Bolt, gate: &gate.Gate{}}
 }
 
 // Storm returns the underlying *storm.DB for advanced operations.
-// Returns nil if this is a transaction-scoped instance.
+// Returns nil if this is a transaction- or bucket-scoped instance.
 func (d *DB) Storm() *storm.DB { return d.root }
 
+// Bolt returns the underlying *bbolt.DB, e.g. for its statistics.
+// Returns nil if this is a transaction-scoped instance.
+func (d *DB) Bolt() *bbolt.DB { return d.file }
+
+// From returns a view of d whose records live in the given nested buckets,
+// e.g. one bucket per tenant. Closing the view does not close d.
+func (d *DB) From(buckets ...string) *DB {
+	return &DB{node: d.node.From(buckets...), file: d.file, gate: d.gate}
+}
+
 // Driver implements [dbase.Database].
 func (d *DB) Driver() string { return "bolt" }
 
+// Capabilities implements [dbase.Capable].
+func (d *DB) Capabilities() dbase.Capabilities { return capabilities }
+
 func (d *DB) Create(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeCreateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.node.Save(model); err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterCreateHooks(ctx, model)
 }
 
 func (d *DB) Get(ctx context.Context, model any, id any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	err := d.node.One("ID", id, model)
 	if err == storm.ErrNotFound {
 		return dbase.ErrNotFound
@@ -70,11 +183,15 @@ func (d *DB) Get(ctx context.Context, model any, id any) error {
 }
 
 func (d *DB) Update(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeUpdateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.node.Update(model); err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterUpdateHooks(ctx, model)
 }
@@ -85,26 +202,52 @@ func (d *DB) UpdateFields(ctx context.Context, model any, fields ...string) erro
 }
 
 func (d *DB) Save(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeCreateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.node.Save(model); err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterCreateHooks(ctx, model)
 }
 
 func (d *DB) Delete(ctx context.Context, model any, id any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeDeleteHooks(ctx, model); err != nil {
 		return err
 	}
-	if err := d.node.DeleteStruct(model); err != nil {
+	target, err := byID(model, id)
+	if err != nil {
+		return err
+	}
+	if err := d.node.DeleteStruct(target); err != nil {
+		if err == storm.ErrNotFound {
+			return dbase.ErrNotFound
+		}
 		return err
 	}
 	return dbase.RunAfterDeleteHooks(ctx, model)
 }
 
+// Find implements [dbase.Database]. Storm decodes whole records, so
+// query.Fields is applied by zeroing the other fields afterwards; with
+// query.Unique duplicates are dropped before Limit and Offset apply.
 func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	return d.find(results, query)
+}
+
+func (d *DB) find(results any, query *dbase.Query) error {
 	if query == nil || len(query.Conditions) == 0 {
 		sq := d.node.Select()
 
@@ -117,44 +260,76 @@ func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
 			setEmptySlice(results)
 			return nil
 		}
-		return err
+		if err != nil {
+			return err
+		}
+		return project(results, query)
 	}
 
-	matchers := convertToMatchers(query.Conditions)
+	matchers, err := convertToMatchers(query.Conditions)
+	if err != nil {
+		return err
+	}
 	sq := d.node.Select(matchers...)
 	sq = applyPagination(sq, query)
 
-	err := sq.Find(results)
+	err = sq.Find(results)
 	if err == storm.ErrNotFound {
 		setEmptySlice(results)
 		return nil
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return project(results, query)
 }
 
 func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if query == nil || len(query.Conditions) == 0 {
 		err := d.node.Select().First(result)
 		if err == storm.ErrNotFound {
 			return dbase.ErrNotFound
 		}
-		return err
+		if err != nil {
+			return err
+		}
+		return projectOne(result, query)
 	}
 
-	matchers := convertToMatchers(query.Conditions)
-	err := d.node.Select(matchers...).First(result)
+	matchers, err := convertToMatchers(query.Conditions)
+	if err != nil {
+		return err
+	}
+	err = d.node.Select(matchers...).First(result)
 	if err == storm.ErrNotFound {
 		return dbase.ErrNotFound
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	return projectOne(result, query)
 }
 
 func (d *DB) Count(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	if !d.gate.Enter() {
+		return 0, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if query != nil && query.Unique {
+		return d.countDistinct(model, query)
+	}
 	if query == nil || len(query.Conditions) == 0 {
 		count, err := d.node.Count(model)
 		return int64(count), err
 	}
-	matchers := convertToMatchers(query.Conditions)
+	matchers, err := convertToMatchers(query.Conditions)
+	if err != nil {
+		return 0, err
+	}
 	count, err := d.node.Select(matchers...).Count(model)
 	return int64(count), err
 }
@@ -164,38 +339,88 @@ func (d *DB) Exists(ctx context.Context, model any, query *dbase.Query) (bool, e
 	return count > 0, err
 }
 
+// Transaction runs fn in a read-write bolt transaction. Bolt allows one
+// writer at a time, so nested calls fail with [dbase.ErrNotSupported].
 func (d *DB) Transaction(ctx context.Context, fn func(tx dbase.Database) error) error {
-	txNode, err := d.node.Begin(true)
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return fmt.Errorf("dbase/bolt: nested transaction: %w", dbase.ErrNotSupported)
+	}
+	tx, err := d.file.Begin(true)
 	if err != nil {
 		return err
 	}
-	defer txNode.Rollback() //nolint:errcheck
+	defer tx.Rollback() //nolint:errcheck
 
-	if err := fn(&DB{node: txNode}); err != nil {
+	// The transaction node has no gate: this call keeps d open.
+	if err := fn(&DB{node: d.node.WithTransaction(tx), tx: tx}); err != nil {
 		return err
 	}
 
-	return txNode.Commit()
+	return tx.Commit()
 }
 
+// Migrate creates the buckets and indexes of models. When the index tags of
+// a model change, its indexes are rebuilt from the stored records; if the
+// records break a new unique tag, a [*UniqueViolationError] is returned.
 func (d *DB) Migrate(ctx context.Context, models ...any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	for _, m := range models {
-		if err := d.node.Init(m); err != nil {
-			return fmt.Errorf("dbase/bolt: init %T: %w", m, err)
+		if err := d.migrate(m); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// Close is [DB.Shutdown] without a deadline. It does nothing on views and
+// transaction-scoped instances.
 func (d *DB) Close() error {
-	if d.root != nil {
-		return d.root.Close()
+	return d.Shutdown(context.Background())
+}
+
+// Shutdown implements [dbase.Shutdowner]. When ctx is done before the
+// operations in flight finish, the file is closed anyway, which bolt
+// delays until the open transactions end.
+func (d *DB) Shutdown(ctx context.Context) error {
+	if d.root == nil {
+		return nil
 	}
-	return nil
+	first, err := d.gate.Close(ctx)
+	if !first {
+		return nil
+	}
+	return errors.Join(err, d.root.Close())
 }
 
+// Ping checks that the bolt file is still open, by starting a read-only
+// transaction, and still present on disk. After Close it returns
+// [dbase.ErrClosed].
 func (d *DB) Ping(ctx context.Context) error {
-	return nil // BoltDB is file-based; always available if opened.
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return nil // a transaction holds the file open
+	}
+	err := d.file.View(func(*bbolt.Tx) error { return ctx.Err() })
+	if errors.Is(err, bbolt.ErrDatabaseNotOpen) {
+		return dbase.ErrClosed
+	}
+	if err != nil {
+		return fmt.Errorf("dbase/bolt: ping: %w", err)
+	}
+	if _, err := os.Stat(d.file.Path()); err != nil {
+		return fmt.Errorf("dbase/bolt: ping: %w", err)
+	}
+	return nil
 }
 
 // --- helpers ---
@@ -208,6 +433,10 @@ func applyPagination(sq storm.Query, query *dbase.Query) storm.Query {
 			sq = sq.OrderBy(order.Field)
 		}
 	}
+	if query.Unique {
+		// Duplicates are dropped after decoding; see project.
+		return sq
+	}
 	if query.Limit > 0 {
 		sq = sq.Limit(query.Limit)
 	}
@@ -217,12 +446,18 @@ func applyPagination(sq storm.Query, query *dbase.Query) storm.Query {
 	return sq
 }
 
-func convertToMatchers(conditions []dbase.Condition) []q.Matcher {
+// convertToMatchers translates conditions into Storm matchers. Conditions
+// outside capabilities fail with [dbase.ErrNotSupported] instead of being
+// dropped, which would widen the result.
+func convertToMatchers(conditions []dbase.Condition) ([]q.Matcher, error) {
 	matchers := make([]q.Matcher, 0, len(conditions))
 
 	for _, cond := range conditions {
-		var m q.Matcher
+		if cond.Or {
+			return nil, fmt.Errorf("dbase/bolt: OR conditions: %w", dbase.ErrNotSupported)
+		}
 
+		var m q.Matcher
 		switch cond.Operator {
 		case dbase.OpEqual:
 			m = q.Eq(cond.Field, cond.Value)
@@ -238,19 +473,37 @@ func convertToMatchers(conditions []dbase.Condition) []q.Matcher {
 			m = q.Lte(cond.Field, cond.Value)
 		case dbase.OpIn:
 			m = q.In(cond.Field, cond.Value)
-		case dbase.OpLike:
-			if s, ok := cond.Value.(string); ok {
-				m = q.Re(cond.Field, s)
-			}
 		default:
-			continue
+			return nil, fmt.Errorf("dbase/bolt: %s conditions: %w", cond.Operator, dbase.ErrNotSupported)
 		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
 
-		if m != nil {
-			matchers = append(matchers, m)
-		}
+// convertError maps Storm errors to the dbase sentinel errors.
+func convertError(err error) error {
+	if err == storm.ErrAlreadyExists {
+		return fmt.Errorf("%w: unique constraint violated", dbase.ErrAlreadyExists)
 	}
-	return matchers
+	return err
+}
+
+// byID returns a new record of the type of model holding only id. Storm
+// deletes by the ID of the struct it is given, and the ID already in model
+// must not take precedence over id.
+func byID(model any, id any) (any, error) {
+	m, err := schema.Of(model)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	value, err := schema.Convert(id, m.ID.Type)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	rec := m.New()
+	m.ID.Value(rec).Set(value)
+	return rec.Interface(), nil
 }
 
 // setEmptySlice initializes the results pointer to an empty slice so that
@@ -262,4 +515,7 @@ func setEmptySlice(results any) {
 	}
 }
 
-var _ dbase.Database = (*DB)(nil)
+var (
+	_ dbase.Database   = (*DB)(nil)
+	_ dbase.Shutdowner = (*DB)(nil)
+)
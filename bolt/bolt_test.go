@@ -1,10 +1,18 @@
 package bolt_test
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+
+	"github.com/nuln/dbase"
 	"github.com/nuln/dbase/bolt"
 	"github.com/nuln/dbase/dbasetest"
 )
@@ -24,3 +32,248 @@ func TestBolt(t *testing.T) {
 
 	dbasetest.Suite(t, db)
 }
+
+func TestBoltPlanMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// Two versions of the same model; local types share the bucket name.
+	v1 := func() any {
+		type Item struct {
+			ID   int    `storm:"id"`
+			Name string `storm:"index"`
+			SKU  string
+		}
+		return &Item{}
+	}()
+	v2 := func() any {
+		type Item struct {
+			ID   int `storm:"id"`
+			Name string
+			SKU  string `storm:"unique"`
+		}
+		return &Item{}
+	}()
+
+	plan, err := dbase.PlanMigrate(ctx, db, v1)
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 3)
+	assert.Equal(t, dbase.ChangeCreateTable, plan.Changes[0].Kind)
+	assert.Equal(t, "create_index Item.ID index __storm_index_ID: unique", plan.Changes[1].String())
+	assert.Equal(t, "create_index Item.Name index __storm_index_Name: index", plan.Changes[2].String())
+
+	require.NoError(t, db.Migrate(ctx, v1))
+	plan, err = db.PlanMigrate(ctx, v1)
+	require.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	plan, err = db.PlanMigrate(ctx, v2)
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 2)
+	assert.Equal(t, dbase.ChangeCreateIndex, plan.Changes[0].Kind)
+	assert.Equal(t, "SKU", plan.Changes[0].Column)
+	assert.Equal(t, "unique", plan.Changes[0].To)
+	assert.Equal(t, dbase.ChangeExtraIndex, plan.Changes[1].Kind)
+	assert.Equal(t, "Name", plan.Changes[1].Column)
+	assert.Empty(t, plan.DDL())
+}
+
+func TestBoltMigrateRebuildsIndexes(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// Each block declares a new version of the same model.
+	{
+		type Item struct {
+			ID   int `storm:"id"`
+			Name string
+		}
+		require.NoError(t, db.Migrate(ctx, &Item{}))
+		for i, name := range []string{"a", "b", "a"} {
+			require.NoError(t, db.Create(ctx, &Item{ID: i + 1, Name: name}))
+		}
+	}
+	{
+		type Item struct {
+			ID   int    `storm:"id"`
+			Name string `storm:"index"`
+		}
+		require.NoError(t, db.Migrate(ctx, &Item{}))
+		var items []Item
+		require.NoError(t, db.Storm().Find("Name", "a", &items))
+		assert.Len(t, items, 2)
+	}
+	{
+		type Item struct {
+			ID   int    `storm:"id"`
+			Name string `storm:"unique"`
+		}
+		plan, err := db.PlanMigrate(ctx, &Item{})
+		require.NoError(t, err)
+		require.Len(t, plan.Changes, 1)
+		assert.Equal(t, "create_index Item.Name index __storm_index_Name: index -> unique", plan.Changes[0].String())
+
+		err = db.Migrate(ctx, &Item{})
+		require.ErrorIs(t, err, dbase.ErrAlreadyExists)
+		var uv *bolt.UniqueViolationError
+		require.ErrorAs(t, err, &uv)
+		assert.Equal(t, "Item", uv.Bucket)
+		assert.Equal(t, []bolt.UniqueViolation{{Field: "Name", Value: "a", IDs: []any{1, 3}}}, uv.Violations)
+
+		require.NoError(t, db.Delete(ctx, &Item{}, 3))
+		require.NoError(t, db.Migrate(ctx, &Item{}))
+		err = db.Create(ctx, &Item{ID: 4, Name: "b"})
+		assert.ErrorIs(t, err, dbase.ErrAlreadyExists)
+	}
+	{
+		type Item struct {
+			ID   int `storm:"id"`
+			Name string
+		}
+		require.NoError(t, db.Migrate(ctx, &Item{}))
+		plan, err := db.PlanMigrate(ctx, &Item{})
+		require.NoError(t, err)
+		assert.True(t, plan.Empty())
+	}
+}
+
+func TestBoltDeleteByID(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	type Item struct {
+		ID   int    `storm:"id"`
+		Name string `storm:"index"`
+	}
+	require.NoError(t, db.Migrate(ctx, &Item{}))
+	require.NoError(t, db.Create(ctx, &Item{ID: 1, Name: "a"}))
+	require.NoError(t, db.Create(ctx, &Item{ID: 2, Name: "b"}))
+
+	// The id argument wins over the ID already in the model.
+	require.NoError(t, db.Delete(ctx, &Item{ID: 1}, 2))
+	var items []Item
+	require.NoError(t, db.Find(ctx, &items, nil))
+	assert.Equal(t, []Item{{ID: 1, Name: "a"}}, items)
+
+	assert.ErrorIs(t, db.Delete(ctx, &Item{}, 2), dbase.ErrNotFound)
+	assert.ErrorIs(t, db.Delete(ctx, &Item{}, "x"), dbase.ErrInvalidModel)
+}
+
+func TestBoltOptions(t *testing.T) {
+	type Item struct {
+		ID   int `storm:"id"`
+		Name string
+	}
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := dbase.OpenURL("bolt://" + path + "?codec=gob&batch_size=10&no_sync=true")
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(ctx, &Item{}))
+	require.NoError(t, db.Create(ctx, &Item{ID: 1, Name: "a"}))
+
+	// The file is locked while db is open.
+	_, err = bolt.Open(path, bolt.Options{Timeout: 10 * time.Millisecond})
+	require.Error(t, err)
+	require.NoError(t, db.Close())
+
+	ro, err := bolt.Open(path, bolt.Options{ReadOnly: true, Codec: "gob"})
+	require.NoError(t, err)
+	defer func() { _ = ro.Close() }()
+	var got Item
+	require.NoError(t, ro.Get(ctx, &got, 1))
+	assert.Equal(t, "a", got.Name)
+	assert.Error(t, ro.Create(ctx, &Item{ID: 2}))
+
+	_, err = dbase.Open(&dbase.Config{Type: "bolt", Path: path, Options: map[string]any{"timout": "1s"}})
+	assert.ErrorContains(t, err, `unknown option "timout" for driver "bolt" (did you mean "timeout"?)`)
+	_, err = bolt.Open(path, bolt.Options{Codec: "xml"})
+	assert.ErrorContains(t, err, `unknown codec "xml"`)
+}
+
+func TestBoltShutdown(t *testing.T) {
+	type Item struct {
+		ID int `storm:"id"`
+	}
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(ctx, &Item{}))
+
+	// Shutdown waits for the transaction in flight and refuses new
+	// operations meanwhile.
+	started, release := make(chan struct{}), make(chan struct{})
+	txDone := make(chan error)
+	go func() {
+		txDone <- db.Transaction(ctx, func(tx dbase.Database) error {
+			close(started)
+			<-release
+			return tx.Create(ctx, &Item{ID: 1})
+		})
+	}()
+	<-started
+	shutdown := make(chan error)
+	go func() { shutdown <- db.Shutdown(ctx) }()
+	require.Eventually(t, func() bool {
+		return errors.Is(db.Get(ctx, &Item{}, 1), dbase.ErrClosed)
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, db.Create(ctx, &Item{ID: 2}), dbase.ErrClosed)
+	close(release)
+	require.NoError(t, <-txDone)
+	require.NoError(t, <-shutdown)
+
+	_, err = db.Count(ctx, &Item{}, nil)
+	assert.ErrorIs(t, err, dbase.ErrClosed)
+	assert.ErrorIs(t, db.Ping(ctx), dbase.ErrClosed)
+	assert.NoError(t, db.Close())
+}
+
+func TestBoltTx(t *testing.T) {
+	type Item struct {
+		ID   int `storm:"id"`
+		Name string
+	}
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, db.BoltTx(ctx, true, func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte("raw"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("k"), []byte("v"))
+	}))
+
+	// Inside Transaction, raw writes share the transaction.
+	err = db.Transaction(ctx, func(tx dbase.Database) error {
+		if err := tx.Create(ctx, &Item{ID: 1, Name: "a"}); err != nil {
+			return err
+		}
+		err := tx.(*bolt.DB).BoltTx(ctx, true, func(btx *bbolt.Tx) error {
+			return btx.Bucket([]byte("raw")).Delete([]byte("k"))
+		})
+		require.NoError(t, err)
+		return errors.New("roll back")
+	})
+	require.EqualError(t, err, "roll back")
+
+	require.NoError(t, db.BoltTx(ctx, false, func(tx *bbolt.Tx) error {
+		assert.Equal(t, []byte("v"), tx.Bucket([]byte("raw")).Get([]byte("k")))
+		return nil
+	}))
+	assert.ErrorIs(t, db.Get(ctx, &Item{}, 1), dbase.ErrNotFound)
+	err = db.BoltTx(ctx, false, func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucket([]byte("other"))
+		return err
+	})
+	assert.ErrorIs(t, err, bbolt.ErrTxNotWritable)
+	_, err = dbase.Exec(ctx, db, "DELETE FROM items")
+	assert.ErrorIs(t, err, dbase.ErrNotSupported)
+}
@@ -0,0 +1,207 @@
+package bolt
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+const (
+	// indexPrefix is the prefix of the index buckets Storm keeps inside a
+	// model's bucket.
+	indexPrefix = "__storm_index_"
+
+	// indexBucket holds the indexes each model was last migrated with, so
+	// that Migrate can tell when tags were added, removed or changed.
+	indexBucket = "__dbase_indexes"
+)
+
+// UniqueViolation is a value shared by several records in a field that is
+// declared unique.
+type UniqueViolation struct {
+	Field string
+	Value any
+	IDs   []any
+}
+
+// UniqueViolationError is returned by Migrate when an index rebuild finds
+// records that break a unique tag. The bucket and its indexes are left
+// untouched. It wraps [dbase.ErrAlreadyExists].
+type UniqueViolationError struct {
+	Bucket     string
+	Violations []UniqueViolation
+}
+
+func (e *UniqueViolationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "dbase/bolt: rebuild %s: unique constraint violated", e.Bucket)
+	for i, v := range e.Violations {
+		if i > 0 {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " %s=%v in %v", v.Field, v.Value, v.IDs)
+	}
+	return b.String()
+}
+
+func (e *UniqueViolationError) Unwrap() error { return dbase.ErrAlreadyExists }
+
+// migrate initializes the bucket of model. If the bucket already exists and
+// its indexes differ from the model's tags, the indexes are rebuilt from the
+// stored records, which also drops indexes that are no longer declared.
+func (d *DB) migrate(model any) error {
+	name, declared, err := stormIndexes(model)
+	if err != nil {
+		return err
+	}
+	rebuild := false
+	if d.hasBucket(name) {
+		rebuild = !maps.Equal(d.storedIndexes(name, declared), declared)
+	}
+	if rebuild {
+		if err := d.checkUnique(name, model, declared); err != nil {
+			return err
+		}
+	}
+
+	if err := d.node.Init(model); err != nil {
+		return fmt.Errorf("dbase/bolt: init %T: %w", model, err)
+	}
+	if rebuild {
+		fresh := reflect.New(schema.ElemType(reflect.TypeOf(model)))
+		if err := d.node.ReIndex(fresh.Interface()); err != nil {
+			return fmt.Errorf("dbase/bolt: rebuild %s: %w", name, convertError(err))
+		}
+	}
+	if err := d.node.Set(indexBucket, name, declared); err != nil {
+		return fmt.Errorf("dbase/bolt: save indexes of %s: %w", name, err)
+	}
+	return nil
+}
+
+// storedIndexes returns the indexes model name was last migrated with. For
+// buckets migrated before they were recorded, the index buckets are used and
+// their kind is taken from declared.
+func (d *DB) storedIndexes(name string, declared map[string]string) map[string]string {
+	var stored map[string]string
+	if err := d.node.Get(indexBucket, name, &stored); err == nil {
+		return stored
+	}
+	stored = make(map[string]string)
+	for _, field := range d.indexBuckets(name) {
+		if kind, ok := declared[field]; ok {
+			stored[field] = kind
+		} else {
+			stored[field] = "index"
+		}
+	}
+	return stored
+}
+
+// checkUnique reports the values that more than one stored record has in a
+// field declared unique. Like Storm, zero values are not indexed and never
+// conflict.
+func (d *DB) checkUnique(name string, model any, declared map[string]string) error {
+	m, err := schema.Of(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	records := reflect.New(reflect.SliceOf(m.Type))
+	if err := d.node.All(records.Interface()); err != nil {
+		return fmt.Errorf("dbase/bolt: rebuild %s: %w", name, err)
+	}
+
+	var violations []UniqueViolation
+	for _, field := range slices.Sorted(maps.Keys(declared)) {
+		f := m.Field(field)
+		if declared[field] != "unique" || f == nil || f == m.ID {
+			continue
+		}
+		var order []string
+		groups := make(map[string]*UniqueViolation)
+		for i := range records.Elem().Len() {
+			rec := records.Elem().Index(i)
+			value := f.Value(rec)
+			if value.IsZero() {
+				continue
+			}
+			key := fmt.Sprint(value.Interface())
+			g, ok := groups[key]
+			if !ok {
+				g = &UniqueViolation{Field: field, Value: value.Interface()}
+				groups[key] = g
+				order = append(order, key)
+			}
+			g.IDs = append(g.IDs, m.ID.Interface(rec))
+		}
+		for _, key := range order {
+			if g := groups[key]; len(g.IDs) > 1 {
+				violations = append(violations, *g)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return &UniqueViolationError{Bucket: name, Violations: violations}
+	}
+	return nil
+}
+
+// hasBucket reports whether the bucket name exists below the node.
+func (d *DB) hasBucket(name string) bool {
+	for _, n := range d.node.PrefixScan(name) {
+		if b := n.Bucket(); b[len(b)-1] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// indexBuckets returns the fields that have an index bucket in the bucket
+// of model name, sorted.
+func (d *DB) indexBuckets(name string) []string {
+	var fields []string
+	for _, n := range d.node.From(name).PrefixScan(indexPrefix) {
+		b := n.Bucket()
+		fields = append(fields, strings.TrimPrefix(b[len(b)-1], indexPrefix))
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// stormIndexes returns the bucket name of model and its indexed fields,
+// mapped to "index" or "unique". Like Storm, it treats the ID field as a
+// unique index.
+func stormIndexes(model any) (string, map[string]string, error) {
+	if model == nil {
+		return "", nil, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, schema.ErrNotStruct)
+	}
+	t := schema.ElemType(reflect.TypeOf(model))
+	if t.Kind() != reflect.Struct {
+		return "", nil, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, schema.ErrNotStruct)
+	}
+
+	indexes := make(map[string]string)
+	hasID := false
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() {
+			continue
+		}
+		for _, opt := range strings.Split(f.Tag.Get("storm"), ",") {
+			switch opt {
+			case "id":
+				indexes[f.Name], hasID = "unique", true
+			case "index", "unique":
+				indexes[f.Name] = opt
+			}
+		}
+	}
+	if _, ok := t.FieldByName("ID"); ok && !hasID {
+		indexes["ID"] = "unique"
+	}
+	return t.Name(), indexes, nil
+}
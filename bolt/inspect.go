@@ -0,0 +1,152 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+)
+
+// Tables implements [dbase.Inspector]. It lists the buckets below the node
+// with their record counts and index buckets; buckets used internally by
+// Storm and dbase are skipped.
+func (d *DB) Tables(ctx context.Context) ([]dbase.TableInfo, error) {
+	if !d.gate.Enter() {
+		return nil, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return nil, fmt.Errorf("dbase/bolt: inspect inside a transaction: %w", dbase.ErrNotSupported)
+	}
+	var tables []dbase.TableInfo
+	for _, n := range d.node.PrefixScan("") {
+		b := n.Bucket()
+		name := b[len(b)-1]
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		table := dbase.TableInfo{Name: name}
+		var kinds map[string]string
+		_ = d.node.Get(indexBucket, name, &kinds) // unknown unless migrated by this package
+		for _, field := range d.indexBuckets(name) {
+			table.Indexes = append(table.Indexes, dbase.IndexInfo{Field: field, Kind: kinds[field]})
+		}
+		err := d.records(name, func(_, _ []byte) (bool, error) {
+			table.Count++
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// Records implements [dbase.Inspector]. Records are decoded with the
+// node's codec; values that are not objects, such as those written with
+// Set, are returned as {"_key": key, "_value": value}. All records are
+// scanned, so the query is evaluated in memory.
+func (d *DB) Records(ctx context.Context, table string, query *dbase.Query) ([]map[string]any, error) {
+	if !d.gate.Enter() {
+		return nil, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return nil, fmt.Errorf("dbase/bolt: inspect inside a transaction: %w", dbase.ErrNotSupported)
+	}
+	if query == nil {
+		query = dbase.NewQuery()
+	}
+	// Without ordering the scan can stop once the requested page is full.
+	want := -1
+	if len(query.OrderBy) == 0 && query.Limit > 0 {
+		want = query.Offset + query.Limit
+	}
+
+	var recs []map[string]any
+	err := d.records(table, func(k, v []byte) (bool, error) {
+		rec := d.decode(k, v)
+		ok, err := eval.Match(eval.MapGetter(rec), query.Conditions)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			recs = append(recs, rec)
+		}
+		return want < 0 || len(recs) < want, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	eval.Sort(recs, query.OrderBy, eval.MapGetter)
+	return eval.Page(recs, query.Limit, query.Offset), nil
+}
+
+// CountRecords implements [dbase.Inspector] by scanning the records as
+// Records does, decoding one at a time.
+func (d *DB) CountRecords(ctx context.Context, table string, query *dbase.Query) (int64, error) {
+	if !d.gate.Enter() {
+		return 0, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return 0, fmt.Errorf("dbase/bolt: inspect inside a transaction: %w", dbase.ErrNotSupported)
+	}
+	var conds []dbase.Condition
+	if query != nil {
+		conds = query.Conditions
+	}
+
+	var n int64
+	err := d.records(table, func(k, v []byte) (bool, error) {
+		ok, err := eval.Match(eval.MapGetter(d.decode(k, v)), conds)
+		if ok {
+			n++
+		}
+		return err == nil, err
+	})
+	return n, err
+}
+
+// decode decodes the record v stored under k with the node's codec; see
+// [DB.Records].
+func (d *DB) decode(k, v []byte) map[string]any {
+	var rec map[string]any
+	if err := d.node.Codec().Unmarshal(v, &rec); err != nil || rec == nil {
+		var value any
+		if err := d.node.Codec().Unmarshal(v, &value); err != nil {
+			value = string(v)
+		}
+		rec = map[string]any{"_key": string(k), "_value": value}
+	}
+	return rec
+}
+
+// records calls fn with the key and value of each record in the bucket
+// table until fn returns false.
+func (d *DB) records(table string, fn func(k, v []byte) (bool, error)) error {
+	return d.file.View(func(tx *bbolt.Tx) error {
+		b := d.node.GetBucket(tx, table)
+		if b == nil {
+			return fmt.Errorf("%w: bucket %s", dbase.ErrNotFound, table)
+		}
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				continue // nested bucket
+			}
+			more, err := fn(k, v)
+			if err != nil || !more {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+var _ dbase.Inspector = (*DB)(nil)
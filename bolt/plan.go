@@ -0,0 +1,63 @@
+package bolt
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/nuln/dbase"
+)
+
+// PlanMigrate implements [dbase.MigrationPlanner]. It reports missing model
+// buckets, indexes declared with storm tags but missing from the bucket,
+// index buckets that no tag declares any more, and indexes whose kind
+// changed, which Migrate rebuilds.
+func (d *DB) PlanMigrate(ctx context.Context, models ...any) (*dbase.MigrationPlan, error) {
+	if !d.gate.Enter() {
+		return nil, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	plan := &dbase.MigrationPlan{Driver: d.Driver()}
+	for _, model := range models {
+		name, declared, err := stormIndexes(model)
+		if err != nil {
+			return nil, err
+		}
+		fields := slices.Sorted(maps.Keys(declared))
+
+		if !d.hasBucket(name) {
+			plan.Changes = append(plan.Changes, dbase.SchemaChange{Kind: dbase.ChangeCreateTable, Table: name})
+			for _, field := range fields {
+				plan.Changes = append(plan.Changes, dbase.SchemaChange{
+					Kind: dbase.ChangeCreateIndex, Table: name, Column: field, Index: indexPrefix + field, To: declared[field],
+				})
+			}
+			continue
+		}
+
+		existing := d.indexBuckets(name)
+		stored := d.storedIndexes(name, declared)
+		for _, field := range fields {
+			change := dbase.SchemaChange{
+				Kind: dbase.ChangeCreateIndex, Table: name, Column: field, Index: indexPrefix + field, To: declared[field],
+			}
+			switch {
+			case !slices.Contains(existing, field):
+				plan.Changes = append(plan.Changes, change)
+			case stored[field] != "" && stored[field] != declared[field]:
+				change.From = stored[field]
+				plan.Changes = append(plan.Changes, change)
+			}
+		}
+		for _, field := range existing {
+			if _, ok := declared[field]; !ok {
+				plan.Changes = append(plan.Changes, dbase.SchemaChange{
+					Kind: dbase.ChangeExtraIndex, Table: name, Column: field, Index: indexPrefix + field,
+				})
+			}
+		}
+	}
+	return plan, nil
+}
+
+var _ dbase.MigrationPlanner = (*DB)(nil)
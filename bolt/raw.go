@@ -0,0 +1,33 @@
+package bolt
+
+import (
+	"context"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/nuln/dbase"
+)
+
+// BoltTx calls fn with the bolt transaction of the current scope, the
+// bolt counterpart of [dbase.RawQuerier]. Outside Transaction fn runs in a
+// new transaction, read-write if writable is set and read-only if not,
+// committed when fn returns nil. Inside Transaction fn gets the enclosing
+// read-write transaction and writable has no effect: fn can write even
+// when it is false. Buckets are not scoped to the buckets of views
+// returned by From.
+func (d *DB) BoltTx(ctx context.Context, writable bool, fn func(tx *bbolt.Tx) error) error {
+	if d.tx != nil {
+		return fn(d.tx)
+	}
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if writable {
+		return d.file.Update(fn)
+	}
+	return d.file.View(fn)
+}
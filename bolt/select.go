@@ -0,0 +1,87 @@
+package bolt
+
+import (
+	"reflect"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// project applies query.Fields and query.Unique to the records Storm
+// decoded into results. Storm paginates unless query.Unique is set, in
+// which case Limit and Offset are applied here, after duplicates are gone.
+func project(results any, query *dbase.Query) error {
+	if query == nil || (len(query.Fields) == 0 && !query.Unique) {
+		return nil
+	}
+	slice, isPtr, err := schema.Slice(results)
+	if err != nil {
+		return err
+	}
+	m, err := schema.OfType(slice.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	items := make([]reflect.Value, slice.Len())
+	for i := range items {
+		if isPtr {
+			items[i] = slice.Index(i)
+		} else {
+			items[i] = slice.Index(i).Addr()
+		}
+	}
+	q := *query
+	if !q.Unique {
+		q.Limit, q.Offset = 0, 0
+	}
+	items, err = eval.Select(items, m, &q)
+	if err != nil {
+		return err
+	}
+
+	out := reflect.MakeSlice(slice.Type(), 0, len(items))
+	for _, rec := range items {
+		if isPtr {
+			out = reflect.Append(out, rec)
+		} else {
+			out = reflect.Append(out, rec.Elem())
+		}
+	}
+	slice.Set(out)
+	return nil
+}
+
+// projectOne zeroes the fields of result missing from query.Fields.
+func projectOne(result any, query *dbase.Query) error {
+	if query == nil || len(query.Fields) == 0 {
+		return nil
+	}
+	m, err := schema.Of(result)
+	if err != nil {
+		return err
+	}
+	fields, err := eval.Fields(m, query.Fields)
+	if err != nil {
+		return err
+	}
+	eval.Project(reflect.ValueOf(result), m, fields)
+	return nil
+}
+
+// countDistinct counts the records of model matching query that differ in
+// query.Fields, which Storm cannot do without decoding them.
+func (d *DB) countDistinct(model any, query *dbase.Query) (int64, error) {
+	m, err := schema.Of(model)
+	if err != nil {
+		return 0, err
+	}
+	results := reflect.New(reflect.SliceOf(reflect.PointerTo(m.Type)))
+	q := *query
+	q.Limit, q.Offset, q.OrderBy = 0, 0, nil
+	if err := d.find(results.Interface(), &q); err != nil {
+		return 0, err
+	}
+	return int64(results.Elem().Len()), nil
+}
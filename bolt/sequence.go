@@ -0,0 +1,63 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// metadataBucket is the bucket in which Storm keeps the increment counters
+// of a model bucket, under "<field>counter".
+const metadataBucket = "__storm_metadata"
+
+// SyncSequence implements [dbase.Sequencer]. Storm only moves the increment
+// counter of a model when it assigns an ID, so after explicit IDs the
+// counter is set to the highest stored one.
+func (d *DB) SyncSequence(ctx context.Context, model any) error {
+	m, err := schema.Of(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	if !m.Increment {
+		return nil
+	}
+	return d.BoltTx(ctx, true, func(tx *bbolt.Tx) error {
+		scope := d
+		if d.tx == nil {
+			scope = &DB{node: d.node.WithTransaction(tx), tx: tx}
+		}
+		last := reflect.New(reflect.SliceOf(reflect.PointerTo(m.Type)))
+		if err := scope.find(last.Interface(), dbase.NewQuery().OrderByDesc(m.ID.Name).SetLimit(1)); err != nil {
+			return err
+		}
+		if last.Elem().Len() == 0 {
+			return nil
+		}
+		high, err := schema.Convert(m.ID.Interface(last.Elem().Index(0).Elem()), reflect.TypeFor[int64]())
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", dbase.ErrInvalidModel, m.Name, err)
+		}
+
+		bucket := d.node.GetBucket(tx, m.Name)
+		if bucket == nil || high.Int() <= 0 {
+			return nil
+		}
+		meta := bucket.Bucket([]byte(metadataBucket))
+		if meta == nil {
+			return nil
+		}
+		key := []byte(m.ID.Name + "counter")
+		if raw := meta.Get(key); len(raw) == 8 && int64(binary.BigEndian.Uint64(raw)) >= high.Int() {
+			return nil
+		}
+		return meta.Put(key, binary.BigEndian.AppendUint64(nil, uint64(high.Int())))
+	})
+}
+
+var _ dbase.Sequencer = (*DB)(nil)
@@ -0,0 +1,40 @@
+package bolt
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/nuln/dbase"
+)
+
+// Stats implements [dbase.StatsReporter] with the statistics of the whole
+// bolt file, also for views returned by From.
+func (d *DB) Stats(ctx context.Context) (*dbase.Stats, error) {
+	if !d.gate.Enter() {
+		return nil, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.file == nil {
+		return nil, fmt.Errorf("dbase/bolt: stats inside a transaction: %w", dbase.ErrNotSupported)
+	}
+	fi, err := os.Stat(d.file.Path())
+	if err != nil {
+		return nil, fmt.Errorf("dbase/bolt: stats: %w", err)
+	}
+	s := d.file.Stats()
+	return &dbase.Stats{
+		Driver: "bolt",
+		Bolt: &dbase.BoltStats{
+			FreePages:    s.FreePageN,
+			PendingPages: s.PendingPageN,
+			FreeAlloc:    s.FreeAlloc,
+			OpenTx:       s.OpenTxN,
+			Tx:           s.TxN,
+			PageWrites:   s.TxStats.Write,
+		},
+		Size: fi.Size(),
+	}, nil
+}
+
+var _ dbase.StatsReporter = (*DB)(nil)
@@ -0,0 +1,102 @@
+package dbase
+
+import "slices"
+
+// Capabilities describes which parts of the [Database] contract a driver
+// fully supports, so callers can avoid features that it would otherwise
+// reject or approximate.
+type Capabilities struct {
+	// Operators lists the condition operators that queries evaluate.
+	// Queries with other operators fail with [ErrNotSupported].
+	Operators []Operator `json:"operators"`
+
+	// Or reports whether conditions added with [Query.Or] are honored;
+	// without it queries using them fail with [ErrNotSupported].
+	Or bool `json:"or"`
+
+	// PartialUpdate reports whether UpdateFields writes only the named
+	// fields; without it the whole record is updated.
+	PartialUpdate bool `json:"partial_update"`
+
+	// AtomicTransactions reports whether the writes of a Transaction are
+	// committed all or nothing, even when the process crashes midway.
+	AtomicTransactions bool `json:"atomic_transactions"`
+
+	// NestedTransactions tells what a Transaction call inside another one
+	// does.
+	NestedTransactions Nesting `json:"nested_transactions"`
+
+	// Aggregation reports whether Count and Exists are computed by the
+	// database rather than by loading every matching record.
+	Aggregation bool `json:"aggregation"`
+
+	// Replicas reports whether [Config.Replicas] is supported.
+	Replicas bool `json:"replicas"`
+}
+
+// Nesting is the behavior of nested transactions; see
+// [Capabilities.NestedTransactions].
+type Nesting string
+
+const (
+	// NestedNone means nested calls fail with [ErrNotSupported].
+	NestedNone Nesting = ""
+
+	// NestedJoin means nested calls run in the enclosing transaction, so
+	// an error rolls back only if it is returned by the outermost call.
+	NestedJoin Nesting = "join"
+
+	// NestedSavepoint means nested calls run in a savepoint, so an error
+	// rolls back the nested call alone.
+	NestedSavepoint Nesting = "savepoint"
+)
+
+// AllOperators lists every [Operator].
+var AllOperators = []Operator{
+	OpEqual, OpNotEqual, OpGreater, OpGreaterEqual, OpLess, OpLessEqual,
+	OpIn, OpNotIn, OpLike, OpPrefix, OpIsNull, OpNotNull,
+}
+
+// Supports reports whether queries evaluate op.
+func (c Capabilities) Supports(op Operator) bool {
+	return slices.Contains(c.Operators, op)
+}
+
+// Capable is implemented by databases that describe their [Capabilities].
+type Capable interface {
+	Capabilities() Capabilities
+}
+
+// CapabilitiesOf returns the capabilities of db. Databases that do not
+// implement [Capable] are assumed to support the whole contract, with
+// nested transactions joining the enclosing one.
+func CapabilitiesOf(db Database) Capabilities {
+	if c, ok := db.(Capable); ok {
+		return c.Capabilities()
+	}
+	return Capabilities{
+		Operators:          AllOperators,
+		Or:                 true,
+		PartialUpdate:      true,
+		AtomicTransactions: true,
+		NestedTransactions: NestedJoin,
+	}
+}
+
+// WithCapabilities declares the capabilities of a driver's databases, as
+// reported by [DriverCapabilities] before one is opened.
+func WithCapabilities(c Capabilities) DriverOption {
+	return func(d *driver) {
+		d.capabilities = &c
+	}
+}
+
+// DriverCapabilities returns the capabilities declared by the registered
+// driver name. ok is false if the driver is unknown or declared none.
+func DriverCapabilities(name string) (c Capabilities, ok bool) {
+	info, ok := Lookup(name)
+	if !ok || info.Capabilities == nil {
+		return Capabilities{}, false
+	}
+	return *info.Capabilities, true
+}
@@ -0,0 +1,280 @@
+// Package cli implements the dbase command-line tool.
+//
+// The stock binary in cmd/dbase knows no models, so it offers the commands
+// that work on any database: drivers, ping, backup, stats and shell, which
+// browses bolt files without their Go types. Applications get
+// the model-aware commands (migrate, export, import, copy, count) by
+// building their own binary with their models and migrations:
+//
+//	func main() {
+//	    cli.Main(cli.App{
+//	        Name:       "myapp-db",
+//	        Models:     []any{&User{}, &Order{}},
+//	        Migrations: migrations,
+//	    })
+//	}
+//
+// The database is configured by a URL (-url or DBASE_URL, see
+// [dbase.ParseURL]) or a config file (-config or DBASE_CONFIG), then by
+// environment variables (DBASE_TYPE, DBASE_DSN, DBASE_PATH) and flags
+// (-type, -dsn, -path), each overriding the previous.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+	"github.com/nuln/dbase/migrate"
+)
+
+// App describes a dbase command-line tool.
+type App struct {
+	// Name is the program name used in help output. Defaults to "dbase".
+	Name string
+
+	// Models are the models the model-aware commands work on.
+	Models []any
+
+	// Migrations are run by "migrate". Without migrations, "migrate up"
+	// calls Migrate with Models.
+	Migrations []migrate.Migration
+
+	// Stdin, Stdout and Stderr default to the process's streams.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Getenv looks up environment variables. Defaults to os.Getenv.
+	Getenv func(key string) string
+}
+
+// errUsage reports a command line that could not be parsed; the usage has
+// already been printed.
+var errUsage = errors.New("usage")
+
+// command is a subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	models  bool // needs App.Models
+	noDB    bool // runs without a configured database
+
+	// setup registers the command's flags on fs and returns the function
+	// that runs it once they are parsed.
+	setup func(fs *flag.FlagSet) runFunc
+}
+
+// runFunc runs a command with the configured database and the arguments
+// left after its flags.
+type runFunc func(ctx context.Context, a *App, cfg *dbase.Config, args []string) error
+
+// Main runs app with the process's arguments, cancelling on interrupt, and
+// exits with its status.
+func Main(app App) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := app.Run(ctx, os.Args[1:])
+	stop()
+	os.Exit(code)
+}
+
+// Run runs the command line args and returns the exit status: 0 on success,
+// 1 on failure and 2 for usage errors.
+func (a *App) Run(ctx context.Context, args []string) int {
+	a.defaults()
+	err := a.run(ctx, args)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+		return 2
+	default:
+		fmt.Fprintf(a.Stderr, "%s: %v\n", a.Name, err)
+		return 1
+	}
+}
+
+func (a *App) defaults() {
+	if a.Name == "" {
+		a.Name = "dbase"
+	}
+	if a.Stdin == nil {
+		a.Stdin = os.Stdin
+	}
+	if a.Stdout == nil {
+		a.Stdout = os.Stdout
+	}
+	if a.Stderr == nil {
+		a.Stderr = os.Stderr
+	}
+	if a.Getenv == nil {
+		a.Getenv = os.Getenv
+	}
+}
+
+func (a *App) run(ctx context.Context, args []string) error {
+	global := flag.NewFlagSet(a.Name, flag.ContinueOnError)
+	global.SetOutput(a.Stderr)
+	var src source
+	src.register(global, "", "DBASE_")
+	global.Usage = a.usage(global)
+	if err := global.Parse(args); err != nil {
+		return err
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return errUsage
+	}
+
+	name, rest := global.Arg(0), global.Args()[1:]
+	cmd := a.lookup(name)
+	if cmd == nil {
+		fmt.Fprintf(a.Stderr, "%s: unknown command %q\n", a.Name, name)
+		global.Usage()
+		return errUsage
+	}
+	if cmd.models && len(a.Models) == 0 {
+		return fmt.Errorf("%s needs models; build the tool with cli.App{Models: ...}", cmd.name)
+	}
+
+	fs := flag.NewFlagSet(a.Name+" "+cmd.name, flag.ContinueOnError)
+	fs.SetOutput(a.Stderr)
+	run := cmd.setup(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(a.Stderr, "Usage: %s [flags] %s [command flags] %s\n\n%s.\n\n",
+			a.Name, cmd.name, cmd.args, cmd.summary)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+
+	var cfg *dbase.Config
+	if !cmd.noDB {
+		c, err := src.config(a.Getenv)
+		if err != nil {
+			return err
+		}
+		cfg = c
+	}
+	return run(ctx, a, cfg, fs.Args())
+}
+
+func (*App) lookup(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+func (a *App) usage(global *flag.FlagSet) func() {
+	return func() {
+		fmt.Fprintf(a.Stderr, "Usage: %s [flags] <command> [command flags] [args]\n\nCommands:\n", a.Name)
+		for _, cmd := range commands {
+			fmt.Fprintf(a.Stderr, "  %-9s %s\n", cmd.name, cmd.summary)
+		}
+		fmt.Fprintf(a.Stderr, "\nFlags:\n")
+		global.PrintDefaults()
+	}
+}
+
+// open opens cfg and closes the database when fn returns.
+func open(cfg *dbase.Config, fn func(db dbase.Database) error) (err error) {
+	db, err := dbase.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := db.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	return fn(db)
+}
+
+// models returns the models named in names, or all models when names is
+// empty.
+func (a *App) models(names []string) ([]any, error) {
+	if len(names) == 0 {
+		return a.Models, nil
+	}
+	byName := make(map[string]any, len(a.Models))
+	known := make([]string, 0, len(a.Models))
+	for _, model := range a.Models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+		}
+		byName[strings.ToLower(m.Name)] = model
+		known = append(known, m.Name)
+	}
+	models := make([]any, 0, len(names))
+	for _, name := range names {
+		model, ok := byName[strings.ToLower(name)]
+		if !ok {
+			sort.Strings(known)
+			return nil, fmt.Errorf("unknown model %q (known: %s)", name, strings.Join(known, ", "))
+		}
+		models = append(models, model)
+	}
+	return models, nil
+}
+
+// source collects the flags and environment variables that configure a
+// database. The target of "copy" uses the prefixes "to-" and "DBASE_TO_".
+type source struct {
+	prefix, env               string
+	file, url, typ, dsn, path string
+}
+
+func (s *source) register(fs *flag.FlagSet, prefix, env string) {
+	s.prefix, s.env = prefix, env
+	fs.StringVar(&s.file, prefix+"config", "", "config file (YAML or JSON); env "+env+"CONFIG")
+	fs.StringVar(&s.url, prefix+"url", "", "database URL, e.g. postgres://host/app; env "+env+"URL")
+	fs.StringVar(&s.typ, prefix+"type", "", "driver name, e.g. sqlite, postgres, bolt; env "+env+"TYPE")
+	fs.StringVar(&s.dsn, prefix+"dsn", "", "data source name for SQL and Redis drivers; env "+env+"DSN")
+	fs.StringVar(&s.path, prefix+"path", "", "file path for file-based drivers; env "+env+"PATH")
+}
+
+// config loads the configuration with [dbase.LoadConfig] from the config
+// file and the environment, with the flags taking the place of the
+// environment variables of the same name.
+func (s *source) config(getenv func(string) string) (*dbase.Config, error) {
+	flags := map[string]string{"URL": s.url, "TYPE": s.typ, "DSN": s.dsn, "PATH": s.path}
+	cfg, err := dbase.LoadConfig(first(s.file, getenv(s.env+"CONFIG")), dbase.LoadOptions{
+		EnvPrefix: s.env,
+		Getenv: func(key string) string {
+			if name, ok := strings.CutPrefix(key, s.env); ok && flags[name] != "" {
+				return flags[name]
+			}
+			return getenv(key)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Type == "" {
+		return nil, fmt.Errorf("no driver configured; set -%stype, %sTYPE or a config file", s.prefix, s.env)
+	}
+	return cfg, nil
+}
+
+func first(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
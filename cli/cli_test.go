@@ -0,0 +1,176 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/cli"
+	_ "github.com/nuln/dbase/drivers"
+	"github.com/nuln/dbase/migrate"
+)
+
+type Book struct {
+	ID    int `gorm:"primaryKey" storm:"id"`
+	Title string
+}
+
+// run runs app with args and returns the exit status and output.
+func run(app cli.App, env map[string]string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	app.Stdout, app.Stderr = &stdout, &stderr
+	app.Getenv = func(key string) string { return env[key] }
+	code := app.Run(context.Background(), args)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLI(t *testing.T) {
+	dir := t.TempDir()
+	boltPath := filepath.Join(dir, "app.db")
+	app := cli.App{Models: []any{&Book{}}}
+
+	code, out, _ := run(cli.App{}, nil, "drivers")
+	require.Equal(t, 0, code)
+	assert.Contains(t, strings.Fields(out), "bolt")
+
+	code, _, errOut := run(cli.App{}, nil, "ping")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "no driver configured")
+
+	code, _, _ = run(cli.App{}, nil, "frobnicate")
+	assert.Equal(t, 2, code)
+
+	code, _, errOut = run(cli.App{}, nil, "-type", "bolt", "-path", boltPath, "count")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "needs models")
+
+	env := map[string]string{"DBASE_TYPE": "bolt", "DBASE_PATH": boltPath}
+	code, out, _ = run(app, env, "ping")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "bolt: ok\n", out)
+
+	code, _, _ = run(app, env, "migrate", "up")
+	require.Equal(t, 0, code)
+	db := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: boltPath})
+	for i, title := range []string{"Dune", "Emma"} {
+		require.NoError(t, db.Create(context.Background(), &Book{ID: i + 1, Title: title}))
+	}
+	require.NoError(t, db.Close())
+
+	code, out, _ = run(app, map[string]string{"DBASE_URL": "bolt://" + boltPath}, "count", "book")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "Book  2\n", out)
+
+	code, out, _ = run(app, env, "stats", "-json")
+	require.Equal(t, 0, code)
+	var stats dbase.Stats
+	require.NoError(t, json.Unmarshal([]byte(out), &stats))
+	assert.Equal(t, "bolt", stats.Driver)
+	assert.Positive(t, stats.Size)
+	assert.Equal(t, map[string]int64{"Book": 2}, stats.Records)
+
+	exported := filepath.Join(dir, "books.ndjson")
+	code, _, _ = run(app, env, "export", "-o", exported)
+	require.Equal(t, 0, code)
+
+	// A config file for the SQLite target, overridden by a flag.
+	sqlitePath := filepath.Join(dir, "app.sqlite")
+	config := filepath.Join(dir, "db.yaml")
+	require.NoError(t, os.WriteFile(config, []byte("type: sqlite\npath: ignored.sqlite\n"), 0o600))
+	code, out, errOut = run(app, env, "copy", "-to-config", config, "-to-path", sqlitePath)
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, "Book: copied 2, skipped 0, 2 in target\n", out)
+
+	imported := filepath.Join(dir, "imported.db")
+	code, _, errOut = run(app, nil, "-type", "bolt", "-path", imported, "import", "-i", exported)
+	require.Equal(t, 0, code, errOut)
+	code, out, _ = run(app, nil, "-type", "bolt", "-path", imported, "count")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "Book  2\n", out)
+
+	backup := filepath.Join(dir, "app.bak")
+	code, _, _ = run(app, map[string]string{"DBASE_CONFIG": config}, "-path", sqlitePath, "backup", "-o", backup)
+	require.Equal(t, 0, code)
+	assert.FileExists(t, backup)
+
+	code, _, errOut = run(app, env, "count", "Author")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, `unknown model "Author"`)
+}
+
+func TestCLIMigrations(t *testing.T) {
+	app := cli.App{
+		Models: []any{&Book{}},
+		Migrations: []migrate.Migration{{
+			ID:   "0001",
+			Name: "books",
+			Up: func(ctx context.Context, db dbase.Database) error {
+				return db.Migrate(ctx, &Book{})
+			},
+			Down: func(context.Context, dbase.Database) error { return nil },
+		}},
+	}
+	env := map[string]string{"DBASE_TYPE": "bolt", "DBASE_PATH": filepath.Join(t.TempDir(), "app.db")}
+
+	code, out, _ := run(app, env, "migrate", "status")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "0001  books  pending")
+
+	code, _, _ = run(app, env, "migrate", "up")
+	require.Equal(t, 0, code)
+	code, out, _ = run(app, env, "migrate", "status")
+	require.Equal(t, 0, code)
+	assert.NotContains(t, out, "pending")
+
+	code, _, _ = run(app, env, "migrate", "down", "-n", "1")
+	assert.Equal(t, 2, code, "flags come before the action")
+	code, _, _ = run(app, env, "migrate", "-n", "1", "down")
+	require.Equal(t, 0, code)
+	code, out, _ = run(app, env, "migrate", "status")
+	require.Equal(t, 0, code)
+	assert.Contains(t, out, "pending")
+}
+
+func TestCLIShell(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app.db")
+	db := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: path})
+	require.NoError(t, db.Migrate(ctx, &Book{}))
+	for i, title := range []string{"Dune", "Emma", "Ulysses"} {
+		require.NoError(t, db.Create(ctx, &Book{ID: i + 1, Title: title}))
+	}
+	require.NoError(t, db.Close())
+	env := map[string]string{"DBASE_TYPE": "bolt", "DBASE_PATH": path}
+
+	// The stock tool reads bolt buckets without the model types.
+	code, out, errOut := run(cli.App{}, env, "shell", "-page", "2", "-c",
+		"tables; find Book order by ID desc; next; count Book Title prefix 'E'")
+	require.Equal(t, 0, code, errOut)
+	assert.Equal(t, strings.Join([]string{
+		"TABLE  RECORDS  INDEXES",
+		"Book   3        ID (unique)",
+		`{"ID":3,"Title":"Ulysses"}`,
+		`{"ID":2,"Title":"Emma"}`,
+		`{"ID":1,"Title":"Dune"}`,
+		"1",
+		"",
+	}, "\n"), out)
+
+	// Interactive input reports errors and keeps going.
+	input := "find Book Title = Emma\nfind Book Title ~\ncount Book Title prefix 'E'\nbogus\nquit\n"
+	app := cli.App{Models: []any{&Book{}}, Stdin: strings.NewReader(input)}
+	code, out, errOut = run(app, env, "shell")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "{\"ID\":2,\"Title\":\"Emma\"}\n", out)
+	assert.Contains(t, errOut, "expected an operator")
+	assert.Contains(t, errOut, "bolt has no prefix conditions", "the model path must not drop conditions")
+	assert.Contains(t, errOut, `unknown command "bogus"`)
+}
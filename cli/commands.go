@@ -0,0 +1,262 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/atomicfile"
+	"github.com/nuln/dbase/internal/schema"
+	"github.com/nuln/dbase/migrate"
+)
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: "drivers", summary: "List the registered drivers", noDB: true, setup: drivers},
+		{name: "ping", summary: "Check that the database is reachable", setup: ping},
+		{
+			name: "migrate", args: "up|down|status", summary: "Apply, revert or list migrations",
+			models: true, setup: migrateCmd,
+		},
+		{name: "export", args: "[model...]", summary: "Write records as NDJSON", models: true, setup: export},
+		{name: "import", summary: "Read records written by export", models: true, setup: importCmd},
+		{name: "copy", args: "[model...]", summary: "Copy records to another database", models: true, setup: copyCmd},
+		{name: "backup", summary: "Write a native snapshot of the database", setup: backup},
+		{name: "count", args: "[model...]", summary: "Count the records of each model", models: true, setup: count},
+		{name: "stats", args: "[model...]", summary: "Show pool, storage and record statistics", setup: stats},
+		{name: "shell", summary: "Browse tables and records interactively", setup: shell},
+	}
+}
+
+func drivers(*flag.FlagSet) runFunc {
+	return func(_ context.Context, a *App, _ *dbase.Config, _ []string) error {
+		w := tabwriter.NewWriter(a.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "DRIVER\tALIASES\tDESCRIPTION")
+		for _, name := range dbase.Drivers() {
+			info, _ := dbase.Lookup(name)
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, strings.Join(info.Aliases, ", "), info.Description)
+		}
+		return w.Flush()
+	}
+}
+
+func ping(*flag.FlagSet) runFunc {
+	return func(ctx context.Context, a *App, cfg *dbase.Config, _ []string) error {
+		return open(cfg, func(db dbase.Database) error {
+			if err := db.Ping(ctx); err != nil {
+				return err
+			}
+			fmt.Fprintf(a.Stdout, "%s: ok\n", db.Driver())
+			return nil
+		})
+	}
+}
+
+func migrateCmd(fs *flag.FlagSet) runFunc {
+	n := fs.Int("n", 1, "number of migrations to revert with down")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, args []string) error {
+		if len(args) != 1 {
+			fs.Usage()
+			return errUsage
+		}
+		action := args[0]
+		if action != "up" && action != "down" && action != "status" {
+			fs.Usage()
+			return errUsage
+		}
+		return open(cfg, func(db dbase.Database) error {
+			if len(a.Migrations) == 0 {
+				if action != "up" {
+					return fmt.Errorf("migrate %s needs migrations; build the tool with cli.App{Migrations: ...}", action)
+				}
+				return db.Migrate(ctx, a.Models...)
+			}
+
+			m, err := migrate.New(db, migrate.Options{}, a.Migrations...)
+			if err != nil {
+				return err
+			}
+			switch action {
+			case "up":
+				return m.Up(ctx)
+			case "down":
+				return m.Down(ctx, *n)
+			}
+			statuses, err := m.Status(ctx)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(a.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tAPPLIED")
+			for _, st := range statuses {
+				applied := "pending"
+				if st.Applied {
+					applied = st.AppliedAt.Local().Format(time.DateTime)
+				}
+				if st.Unknown {
+					applied += " (unknown)"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", st.ID, st.Name, applied)
+			}
+			return w.Flush()
+		})
+	}
+}
+
+func export(fs *flag.FlagSet) runFunc {
+	out := fs.String("o", "", "output file (default stdout)")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, args []string) error {
+		models, err := a.models(args)
+		if err != nil {
+			return err
+		}
+		return open(cfg, func(db dbase.Database) error {
+			return output(a, *out, func(w io.Writer) error {
+				return dbase.Export(ctx, db, w, models...)
+			})
+		})
+	}
+}
+
+func importCmd(fs *flag.FlagSet) runFunc {
+	in := fs.String("i", "", "input file (default stdin)")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, _ []string) error {
+		r := a.Stdin
+		if *in != "" {
+			f, err := os.Open(*in)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = f.Close() }()
+			r = f
+		}
+		return open(cfg, func(db dbase.Database) error {
+			return dbase.Import(ctx, db, r, a.Models...)
+		})
+	}
+}
+
+func copyCmd(fs *flag.FlagSet) runFunc {
+	var target source
+	target.register(fs, "to-", "DBASE_TO_")
+	var opts dbase.CopyOptions
+	fs.IntVar(&opts.BatchSize, "batch", 500, "records per batch")
+	conflict := fs.String("on-conflict", string(dbase.ConflictError), "existing records: error, skip or overwrite")
+	fs.BoolVar(&opts.Resume, "resume", false, "continue after the highest ID already in the target")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, args []string) error {
+		opts.OnConflict = dbase.ConflictPolicy(*conflict)
+		models, err := a.models(args)
+		if err != nil {
+			return err
+		}
+		dstCfg, err := target.config(a.Getenv)
+		if err != nil {
+			return err
+		}
+		return open(cfg, func(src dbase.Database) error {
+			return open(dstCfg, func(dst dbase.Database) error {
+				stats, err := dbase.Copy(ctx, src, dst, opts, models...)
+				for _, st := range stats {
+					fmt.Fprintf(a.Stdout, "%s: copied %d, skipped %d, %d in target\n",
+						st.Model, st.Copied, st.Skipped, st.Target)
+				}
+				return err
+			})
+		})
+	}
+}
+
+func backup(fs *flag.FlagSet) runFunc {
+	out := fs.String("o", "", "output file (default stdout)")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, _ []string) error {
+		return open(cfg, func(db dbase.Database) error {
+			if *out != "" {
+				return dbase.BackupTo(ctx, db, *out)
+			}
+			return dbase.Backup(ctx, db, a.Stdout)
+		})
+	}
+}
+
+func count(*flag.FlagSet) runFunc {
+	return func(ctx context.Context, a *App, cfg *dbase.Config, args []string) error {
+		models, err := a.models(args)
+		if err != nil {
+			return err
+		}
+		return open(cfg, func(db dbase.Database) error {
+			w := tabwriter.NewWriter(a.Stdout, 0, 4, 2, ' ', 0)
+			for _, model := range models {
+				m, err := schema.Of(model)
+				if err != nil {
+					return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+				}
+				n, err := db.Count(ctx, model, nil)
+				if err != nil {
+					return fmt.Errorf("count %s: %w", m.Name, err)
+				}
+				fmt.Fprintf(w, "%s\t%d\n", m.Name, n)
+			}
+			return w.Flush()
+		})
+	}
+}
+
+func stats(fs *flag.FlagSet) runFunc {
+	asJSON := fs.Bool("json", false, "write the statistics as JSON")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, args []string) error {
+		models, err := a.models(args)
+		if err != nil {
+			return err
+		}
+		return open(cfg, func(db dbase.Database) error {
+			s, err := dbase.StatsOf(ctx, db, models...)
+			if err != nil {
+				return err
+			}
+			if *asJSON {
+				enc := json.NewEncoder(a.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(s)
+			}
+
+			w := tabwriter.NewWriter(a.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(w, "driver\t%s\n", s.Driver)
+			if s.Size > 0 {
+				fmt.Fprintf(w, "size\t%d bytes\n", s.Size)
+			}
+			if p := s.Pool; p != nil {
+				fmt.Fprintf(w, "connections\t%d open, %d in use, %d idle, max %d\n", p.Open, p.InUse, p.Idle, p.MaxOpen)
+				fmt.Fprintf(w, "waits\t%d, %s\n", p.WaitCount, p.WaitDuration)
+			}
+			if b := s.Bolt; b != nil {
+				fmt.Fprintf(w, "pages\t%d free, %d pending\n", b.FreePages, b.PendingPages)
+				fmt.Fprintf(w, "transactions\t%d open, %d read\n", b.OpenTx, b.Tx)
+			}
+			for _, name := range slices.Sorted(maps.Keys(s.Records)) {
+				fmt.Fprintf(w, "records %s\t%d\n", name, s.Records[name])
+			}
+			return w.Flush()
+		})
+	}
+}
+
+// output runs fn with the file named out, replaced atomically, or with
+// stdout when out is empty.
+func output(a *App, out string, fn func(w io.Writer) error) error {
+	if out == "" {
+		return fn(a.Stdout)
+	}
+	return atomicfile.Write(out, fn)
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+const shellHelp = `Commands:
+  tables                  list tables with record counts and indexes
+  find <table> [filter]   print matching records as JSON, a page at a time
+  next                    print the next page of the last find
+  count <table> [filter]  count matching records
+  help                    show this help
+  quit                    leave the shell
+
+Filters use the syntax of dbase.ParseQuery, e.g.
+  find Book Year >= 1960 and Title like 'D%' order by Year desc limit 5
+`
+
+func shell(fs *flag.FlagSet) runFunc {
+	script := fs.String("c", "", "run the given shell commands, separated by ';', and exit")
+	page := fs.Int("page", 20, "records per page when the filter has no limit")
+	return func(ctx context.Context, a *App, cfg *dbase.Config, _ []string) error {
+		return open(cfg, func(db dbase.Database) error {
+			s := &session{app: a, db: db, page: *page, models: make(map[string]any)}
+			s.inspector, _ = dbase.Inspect(db)
+			for _, model := range a.Models {
+				m, err := schema.Of(model)
+				if err != nil {
+					return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+				}
+				s.models[strings.ToLower(m.Name)] = model
+			}
+			if *script != "" {
+				for _, line := range strings.Split(*script, ";") {
+					if err := s.exec(ctx, line); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			return s.repl(ctx)
+		})
+	}
+}
+
+// session is the state of a shell. Tables named like one of the app's
+// models are read through the model; other tables are read through the
+// driver's [dbase.Inspector], if any.
+type session struct {
+	app       *App
+	db        dbase.Database
+	inspector dbase.Inspector
+	models    map[string]any // by lower-case name
+	page      int
+
+	// last is the table and query of the last find, for next.
+	lastTable string
+	lastQuery *dbase.Query
+}
+
+var errQuit = errors.New("quit")
+
+func (s *session) repl(ctx context.Context) error {
+	interactive := isTerminal(s.app.Stdin)
+	if interactive {
+		fmt.Fprintf(s.app.Stdout, "Connected to %s. Type \"help\" for help.\n", s.db.Driver())
+	}
+	in := bufio.NewScanner(s.app.Stdin)
+	for {
+		if interactive {
+			fmt.Fprint(s.app.Stdout, "dbase> ")
+		}
+		if !in.Scan() {
+			return in.Err()
+		}
+		err := s.exec(ctx, in.Text())
+		switch {
+		case errors.Is(err, errQuit):
+			return nil
+		case err != nil && ctx.Err() != nil:
+			return err
+		case err != nil:
+			fmt.Fprintf(s.app.Stderr, "error: %v\n", err)
+		}
+	}
+}
+
+func (s *session) exec(ctx context.Context, line string) error {
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch strings.ToLower(strings.TrimPrefix(cmd, ".")) {
+	case "":
+		return nil
+	case "quit", "exit":
+		return errQuit
+	case "help":
+		fmt.Fprint(s.app.Stdout, shellHelp)
+		return nil
+	case "tables":
+		return s.tables(ctx)
+	case "find":
+		table, q, err := s.parse(rest)
+		if err != nil {
+			return err
+		}
+		if q.Limit == 0 {
+			q.Limit = s.page
+		}
+		s.lastTable, s.lastQuery = table, q
+		return s.find(ctx, table, q)
+	case "next":
+		if s.lastQuery == nil {
+			return fmt.Errorf("no find to continue")
+		}
+		s.lastQuery.Offset += s.lastQuery.Limit
+		return s.find(ctx, s.lastTable, s.lastQuery)
+	case "count":
+		table, q, err := s.parse(rest)
+		if err != nil {
+			return err
+		}
+		n, err := s.count(ctx, table, q)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.app.Stdout, n)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q; type \"help\" for help", cmd)
+}
+
+// parse splits "<table> [filter]".
+func (s *session) parse(args string) (string, *dbase.Query, error) {
+	table, filter, _ := strings.Cut(strings.TrimSpace(args), " ")
+	if table == "" {
+		return "", nil, fmt.Errorf("missing table name")
+	}
+	q, err := dbase.ParseQuery(filter)
+	return table, q, err
+}
+
+func (s *session) tables(ctx context.Context) error {
+	var tables []dbase.TableInfo
+	if s.inspector != nil {
+		var err error
+		if tables, err = s.inspector.Tables(ctx); err != nil {
+			return err
+		}
+	} else {
+		for _, model := range s.app.Models {
+			m, _ := schema.Of(model)
+			n, err := s.db.Count(ctx, model, nil)
+			if err != nil {
+				return err
+			}
+			table := dbase.TableInfo{Name: m.Name, Count: n}
+			for _, f := range m.Indexes {
+				kind := "index"
+				if f.Unique {
+					kind = "unique"
+				}
+				table.Indexes = append(table.Indexes, dbase.IndexInfo{Field: f.Name, Kind: kind})
+			}
+			tables = append(tables, table)
+		}
+	}
+
+	w := tabwriter.NewWriter(s.app.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tRECORDS\tINDEXES")
+	for _, t := range tables {
+		indexes := make([]string, 0, len(t.Indexes))
+		for _, idx := range t.Indexes {
+			if idx.Kind != "" {
+				indexes = append(indexes, idx.Field+" ("+idx.Kind+")")
+			} else {
+				indexes = append(indexes, idx.Field)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", t.Name, t.Count, strings.Join(indexes, ", "))
+	}
+	return w.Flush()
+}
+
+func (s *session) find(ctx context.Context, table string, q *dbase.Query) error {
+	enc := json.NewEncoder(s.app.Stdout)
+	if model, ok := s.models[strings.ToLower(table)]; ok {
+		if err := s.supported(q); err != nil {
+			return err
+		}
+		results := reflect.New(reflect.SliceOf(reflect.TypeOf(model)))
+		if err := s.db.Find(ctx, results.Interface(), q); err != nil {
+			return err
+		}
+		for i := range results.Elem().Len() {
+			if err := enc.Encode(results.Elem().Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if s.inspector == nil {
+		return fmt.Errorf("unknown table %q", table)
+	}
+	recs, err := s.inspector.Records(ctx, table, q)
+	if err != nil {
+		return err
+	}
+	for _, rec := range recs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *session) count(ctx context.Context, table string, q *dbase.Query) (int64, error) {
+	if model, ok := s.models[strings.ToLower(table)]; ok {
+		if err := s.supported(q); err != nil {
+			return 0, err
+		}
+		return s.db.Count(ctx, model, q)
+	}
+	if s.inspector == nil {
+		return 0, fmt.Errorf("unknown table %q", table)
+	}
+	return s.inspector.CountRecords(ctx, table, q)
+}
+
+// supported fails with [dbase.ErrNotSupported] when q has conditions the
+// driver does not declare in its capabilities, rather than printing results
+// of a query that it may have answered without them. The inspector
+// evaluates all conditions itself.
+func (s *session) supported(q *dbase.Query) error {
+	caps := dbase.CapabilitiesOf(s.db)
+	for _, c := range q.Conditions {
+		if c.Or && !caps.Or {
+			return fmt.Errorf("%w: %s has no OR conditions", dbase.ErrNotSupported, s.db.Driver())
+		}
+		if !caps.Supports(c.Operator) {
+			return fmt.Errorf("%w: %s has no %s conditions", dbase.ErrNotSupported, s.db.Driver(), c.Operator)
+		}
+	}
+	return nil
+}
+
+// isTerminal reports whether r is an interactive terminal.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
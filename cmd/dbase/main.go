@@ -0,0 +1,17 @@
+// Command dbase inspects and maintains databases of every built-in driver.
+//
+//	dbase -type sqlite -path app.db ping
+//	dbase -config db.yaml backup -o app.bak
+//
+// It knows no models; see package cli for building a tool that also
+// migrates, exports, imports, copies and counts an application's models.
+package main
+
+import (
+	"github.com/nuln/dbase/cli"
+	_ "github.com/nuln/dbase/drivers"
+)
+
+func main() {
+	cli.Main(cli.App{})
+}
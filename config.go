@@ -1,8 +1,7 @@
 package dbase
 
 import (
-	"fmt"
-	"sync"
+	"context"
 	"time"
 )
 
@@ -17,9 +16,26 @@ type Config struct {
 	// DSN is the data source name for SQL databases (PostgreSQL, MySQL).
 	DSN string `json:"dsn,omitempty" yaml:"dsn,omitempty"`
 
+	// Replicas lists read replicas, given in the same form as DSN (or Path
+	// for SQLite). Reads outside transactions are spread across them while
+	// writes go to the primary. Only SQL drivers support replicas.
+	Replicas []string `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+
+	// ReplicaPolicy selects how reads pick a replica: [ReplicaRandom]
+	// (default) or [ReplicaRoundRobin].
+	ReplicaPolicy string `json:"replica_policy,omitempty" yaml:"replica_policy,omitempty"`
+
 	// Pool holds connection pool settings (only applicable to SQL databases).
 	Pool *PoolConfig `json:"pool,omitempty" yaml:"pool,omitempty"`
 
+	// Retry makes Open retry a driver that fails to connect, e.g. while
+	// the database server is still starting. Nil means a single attempt.
+	Retry *RetryConfig `json:"retry,omitempty" yaml:"retry,omitempty"`
+
+	// Lazy makes Open return without connecting. The driver is opened,
+	// with the attempts set by Retry, by the first operation or Ping.
+	Lazy bool `json:"lazy,omitempty" yaml:"lazy,omitempty"`
+
 	// Options holds driver-specific configuration.
 	Options map[string]any `json:"options,omitempty" yaml:"options,omitempty"`
 }
@@ -39,54 +55,22 @@ type PoolConfig struct {
 	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time,omitempty" yaml:"conn_max_idle_time,omitempty"`
 }
 
-// Factory is a function that creates a [Database] from a [Config].
-type Factory func(cfg *Config) (Database, error)
-
-var (
-	mu        sync.RWMutex
-	factories = make(map[string]Factory)
-)
-
-// Register makes a database driver available by the provided name.
-// This is typically called from the driver package's init() function.
-// It panics if called twice with the same name.
-func Register(name string, factory Factory) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	if _, exists := factories[name]; exists {
-		panic(fmt.Sprintf("dbase: driver %q already registered", name))
-	}
-	factories[name] = factory
-}
-
-// Drivers returns a list of all registered driver names.
-func Drivers() []string {
-	mu.RLock()
-	defer mu.RUnlock()
-
-	names := make([]string, 0, len(factories))
-	for name := range factories {
-		names = append(names, name)
-	}
-	return names
+// Validate checks cfg against its driver in the default [Registry]; see
+// [Registry.Validate].
+func (cfg *Config) Validate() error {
+	return defaultRegistry.Validate(cfg)
 }
 
-// Open creates a new [Database] using the registered driver specified in cfg.Type.
+// Open creates a new [Database] using the driver specified in cfg.Type,
+// after checking cfg with [Config.Validate]; see [Registry.OpenContext].
 func Open(cfg *Config) (Database, error) {
-	if cfg == nil {
-		return nil, fmt.Errorf("dbase: config must not be nil")
-	}
-
-	mu.RLock()
-	factory, ok := factories[cfg.Type]
-	mu.RUnlock()
-
-	if !ok {
-		return nil, fmt.Errorf("dbase: unknown driver %q (forgotten import?)", cfg.Type)
-	}
+	return defaultRegistry.Open(cfg)
+}
 
-	return factory(cfg)
+// OpenContext is like [Open], but stops retrying (see [Config.Retry]) when
+// ctx is done.
+func OpenContext(ctx context.Context, cfg *Config) (Database, error) {
+	return defaultRegistry.OpenContext(ctx, cfg)
 }
 
 // MustOpen is like [Open] but panics on error.
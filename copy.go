@@ -0,0 +1,223 @@
+package dbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// ErrCountMismatch is returned by [Copy] when the target does not hold as
+// many records as the source after copying.
+var ErrCountMismatch = errors.New("dbase: record count mismatch")
+
+// ConflictPolicy tells [Copy] what to do with a record whose ID already
+// exists in the target.
+type ConflictPolicy string
+
+const (
+	// ConflictError stops the copy with [ErrAlreadyExists]. It is the default.
+	ConflictError ConflictPolicy = "error"
+
+	// ConflictSkip keeps the target record.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictOverwrite replaces the target record with the source record.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+)
+
+// CopyOptions configures [Copy].
+type CopyOptions struct {
+	// BatchSize is the number of records read and written per transaction.
+	// Defaults to 500.
+	BatchSize int
+
+	// OnConflict decides how existing target records are handled.
+	// Defaults to [ConflictError].
+	OnConflict ConflictPolicy
+
+	// Resume continues an interrupted copy after the highest ID already in
+	// the target instead of starting from the first record. Records are
+	// copied in ID order and each batch is committed as a whole, so the
+	// target holds a prefix of the source.
+	Resume bool
+
+	// Progress, if set, is called after each committed batch.
+	Progress func(stats CopyStats)
+}
+
+// CopyStats reports the progress of copying one model.
+type CopyStats struct {
+	// Model is the model name.
+	Model string
+
+	// Copied is the number of records written to the target.
+	Copied int64
+
+	// Skipped is the number of records left alone under [ConflictSkip].
+	Skipped int64
+
+	// Source and Target are the record counts after copying. They are only
+	// set once the model is done.
+	Source int64
+	Target int64
+}
+
+// Copy streams all records of each model from src to dst, keeping their
+// IDs. The target is migrated first. Records are read in ID order in
+// batches, each written in one transaction of dst, and the counts of both
+// sides are compared at the end; a difference is reported as
+// [ErrCountMismatch].
+//
+//	stats, err := dbase.Copy(ctx, boltDB, pgDB, dbase.CopyOptions{Resume: true}, &User{}, &Order{})
+//
+// Hooks of the models run on dst as for any other write. Once a model is
+// copied, its ID sequence in dst is moved past the copied IDs with
+// [SyncSequence], so that later creates do not reuse them.
+func Copy(ctx context.Context, src, dst Database, opts CopyOptions, models ...any) ([]CopyStats, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = ConflictError
+	case ConflictError, ConflictSkip, ConflictOverwrite:
+	default:
+		return nil, fmt.Errorf("dbase: copy: unknown conflict policy %q", opts.OnConflict)
+	}
+
+	all := make([]CopyStats, 0, len(models))
+	for _, model := range models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return all, fmt.Errorf("%w: %v", ErrInvalidModel, err)
+		}
+		stats, err := copyModel(ctx, src, dst, opts, m)
+		all = append(all, stats)
+		if err != nil {
+			return all, fmt.Errorf("dbase: copy %s: %w", m.Name, err)
+		}
+	}
+	return all, nil
+}
+
+func copyModel(ctx context.Context, src, dst Database, opts CopyOptions, m *schema.Model) (CopyStats, error) {
+	stats := CopyStats{Model: m.Name}
+	model := m.New().Interface()
+	if err := dst.Migrate(ctx, model); err != nil {
+		return stats, err
+	}
+
+	var after any
+	if opts.Resume {
+		last := reflect.New(reflect.SliceOf(reflect.PointerTo(m.Type)))
+		if err := dst.Find(ctx, last.Interface(), NewQuery().OrderByDesc(m.ID.Name).SetLimit(1)); err != nil {
+			return stats, err
+		}
+		if last.Elem().Len() > 0 {
+			after = m.ID.Interface(last.Elem().Index(0).Elem())
+		}
+	}
+
+	err := batches(ctx, src, m, opts.BatchSize, after, func(records reflect.Value) error {
+		var copied, skipped int64
+		err := dst.Transaction(ctx, func(tx Database) error {
+			copied, skipped = 0, 0
+			for i := range records.Len() {
+				ok, err := copyRecord(ctx, tx, opts.OnConflict, m, records.Index(i))
+				if err != nil {
+					return err
+				}
+				if ok {
+					copied++
+				} else {
+					skipped++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		stats.Copied += copied
+		stats.Skipped += skipped
+		if opts.Progress != nil {
+			opts.Progress(stats)
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	if err := SyncSequence(ctx, dst, model); err != nil && !errors.Is(err, ErrNotSupported) {
+		return stats, err
+	}
+
+	if stats.Source, err = src.Count(ctx, model, nil); err != nil {
+		return stats, err
+	}
+	if stats.Target, err = dst.Count(ctx, model, nil); err != nil {
+		return stats, err
+	}
+	if stats.Source != stats.Target {
+		return stats, fmt.Errorf("%w: source has %d, target has %d", ErrCountMismatch, stats.Source, stats.Target)
+	}
+	return stats, nil
+}
+
+// batches reads the records of m from db in ID order, size at a time,
+// starting after the ID after (nil for the first record), and calls fn with
+// each batch as a slice of struct pointers.
+func batches(
+	ctx context.Context, db Database, m *schema.Model, size int, after any, fn func(records reflect.Value) error,
+) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		q := NewQuery()
+		if after != nil {
+			q = Gt(m.ID.Name, after)
+		}
+		q = q.OrderByAsc(m.ID.Name).SetLimit(size)
+		batch := reflect.New(reflect.SliceOf(reflect.PointerTo(m.Type)))
+		if err := db.Find(ctx, batch.Interface(), q); err != nil {
+			return err
+		}
+		records := batch.Elem()
+		if records.Len() == 0 {
+			return nil
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+		if records.Len() < size {
+			return nil
+		}
+		after = m.ID.Interface(records.Index(records.Len() - 1).Elem())
+	}
+}
+
+// copyRecord writes rec (a pointer to a struct) to tx and reports whether
+// it was written.
+func copyRecord(
+	ctx context.Context, tx Database, policy ConflictPolicy, m *schema.Model, rec reflect.Value,
+) (bool, error) {
+	switch policy {
+	case ConflictOverwrite:
+		return true, tx.Save(ctx, rec.Interface())
+	case ConflictSkip:
+		// Look before writing: a failed insert aborts the transaction on
+		// some SQL databases.
+		err := tx.Get(ctx, m.New().Interface(), m.ID.Interface(rec.Elem()))
+		switch {
+		case err == nil:
+			return false, nil
+		case !errors.Is(err, ErrNotFound):
+			return false, err
+		}
+	}
+	return true, tx.Create(ctx, rec.Interface())
+}
@@ -67,6 +67,8 @@ type Database interface {
 	// === Lifecycle ===
 
 	// Close closes the database connection and releases resources.
+	// Drivers that implement [Shutdowner] first wait for the operations
+	// in flight, and fail later ones with [ErrClosed].
 	Close() error
 
 	// Ping verifies the database connection is alive.
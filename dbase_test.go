@@ -0,0 +1,554 @@
+package dbase_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/dbasetest"
+	_ "github.com/nuln/dbase/drivers"
+)
+
+type Customer struct {
+	ID    int    `gorm:"primaryKey" storm:"id"`
+	Email string `gorm:"uniqueIndex" storm:"unique"`
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: filepath.Join(dir, "src.db")})
+	defer func() { _ = src.Close() }()
+	dst := dbase.MustOpen(&dbase.Config{Type: "sqlite", Path: filepath.Join(dir, "dst.db")})
+	defer func() { _ = dst.Close() }()
+
+	require.NoError(t, src.Migrate(ctx, &Customer{}))
+	for i := 1; i <= 7; i++ {
+		require.NoError(t, src.Create(ctx, &Customer{ID: i * 10, Email: fmt.Sprintf("c%d@example.com", i)}))
+	}
+
+	// Interrupt the copy after the first batch, then resume it.
+	cctx, cancel := context.WithCancel(ctx)
+	_, err := dbase.Copy(cctx, src, dst, dbase.CopyOptions{
+		BatchSize: 3,
+		Progress:  func(dbase.CopyStats) { cancel() },
+	}, &Customer{})
+	require.ErrorIs(t, err, context.Canceled)
+	n, err := dst.Count(ctx, &Customer{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	stats, err := dbase.Copy(ctx, src, dst, dbase.CopyOptions{BatchSize: 3, Resume: true}, &Customer{})
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, dbase.CopyStats{Model: "Customer", Copied: 4, Source: 7, Target: 7}, stats[0])
+
+	var got Customer
+	require.NoError(t, dst.Get(ctx, &got, 70))
+	assert.Equal(t, "c7@example.com", got.Email)
+
+	// Copying again conflicts unless told otherwise.
+	_, err = dbase.Copy(ctx, src, dst, dbase.CopyOptions{}, &Customer{})
+	require.ErrorIs(t, err, dbase.ErrAlreadyExists)
+
+	stats, err = dbase.Copy(ctx, src, dst, dbase.CopyOptions{OnConflict: dbase.ConflictSkip}, &Customer{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), stats[0].Skipped)
+
+	require.NoError(t, src.Update(ctx, &Customer{ID: 10, Email: "changed@example.com"}))
+	_, err = dbase.Copy(ctx, src, dst, dbase.CopyOptions{OnConflict: dbase.ConflictOverwrite}, &Customer{})
+	require.NoError(t, err)
+	var changed Customer
+	require.NoError(t, dst.Get(ctx, &changed, 10))
+	assert.Equal(t, "changed@example.com", changed.Email)
+
+	// Copy back into an empty bolt file, then break the counts.
+	back := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: filepath.Join(dir, "back.db")})
+	defer func() { _ = back.Close() }()
+	_, err = dbase.Copy(ctx, dst, back, dbase.CopyOptions{}, &Customer{})
+	require.NoError(t, err)
+
+	require.NoError(t, back.Create(ctx, &Customer{ID: 99, Email: "extra@example.com"}))
+	_, err = dbase.Copy(ctx, dst, back, dbase.CopyOptions{Resume: true}, &Customer{})
+	assert.ErrorIs(t, err, dbase.ErrCountMismatch)
+
+	_, err = dbase.Copy(ctx, src, dst, dbase.CopyOptions{OnConflict: "merge"}, &Customer{})
+	assert.Error(t, err)
+}
+
+type Ticket struct {
+	ID    int `gorm:"primaryKey" storm:"id,increment"`
+	Title string
+}
+
+func TestCopyResumeBolt(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := dbase.MustOpen(&dbase.Config{Type: "sqlite", Path: filepath.Join(dir, "src.db")})
+	defer func() { _ = src.Close() }()
+	dst := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: filepath.Join(dir, "dst.db")})
+	defer func() { _ = dst.Close() }()
+
+	require.NoError(t, src.Migrate(ctx, &Ticket{}))
+	for i := 1; i <= 7; i++ {
+		require.NoError(t, src.Create(ctx, &Ticket{ID: i * 10, Title: fmt.Sprintf("t%d", i)}))
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	_, err := dbase.Copy(cctx, src, dst, dbase.CopyOptions{
+		BatchSize: 3,
+		Progress:  func(dbase.CopyStats) { cancel() },
+	}, &Ticket{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Resuming continues after the highest copied ID, not the first one.
+	stats, err := dbase.Copy(ctx, src, dst, dbase.CopyOptions{BatchSize: 3, Resume: true}, &Ticket{})
+	require.NoError(t, err)
+	assert.Equal(t, dbase.CopyStats{Model: "Ticket", Copied: 4, Source: 7, Target: 7}, stats[0])
+
+	// The increment counter was moved past the copied IDs.
+	next := &Ticket{Title: "new"}
+	require.NoError(t, dst.Create(ctx, next))
+	assert.Equal(t, 71, next.ID)
+	var first Ticket
+	require.NoError(t, dst.Get(ctx, &first, 10))
+	assert.Equal(t, "t1", first.Title)
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	src := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: filepath.Join(dir, "src.db")})
+	defer func() { _ = src.Close() }()
+	dst := dbase.MustOpen(&dbase.Config{Type: "sqlite", Path: filepath.Join(dir, "dst.db")})
+	defer func() { _ = dst.Close() }()
+
+	require.NoError(t, src.Migrate(ctx, &Customer{}))
+	for i := 3; i >= 1; i-- {
+		require.NoError(t, src.Create(ctx, &Customer{ID: i, Email: fmt.Sprintf("c%d@example.com", i)}))
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, dbase.Export(ctx, src, &buf, &Customer{}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.JSONEq(t, `{"format":"dbase-ndjson","version":1,"driver":"bolt","models":[{"name":"Customer","id":"ID","fields":[
+		{"name":"ID","column":"id","type":"int"},{"name":"Email","column":"email","type":"string"}]}]}`, lines[0])
+	assert.JSONEq(t, `{"model":"Customer","record":{"ID":1,"Email":"c1@example.com"}}`, lines[1])
+
+	exported := buf.String()
+	require.NoError(t, dbase.Import(ctx, dst, strings.NewReader(exported), &Customer{}))
+	require.NoError(t, dbase.Import(ctx, dst, strings.NewReader(exported), &Customer{}), "import is repeatable")
+
+	var customers []Customer
+	require.NoError(t, dst.Find(ctx, &customers, dbase.NewQuery().OrderByAsc("ID")))
+	require.Len(t, customers, 3)
+	assert.Equal(t, Customer{ID: 3, Email: "c3@example.com"}, customers[2])
+
+	err := dbase.Import(ctx, dst, strings.NewReader(exported))
+	assert.ErrorIs(t, err, dbase.ErrInvalidModel)
+	err = dbase.Import(ctx, dst, strings.NewReader(`{"format":"csv","version":1}`), &Customer{})
+	assert.Error(t, err)
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	for _, driver := range []string{"bolt", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "live.db")
+			db := dbase.MustOpen(&dbase.Config{Type: driver, Path: path})
+			require.NoError(t, db.Migrate(ctx, &Customer{}))
+			require.NoError(t, db.Create(ctx, &Customer{ID: 1, Email: "before@example.com"}))
+
+			var buf bytes.Buffer
+			require.NoError(t, dbase.Backup(ctx, db, &buf))
+			snapshot := filepath.Join(dir, "snapshot.db")
+			require.NoError(t, dbase.BackupTo(ctx, db, snapshot))
+			require.NoError(t, dbase.BackupTo(ctx, db, snapshot), "existing backups are replaced")
+			err := db.Transaction(ctx, func(tx dbase.Database) error { return dbase.Backup(ctx, tx, io.Discard) })
+			assert.ErrorIs(t, err, dbase.ErrNotSupported, "transaction scopes cannot back up")
+
+			require.NoError(t, db.Create(ctx, &Customer{ID: 2, Email: "after@example.com"}))
+			require.NoError(t, db.Close())
+
+			require.NoError(t, dbase.Restore(&buf, path))
+			for _, p := range []string{path, snapshot} {
+				restored := dbase.MustOpen(&dbase.Config{Type: driver, Path: p})
+				n, err := restored.Count(ctx, &Customer{}, nil)
+				require.NoError(t, err)
+				assert.Equal(t, int64(1), n, p)
+				require.NoError(t, restored.Close())
+			}
+		})
+	}
+
+	db := dbase.MustOpen(&dbase.Config{Type: "jsonfile", Path: filepath.Join(t.TempDir(), "db.json")})
+	defer func() { _ = db.Close() }()
+	assert.ErrorIs(t, dbase.Backup(ctx, db, io.Discard), dbase.ErrNotSupported)
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := dbase.ParseQuery(`Name = "Dune" and Year >= 1960 or Title like 'It''s%' ` +
+		`order by Year desc, Title limit 10 offset 20`)
+	require.NoError(t, err)
+	assert.Equal(t, &dbase.Query{
+		Conditions: []dbase.Condition{
+			{Field: "Name", Operator: dbase.OpEqual, Value: "Dune"},
+			{Field: "Year", Operator: dbase.OpGreaterEqual, Value: int64(1960)},
+			{Field: "Title", Operator: dbase.OpLike, Value: "It's%", Or: true},
+		},
+		OrderBy: []dbase.Order{{Field: "Year", Descending: true}, {Field: "Title"}},
+		Limit:   10,
+		Offset:  20,
+	}, q)
+
+	q, err = dbase.ParseQuery(`Tag not in (a, "b c", 3) AND Deleted IS NULL and Owner is not null and Score<>1.5`)
+	require.NoError(t, err)
+	assert.Equal(t, []dbase.Condition{
+		{Field: "Tag", Operator: dbase.OpNotIn, Value: []any{"a", "b c", int64(3)}},
+		{Field: "Deleted", Operator: dbase.OpIsNull},
+		{Field: "Owner", Operator: dbase.OpNotNull},
+		{Field: "Score", Operator: dbase.OpNotEqual, Value: 1.5},
+	}, q.Conditions)
+
+	q, err = dbase.ParseQuery("limit 5")
+	require.NoError(t, err)
+	assert.Equal(t, &dbase.Query{Limit: 5}, q)
+
+	q, err = dbase.ParseQuery("")
+	require.NoError(t, err)
+	assert.True(t, q.IsEmpty())
+
+	for _, bad := range []string{`Name =`, `Name ~ 1`, `Name = "x`, `Tag in (1, 2`, `limit -1`, `Name = 1 Age = 2`, `= 1`} {
+		_, err := dbase.ParseQuery(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want *dbase.Config
+	}{
+		{
+			"postgres://app:secret@db:5432/app?sslmode=disable&max_open_conns=20&conn_max_lifetime=5m",
+			&dbase.Config{
+				Type: "postgres",
+				DSN:  "postgres://app:secret@db:5432/app?sslmode=disable",
+				Pool: &dbase.PoolConfig{MaxOpenConns: 20, ConnMaxLifetime: 5 * time.Minute},
+			},
+		},
+		{
+			"postgresql://db/app?replica=postgres://r1/app&replica=postgres://r2/app&replica_policy=round_robin",
+			&dbase.Config{
+				Type:          "postgres",
+				DSN:           "postgresql://db/app",
+				Replicas:      []string{"postgres://r1/app", "postgres://r2/app"},
+				ReplicaPolicy: "round_robin",
+			},
+		},
+		{
+			"mariadb://app:secret@db:3306/app?parseTime=true",
+			&dbase.Config{Type: "mysql", DSN: "app:secret@tcp(db:3306)/app?parseTime=true"},
+		},
+		{
+			"sqlite:///var/app.db?_pragma=busy_timeout(5000)&max_idle_conns=2",
+			&dbase.Config{
+				Type: "sqlite",
+				Path: "/var/app.db?_pragma=busy_timeout%285000%29",
+				Pool: &dbase.PoolConfig{MaxIdleConns: 2},
+			},
+		},
+		{"sqlite::memory:", &dbase.Config{Type: "sqlite", Path: ":memory:"}},
+		{"sqlite://data/app.db", &dbase.Config{Type: "sqlite", Path: "data/app.db"}},
+		{
+			"bolt:///data/my%20app.db?timeout=1s",
+			&dbase.Config{Type: "bolt", Path: "/data/my app.db", Options: map[string]any{"timeout": "1s"}},
+		},
+		{
+			"redis://localhost:6379/0?prefix=app:&ttl=1h&dial_timeout=1s",
+			&dbase.Config{
+				Type:    "redis",
+				DSN:     "redis://localhost:6379/0?dial_timeout=1s",
+				Options: map[string]any{"prefix": "app:", "ttl": "1h"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		cfg, err := dbase.ParseURL(tt.url)
+		require.NoError(t, err, tt.url)
+		assert.Equal(t, tt.want, cfg, tt.url)
+	}
+
+	for _, bad := range []string{"/var/app.db", "postgres://db/app?max_open_conns=many", "bolt:///a.db?%zz"} {
+		_, err := dbase.ParseURL(bad)
+		assert.Error(t, err, bad)
+	}
+
+	dir := t.TempDir()
+	db, err := dbase.OpenURL("sqlite://" + filepath.Join(dir, "app.db") + "?_pragma=busy_timeout(1000)")
+	require.NoError(t, err)
+	require.NoError(t, db.Ping(context.Background()))
+	require.NoError(t, db.Close())
+
+	db, err = dbase.OpenURL("jsonfile://" + dir + "?read_only=true")
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	assert.Error(t, db.Create(context.Background(), &Customer{ID: 1}))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "dsn")
+	require.NoError(t, os.WriteFile(secret, []byte("host=db password=s3cret\n"), 0o600))
+	file := filepath.Join(dir, "db.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+type: postgres
+dsn_file: `+secret+`
+replicas: ["host=${REPLICA_HOST} user=$$app"]
+pool:
+  max_open_conns: ${MAX_CONNS:-10}
+  conn_max_lifetime: 5m
+`), 0o600))
+	env := map[string]string{"REPLICA_HOST": "r1"}
+	getenv := func(key string) string { return env[key] }
+
+	cfg, err := dbase.LoadConfig(file, dbase.LoadOptions{Getenv: getenv})
+	require.NoError(t, err)
+	assert.Equal(t, &dbase.Config{
+		Type:     "postgres",
+		DSN:      "host=db password=s3cret",
+		Replicas: []string{"host=r1 user=$app"},
+		Pool:     &dbase.PoolConfig{MaxOpenConns: 10, ConnMaxLifetime: 5 * time.Minute},
+	}, cfg)
+
+	env["APP_DB_URL"] = "postgres://primary/app?max_idle_conns=2"
+	env["APP_DB_MAX_OPEN_CONNS"] = "30"
+	env["APP_DB_REPLICAS"] = "host=r2,host=r3"
+	cfg, err = dbase.LoadConfig(file, dbase.LoadOptions{EnvPrefix: "APP_DB_", Getenv: getenv})
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://primary/app", cfg.DSN)
+	assert.Equal(t, []string{"host=r2", "host=r3"}, cfg.Replicas)
+	assert.Equal(t, &dbase.PoolConfig{MaxOpenConns: 30, MaxIdleConns: 2, ConnMaxLifetime: 5 * time.Minute}, cfg.Pool)
+
+	// The environment alone.
+	cfg, err = dbase.LoadConfig("", dbase.LoadOptions{EnvPrefix: "X_", Getenv: func(key string) string {
+		return map[string]string{"X_TYPE": "sqlite", "X_PATH_FILE": secret}[key]
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, &dbase.Config{Type: "sqlite", Path: "host=db password=s3cret"}, cfg)
+
+	for name, content := range map[string]string{
+		"environment variable MISSING is not set": "type: bolt\npath: ${MISSING}\n",
+		"field dns not found":                     "type: postgres\ndns: host=db\n",
+		"both dsn and dsn_file are set":           "type: postgres\ndsn: a\ndsn_file: b\n",
+	} {
+		require.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+		_, err := dbase.LoadConfig(file, dbase.LoadOptions{Getenv: getenv})
+		assert.ErrorContains(t, err, name)
+	}
+	_, err = dbase.LoadConfig("", dbase.LoadOptions{EnvPrefix: "X_", Getenv: func(key string) string {
+		return map[string]string{"X_CONN_MAX_LIFETIME": "forever"}[key]
+	}})
+	assert.ErrorContains(t, err, "X_CONN_MAX_LIFETIME")
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, (&dbase.Config{Type: "bolt", Path: "app.db"}).Validate())
+
+	tests := []struct {
+		cfg  dbase.Config
+		want string
+	}{
+		{dbase.Config{}, "type is required"},
+		{dbase.Config{Type: "sqlit", Path: "app.db"}, `unknown driver "sqlit" (did you mean "sqlite"?)`},
+		{dbase.Config{Type: "mongo"}, `unknown driver "mongo" (forgotten import?)`},
+		{dbase.Config{Type: "postgres"}, `dsn is required by driver "postgres"`},
+		{dbase.Config{Type: "bolt", DSN: "app.db"}, `path is required by driver "bolt"`},
+		{
+			dbase.Config{Type: "mysql", DSN: "db", ReplicaPolicy: "nearest", Pool: &dbase.PoolConfig{MaxOpenConns: -1}},
+			`replica_policy "nearest" is not "random" or "round_robin"; pool settings must not be negative`,
+		},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		require.ErrorIs(t, err, dbase.ErrInvalidConfig)
+		assert.Equal(t, "dbase: invalid config: "+tt.want, err.Error())
+	}
+
+	_, err := dbase.Open(&dbase.Config{Type: "postgres"})
+	assert.ErrorIs(t, err, dbase.ErrInvalidConfig)
+}
+
+func TestDecodeOptions(t *testing.T) {
+	type base struct {
+		Verbose bool `option:"verbose"`
+	}
+	type options struct {
+		base
+		Timeout time.Duration `option:"timeout"`
+		Size    int           `option:"size"`
+		Ratio   float64       `option:"ratio"`
+		Tags    []string      `option:"tags"`
+		Name    string        `option:"name"`
+	}
+
+	var opts options
+	cfg := &dbase.Config{Type: "test", Options: map[string]any{
+		"verbose": "true", "timeout": "2s", "size": float64(3), "ratio": 1, "tags": "a,b", "name": "x",
+	}}
+	require.NoError(t, dbase.DecodeOptions(cfg, &opts))
+	assert.Equal(t, options{base{true}, 2 * time.Second, 3, 1, []string{"a", "b"}, "x"}, opts)
+
+	cfg.Options = map[string]any{"size": "big", "timeout": 5, "nme": "x", "name": true, "tags": []any{"a", 1}}
+	err := dbase.DecodeOptions(cfg, &opts)
+	require.ErrorIs(t, err, dbase.ErrInvalidConfig)
+	assert.Equal(t, `dbase: invalid config: option "name" for driver "test": expected string, got bool; `+
+		`unknown option "nme" for driver "test" (did you mean "name"?); `+
+		`option "size" for driver "test": expected an integer, got "big"; `+
+		`option "tags" for driver "test": expected a list of strings, got int; `+
+		`option "timeout" for driver "test": expected a duration such as "5s", got int`, err.Error())
+
+	// Validate checks the options a driver declares, and URLs route them.
+	err = (&dbase.Config{Type: "bolt", Path: "app.db", Options: map[string]any{"read_only": "maybe"}}).Validate()
+	assert.ErrorContains(t, err, `option "read_only" for driver "bolt": expected a boolean, got "maybe"`)
+	cfg, err = dbase.ParseURL("postgres://db/app?sslmode=disable&prepare_stmt=true")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://db/app?sslmode=disable", cfg.DSN)
+	assert.Equal(t, map[string]any{"prepare_stmt": "true"}, cfg.Options)
+}
+
+func TestCapabilities(t *testing.T) {
+	bolt, ok := dbase.DriverCapabilities("bolt")
+	require.True(t, ok)
+	assert.False(t, bolt.Or)
+	assert.False(t, bolt.Supports(dbase.OpLike))
+	assert.Equal(t, dbase.NestedNone, bolt.NestedTransactions)
+
+	sqlite, ok := dbase.DriverCapabilities("sqlite")
+	require.True(t, ok)
+	assert.ElementsMatch(t, dbase.AllOperators, sqlite.Operators)
+	assert.Equal(t, dbase.NestedSavepoint, sqlite.NestedTransactions)
+
+	_, ok = dbase.DriverCapabilities("mongo")
+	assert.False(t, ok)
+
+	db := dbase.MustOpen(&dbase.Config{Type: "bolt", Path: filepath.Join(t.TempDir(), "app.db")})
+	defer func() { _ = db.Close() }()
+	assert.Equal(t, bolt, dbase.CapabilitiesOf(db))
+	// A database that does not describe itself is assumed to do everything.
+	assert.True(t, dbase.CapabilitiesOf(struct{ dbase.Database }{db}).Or)
+}
+
+func TestRegistry(t *testing.T) {
+	info, ok := dbase.Lookup("pg")
+	require.True(t, ok)
+	assert.Equal(t, "postgres", info.Name)
+	assert.Equal(t, []string{"pg", "postgresql"}, info.Aliases)
+	assert.Equal(t, []string{"dsn"}, info.Required)
+	assert.Contains(t, info.Options, "prepare_stmt")
+	require.NotNil(t, info.Capabilities)
+	assert.True(t, slices.IsSorted(dbase.Drivers()))
+
+	// An alias opens the driver under its own name.
+	db, err := dbase.Open(&dbase.Config{Type: "sqlite3", Path: filepath.Join(t.TempDir(), "app.db")})
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite", db.Driver())
+	require.NoError(t, db.Close())
+
+	// A fake driver for this test only.
+	var opened *dbase.Config
+	dbasetest.RegisterDriver(t, "fake", func(cfg *dbase.Config) (dbase.Database, error) {
+		opened = cfg
+		return nil, dbase.ErrNotSupported
+	}, dbase.WithAliases("phony"), dbase.Requires("Path"))
+	assert.Panics(t, func() { dbase.Register("phony", nil) })
+	_, err = dbase.OpenURL("phony://host/db")
+	assert.ErrorIs(t, err, dbase.ErrNotSupported)
+	assert.Equal(t, "fake", opened.Type)
+	assert.True(t, dbase.Unregister("fake"))
+	assert.False(t, dbase.Unregister("fake"))
+	_, ok = dbase.Lookup("phony")
+	assert.False(t, ok)
+
+	// A separate registry does not see the default drivers.
+	r := dbase.NewRegistry()
+	r.Register("mem", func(*dbase.Config) (dbase.Database, error) { return nil, nil })
+	assert.Equal(t, []string{"mem"}, r.Drivers())
+	_, err = r.Open(&dbase.Config{Type: "bolt", Path: "app.db"})
+	assert.ErrorContains(t, err, `unknown driver "bolt"`)
+}
+
+func TestOpenRetry(t *testing.T) {
+	down := errors.New("connection refused")
+	failures := 2
+	dbasetest.RegisterDriver(t, "flaky", func(cfg *dbase.Config) (dbase.Database, error) {
+		if failures > 0 {
+			failures--
+			return nil, down
+		}
+		return dbase.Open(&dbase.Config{Type: "sqlite", Path: cfg.Path})
+	})
+	path := filepath.Join(t.TempDir(), "app.db")
+	retry := &dbase.RetryConfig{InitialBackoff: time.Millisecond, MaxWait: time.Second}
+
+	// Without Retry the first failure is returned.
+	_, err := dbase.Open(&dbase.Config{Type: "flaky", Path: path})
+	assert.ErrorIs(t, err, down)
+
+	db, err := dbase.Open(&dbase.Config{Type: "flaky", Path: path, Retry: retry})
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	// Retrying gives up after MaxWait.
+	failures = 1 << 20
+	_, err = dbase.Open(&dbase.Config{Type: "flaky", Path: path, Retry: &dbase.RetryConfig{InitialBackoff: time.Millisecond, MaxWait: 20 * time.Millisecond}})
+	assert.ErrorIs(t, err, down)
+	assert.ErrorContains(t, err, "giving up")
+
+	// A lazy database connects on first use; Ping reports the failure.
+	ctx := context.Background()
+	failures = 1
+	db, err = dbase.Open(&dbase.Config{Type: "flaky", Path: path, Lazy: true})
+	require.NoError(t, err)
+	assert.ErrorIs(t, db.Ping(ctx), down)
+	require.NoError(t, db.Ping(ctx))
+	require.NoError(t, db.Migrate(ctx, &Customer{}))
+	require.NoError(t, db.Create(ctx, &Customer{ID: 1, Email: "a@example.com"}))
+	n, err := db.Count(ctx, &Customer{}, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	require.NoError(t, dbase.BackupTo(ctx, db, filepath.Join(t.TempDir(), "backup.db")))
+	require.NoError(t, db.Close())
+	assert.ErrorIs(t, db.Ping(ctx), dbase.ErrClosed)
+}
+
+func TestStatsOf(t *testing.T) {
+	ctx := context.Background()
+	db, err := dbase.Open(&dbase.Config{Type: "sqlite", Path: filepath.Join(t.TempDir(), "app.db")})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	require.NoError(t, db.Migrate(ctx, &Customer{}))
+	require.NoError(t, db.Create(ctx, &Customer{ID: 1, Email: "a@example.com"}))
+
+	stats, err := dbase.StatsOf(ctx, db, &Customer{})
+	require.NoError(t, err)
+	assert.Equal(t, "sqlite", stats.Driver)
+	require.NotNil(t, stats.Pool)
+	assert.Positive(t, stats.Pool.Open)
+	assert.Positive(t, stats.Size)
+	assert.Equal(t, map[string]int64{"Customer": 1}, stats.Records)
+}
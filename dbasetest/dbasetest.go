@@ -3,6 +3,8 @@ package dbasetest
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -19,6 +21,16 @@ type TestModel struct {
 	Age   int
 }
 
+// RegisterDriver registers a driver in the default registry for the rest
+// of the test, e.g. a fake that returns canned errors, and unregisters it
+// when the test ends. Tests using it must not run in parallel with others
+// that register the same name.
+func RegisterDriver(t testing.TB, name string, factory dbase.Factory, opts ...dbase.DriverOption) {
+	t.Helper()
+	dbase.Register(name, factory, opts...)
+	t.Cleanup(func() { dbase.Unregister(name) })
+}
+
 // Suite runs a comprehensive conformance test suite against any [dbase.Database]
 // implementation. It verifies CRUD operations, querying, transactions, hooks,
 // and edge cases.
@@ -73,6 +85,11 @@ func Suite(t *testing.T, database dbase.Database) {
 		assert.NotEqual(t, bob.ID, charlie.ID)
 	})
 
+	t.Run("CreateDuplicateUnique", func(t *testing.T) {
+		err := database.Create(ctx, &TestModel{Name: "Alice Again", Email: "alice@test.com"})
+		assert.ErrorIs(t, err, dbase.ErrAlreadyExists, "a unique field must not be duplicated")
+	})
+
 	// ===== Get =====
 
 	t.Run("Get", func(t *testing.T) {
@@ -88,6 +105,25 @@ func Suite(t *testing.T, database dbase.Database) {
 		assert.ErrorIs(t, err, dbase.ErrNotFound)
 	})
 
+	t.Run("StringID", func(t *testing.T) {
+		type Keyed struct {
+			ID   string `gorm:"primaryKey" storm:"id"`
+			Name string
+		}
+		require.NoError(t, database.Migrate(ctx, &Keyed{}))
+		// The quote must be bound as a value, not spliced into SQL.
+		id := "it's-1"
+		require.NoError(t, database.Create(ctx, &Keyed{ID: id, Name: "keyed"}))
+
+		var got Keyed
+		require.NoError(t, database.Get(ctx, &got, id))
+		assert.Equal(t, Keyed{ID: id, Name: "keyed"}, got)
+		assert.ErrorIs(t, database.Get(ctx, &got, "missing"), dbase.ErrNotFound)
+
+		require.NoError(t, database.Delete(ctx, &Keyed{}, id))
+		assert.ErrorIs(t, database.Get(ctx, &got, id), dbase.ErrNotFound)
+	})
+
 	// ===== Update =====
 
 	t.Run("Update", func(t *testing.T) {
@@ -314,4 +350,228 @@ func Suite(t *testing.T, database dbase.Database) {
 		q.Where("Name", dbase.OpEqual, "test")
 		assert.False(t, q.IsEmpty())
 	})
+
+	// ===== Capabilities =====
+	// Features the driver declares are checked; the others are skipped.
+
+	caps := dbase.CapabilitiesOf(database)
+
+	// names returns the sorted names of the Cap-* records matching query.
+	names := func(t *testing.T, query *dbase.Query) []string {
+		t.Helper()
+		var results []TestModel
+		require.NoError(t, database.Find(ctx, &results, query.OrderByAsc("Name")))
+		out := []string{}
+		for _, r := range results {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+	capQuery := func() *dbase.Query {
+		return dbase.NewQuery().Where("Age", dbase.OpGreaterEqual, 100)
+	}
+
+	t.Run("CapabilitiesSetup", func(t *testing.T) {
+		for i, name := range []string{"Cap-A", "Cap-B", "Cap-C"} {
+			require.NoError(t, database.Create(ctx, &TestModel{
+				Name: name, Email: strings.ToLower(name) + "@test.com", Age: 101 + i,
+			}))
+		}
+	})
+
+	operatorTests := []struct {
+		op    dbase.Operator
+		field string
+		value any
+		want  []string
+	}{
+		{dbase.OpEqual, "Name", "Cap-B", []string{"Cap-B"}},
+		{dbase.OpNotEqual, "Name", "Cap-B", []string{"Cap-A", "Cap-C"}},
+		{dbase.OpGreater, "Age", 102, []string{"Cap-C"}},
+		{dbase.OpGreaterEqual, "Age", 102, []string{"Cap-B", "Cap-C"}},
+		{dbase.OpLess, "Age", 102, []string{"Cap-A"}},
+		{dbase.OpLessEqual, "Age", 102, []string{"Cap-A", "Cap-B"}},
+		{dbase.OpIn, "Name", []string{"Cap-A", "Cap-C"}, []string{"Cap-A", "Cap-C"}},
+		{dbase.OpNotIn, "Name", []string{"Cap-A", "Cap-C"}, []string{"Cap-B"}},
+		{dbase.OpLike, "Name", "%-B", []string{"Cap-B"}},
+		{dbase.OpPrefix, "Name", "Cap-", []string{"Cap-A", "Cap-B", "Cap-C"}},
+		{dbase.OpPrefix, "Name", "Cap_", []string{}},
+		{dbase.OpPrefix, "Name", "%-", []string{}},
+		{dbase.OpIsNull, "Name", nil, []string{}},
+		{dbase.OpNotNull, "Name", nil, []string{"Cap-A", "Cap-B", "Cap-C"}},
+	}
+	// unsupported checks that a query the driver does not declare support
+	// for is rejected rather than answered with some of its conditions
+	// dropped.
+	unsupported := func(t *testing.T, query *dbase.Query) {
+		t.Helper()
+		var results []TestModel
+		assert.ErrorIs(t, database.Find(ctx, &results, query), dbase.ErrNotSupported, "Find")
+		assert.ErrorIs(t, database.FindOne(ctx, &TestModel{}, query), dbase.ErrNotSupported, "FindOne")
+		_, err := database.Count(ctx, &TestModel{}, query)
+		assert.ErrorIs(t, err, dbase.ErrNotSupported, "Count")
+		_, err = database.Exists(ctx, &TestModel{}, query)
+		assert.ErrorIs(t, err, dbase.ErrNotSupported, "Exists")
+	}
+
+	for _, tt := range operatorTests {
+		t.Run("Operator_"+string(tt.op), func(t *testing.T) {
+			q := capQuery().Where(tt.field, tt.op, tt.value)
+			if !caps.Supports(tt.op) {
+				unsupported(t, q)
+				return
+			}
+			assert.Equal(t, tt.want, names(t, q))
+		})
+	}
+
+	t.Run("QueryOr", func(t *testing.T) {
+		q := capQuery().Where("Name", dbase.OpEqual, "Cap-A").Or("Name", dbase.OpEqual, "Cap-C")
+		if !caps.Or {
+			unsupported(t, q)
+			return
+		}
+		assert.Equal(t, []string{"Cap-A", "Cap-C"}, names(t, q))
+	})
+
+	t.Run("UpdateFieldsPartial", func(t *testing.T) {
+		if !caps.PartialUpdate {
+			t.Skipf("%s updates whole records", database.Driver())
+		}
+		var user TestModel
+		require.NoError(t, database.FindOne(ctx, &user, dbase.Eq("Name", "Cap-A")))
+		user.Name, user.Age = "Changed", 111
+		require.NoError(t, database.UpdateFields(ctx, &user, "Age"))
+
+		var stored TestModel
+		require.NoError(t, database.Get(ctx, &stored, user.ID))
+		assert.Equal(t, "Cap-A", stored.Name)
+		assert.Equal(t, 111, stored.Age)
+	})
+
+	t.Run("NestedTransaction", func(t *testing.T) {
+		create := func(tx dbase.Database, name string) error {
+			return tx.Create(ctx, &TestModel{Name: name, Email: strings.ToLower(name) + "@test.com", Age: 200})
+		}
+		exists := func(name string) bool {
+			ok, err := database.Exists(ctx, &TestModel{}, dbase.Eq("Name", name))
+			require.NoError(t, err)
+			return ok
+		}
+
+		switch caps.NestedTransactions {
+		case dbase.NestedNone:
+			err := database.Transaction(ctx, func(tx dbase.Database) error {
+				return tx.Transaction(ctx, func(dbase.Database) error { return nil })
+			})
+			assert.ErrorIs(t, err, dbase.ErrNotSupported)
+
+		case dbase.NestedJoin:
+			err := database.Transaction(ctx, func(tx dbase.Database) error {
+				if err := tx.Transaction(ctx, func(inner dbase.Database) error {
+					return create(inner, "Nested-Join")
+				}); err != nil {
+					return err
+				}
+				return assert.AnError
+			})
+			assert.ErrorIs(t, err, assert.AnError)
+			assert.False(t, exists("Nested-Join"), "the outer rollback must undo the joined call")
+
+		case dbase.NestedSavepoint:
+			err := database.Transaction(ctx, func(tx dbase.Database) error {
+				if err := create(tx, "Nested-Outer"); err != nil {
+					return err
+				}
+				err := tx.Transaction(ctx, func(inner dbase.Database) error {
+					if err := create(inner, "Nested-Inner"); err != nil {
+						return err
+					}
+					return assert.AnError
+				})
+				assert.ErrorIs(t, err, assert.AnError)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.True(t, exists("Nested-Outer"))
+			assert.False(t, exists("Nested-Inner"), "the savepoint must be rolled back")
+		}
+	})
+
+	// ===== Projection =====
+
+	t.Run("ProjectionSetup", func(t *testing.T) {
+		for i, age := range []int{300, 300, 301} {
+			email := "proj" + string(rune('a'+i)) + "@test.com"
+			require.NoError(t, database.Create(ctx, &TestModel{Name: "Proj", Email: email, Age: age}))
+		}
+	})
+
+	projQuery := func() *dbase.Query { return dbase.Eq("Name", "Proj").OrderByAsc("Age") }
+
+	t.Run("Select", func(t *testing.T) {
+		var results []TestModel
+		require.NoError(t, database.Find(ctx, &results, projQuery().Select("Age")))
+		require.Len(t, results, 3)
+		for _, r := range results {
+			assert.Empty(t, r.Name, "unselected fields must be left zero")
+			assert.NotZero(t, r.Age)
+		}
+	})
+
+	t.Run("SelectUnknownField", func(t *testing.T) {
+		var results []TestModel
+		err := database.Find(ctx, &results, projQuery().Select("Missing"))
+		assert.ErrorIs(t, err, dbase.ErrInvalidModel)
+	})
+
+	t.Run("Distinct", func(t *testing.T) {
+		var results []TestModel
+		require.NoError(t, database.Find(ctx, &results, projQuery().Distinct("Age")))
+		require.Len(t, results, 2)
+		assert.Equal(t, 300, results[0].Age)
+		assert.Equal(t, 301, results[1].Age)
+
+		results = nil
+		require.NoError(t, database.Find(ctx, &results, projQuery().Distinct("Age").SetOffset(1).SetLimit(1)))
+		require.Len(t, results, 1)
+		assert.Equal(t, 301, results[0].Age)
+	})
+
+	t.Run("CountDistinct", func(t *testing.T) {
+		count, err := database.Count(ctx, &TestModel{}, projQuery().Distinct("Age"))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("Project", func(t *testing.T) {
+		var ages []struct{ Age int }
+		require.NoError(t, dbase.Project(ctx, database, &TestModel{}, &ages, projQuery().Distinct("Age")))
+		assert.Equal(t, []struct{ Age int }{{300}, {301}}, ages)
+
+		var rows []map[string]any
+		require.NoError(t, dbase.Project(ctx, database, &TestModel{}, &rows, projQuery().Select("Name", "Age")))
+		require.Len(t, rows, 3)
+		assert.Equal(t, "Proj", rows[0]["name"])
+		assert.EqualValues(t, 300, rows[0]["age"])
+		assert.NotContains(t, rows[0], "email")
+	})
+
+	// ===== Sequences =====
+
+	t.Run("SyncSequence", func(t *testing.T) {
+		type Sequenced struct {
+			ID   uint `gorm:"primaryKey" storm:"id,increment"`
+			Name string
+		}
+		require.NoError(t, database.Migrate(ctx, &Sequenced{}))
+		require.NoError(t, database.Create(ctx, &Sequenced{ID: 100, Name: "explicit"}))
+		if err := dbase.SyncSequence(ctx, database, &Sequenced{}); !errors.Is(err, dbase.ErrNotSupported) {
+			require.NoError(t, err)
+		}
+
+		next := &Sequenced{Name: "next"}
+		require.NoError(t, database.Create(ctx, next))
+		assert.Greater(t, next.ID, uint(100), "the sequence must not hand out stored IDs")
+	})
 }
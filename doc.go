@@ -9,6 +9,9 @@
 //   - postgres — PostgreSQL via GORM
 //   - mysql    — MySQL/MariaDB via GORM
 //   - bolt     — BoltDB via Storm (import _ "github.com/nuln/dbase/bolt")
+//   - badger   — BadgerDB with tag-derived secondary indexes (import _ "github.com/nuln/dbase/badger")
+//   - redis    — Redis, records as JSON with index sets (import _ "github.com/nuln/dbase/redis")
+//   - jsonfile — JSON/NDJSON files, one per model (import _ "github.com/nuln/dbase/jsonfile")
 //
 // # Quick Start
 //
@@ -6,6 +6,9 @@
 package drivers
 
 import (
+	_ "github.com/nuln/dbase/badger"
 	_ "github.com/nuln/dbase/bolt"
 	_ "github.com/nuln/dbase/gorm"
+	_ "github.com/nuln/dbase/jsonfile"
+	_ "github.com/nuln/dbase/redis"
 )
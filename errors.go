@@ -22,6 +22,9 @@ var (
 
 	// ErrClosed is returned when operating on a closed database.
 	ErrClosed = errors.New("dbase: database closed")
+
+	// ErrInvalidConfig is returned when a [Config] fails validation.
+	ErrInvalidConfig = errors.New("dbase: invalid config")
 )
 
 // IsNotFound reports whether err is or wraps [ErrNotFound].
@@ -0,0 +1,195 @@
+package dbase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// ExportFormat and ExportVersion identify the stream written by [Export].
+const (
+	ExportFormat  = "dbase-ndjson"
+	ExportVersion = 1
+)
+
+// ExportHeader is the first line of an export stream.
+type ExportHeader struct {
+	Format  string        `json:"format"`
+	Version int           `json:"version"`
+	Driver  string        `json:"driver"`
+	Models  []ModelSchema `json:"models"`
+}
+
+// ModelSchema describes a model in an [ExportHeader].
+type ModelSchema struct {
+	Name   string        `json:"name"`
+	ID     string        `json:"id"`
+	Fields []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes a model field in a [ModelSchema].
+type FieldSchema struct {
+	Name   string `json:"name"`
+	Column string `json:"column"`
+	Type   string `json:"type"`
+}
+
+// exportRecord is a record line of an export stream.
+type exportRecord struct {
+	Model  string          `json:"model"`
+	Record json.RawMessage `json:"record"`
+}
+
+const exportBatchSize = 500
+
+// Export writes all records of models to w as newline-delimited JSON. The
+// first line is an [ExportHeader] describing the models; each following
+// line holds one record as {"model": name, "record": {...}}, encoded with
+// encoding/json and ordered by model and ID, so exports of the same data
+// diff cleanly. Records are read in batches and never held in memory all at
+// once.
+func Export(ctx context.Context, db Database, w io.Writer, models ...any) error {
+	header := ExportHeader{Format: ExportFormat, Version: ExportVersion, Driver: db.Driver()}
+	metas := make([]*schema.Model, 0, len(models))
+	for _, model := range models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidModel, err)
+		}
+		metas = append(metas, m)
+		header.Models = append(header.Models, modelSchema(m))
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("dbase: export: %w", err)
+	}
+	for _, m := range metas {
+		err := batches(ctx, db, m, exportBatchSize, nil, func(records reflect.Value) error {
+			for i := range records.Len() {
+				data, err := json.Marshal(records.Index(i).Interface())
+				if err != nil {
+					return err
+				}
+				if err := enc.Encode(exportRecord{Model: m.Name, Record: data}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("dbase: export %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Import reads a stream written by [Export] and saves its records into db,
+// which is migrated first. models are the Go types to decode the records
+// into, matched to the stream by name; the stream must not contain other
+// models. Records are written in batches, one transaction each, and saved
+// (upserted), so an import can be repeated.
+func Import(ctx context.Context, db Database, r io.Reader, models ...any) error {
+	byName := make(map[string]*schema.Model, len(models))
+	for _, model := range models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidModel, err)
+		}
+		byName[m.Name] = m
+	}
+
+	dec := json.NewDecoder(r)
+	var header ExportHeader
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("dbase: import: read header: %w", err)
+	}
+	if header.Format != ExportFormat || header.Version != ExportVersion {
+		return fmt.Errorf("dbase: import: unsupported stream %q version %d", header.Format, header.Version)
+	}
+	for _, ms := range header.Models {
+		m, ok := byName[ms.Name]
+		switch {
+		case !ok:
+			return fmt.Errorf("%w: import: no model given for %s", ErrInvalidModel, ms.Name)
+		case ms.ID != m.ID.Name:
+			return fmt.Errorf("%w: import: %s has ID %s in the stream, %s in the model",
+				ErrInvalidModel, ms.Name, ms.ID, m.ID.Name)
+		}
+		if err := db.Migrate(ctx, m.New().Interface()); err != nil {
+			return fmt.Errorf("dbase: import: migrate %s: %w", ms.Name, err)
+		}
+	}
+
+	batch := make([]any, 0, exportBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := db.Transaction(ctx, func(tx Database) error {
+			for _, rec := range batch {
+				if err := tx.Save(ctx, rec); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	for n := 1; ; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var rec exportRecord
+		err := dec.Decode(&rec)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("dbase: import: record %d: %w", n, err)
+		}
+		m, ok := byName[rec.Model]
+		if !ok || !declared(header, rec.Model) {
+			return fmt.Errorf("%w: import: record %d: unknown model %s", ErrInvalidModel, n, rec.Model)
+		}
+		v := m.New()
+		if err := json.Unmarshal(rec.Record, v.Interface()); err != nil {
+			return fmt.Errorf("dbase: import: record %d: %w", n, err)
+		}
+		batch = append(batch, v.Interface())
+		if len(batch) == exportBatchSize {
+			if err := flush(); err != nil {
+				return fmt.Errorf("dbase: import: %w", err)
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return fmt.Errorf("dbase: import: %w", err)
+	}
+	return nil
+}
+
+func modelSchema(m *schema.Model) ModelSchema {
+	ms := ModelSchema{Name: m.Name, ID: m.ID.Name}
+	for _, f := range m.Fields {
+		ms.Fields = append(ms.Fields, FieldSchema{Name: f.Name, Column: f.Column, Type: f.Type.String()})
+	}
+	return ms
+}
+
+// declared reports whether the header lists model name.
+func declared(header ExportHeader, name string) bool {
+	for _, ms := range header.Models {
+		if ms.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/atomicfile"
+)
+
+// Backup implements [dbase.Backupper] for SQLite using VACUUM INTO, which
+// writes a consistent, compacted copy of the primary database while it
+// stays online. Other dialects return [dbase.ErrNotSupported]; use their
+// native tools (pg_dump, mysqldump) or [dbase.Export]. So do transaction
+// scopes, since SQLite cannot vacuum inside a transaction.
+func (d *DB) Backup(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "dbase-backup-*")
+	if err != nil {
+		return fmt.Errorf("dbase/gorm: backup: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	tmp := filepath.Join(dir, "backup.db")
+	if err := d.vacuumInto(ctx, tmp); err != nil {
+		return err
+	}
+	f, err := os.Open(tmp)
+	if err != nil {
+		return fmt.Errorf("dbase/gorm: backup: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("dbase/gorm: backup: %w", err)
+	}
+	return nil
+}
+
+// BackupTo implements [dbase.Backupper].
+func (d *DB) BackupTo(ctx context.Context, path string) error {
+	return atomicfile.Replace(path, func(tmp string) error {
+		return d.vacuumInto(ctx, tmp)
+	})
+}
+
+func (d *DB) vacuumInto(ctx context.Context, path string) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.driverName != "sqlite" {
+		return fmt.Errorf("dbase/gorm: backup %s: %w", d.driverName, dbase.ErrNotSupported)
+	}
+	if d.gate == nil {
+		return fmt.Errorf("dbase/gorm: backup inside a transaction: %w", dbase.ErrNotSupported)
+	}
+	if err := d.gdb.WithContext(ctx).Clauses(dbresolver.Write).Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("dbase/gorm: backup: %w", err)
+	}
+	return nil
+}
+
+var _ dbase.Backupper = (*DB)(nil)
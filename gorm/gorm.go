@@ -1,73 +1,196 @@
 // Package gorm provides a [dbase.Database] implementation backed by GORM.
 // Importing this package registers the "sqlite", "postgres", and "mysql" drivers.
 //
+// When [dbase.Config.Replicas] is set, reads outside transactions go to the
+// replicas and everything else to the primary; see [dbase.UsePrimary].
+//
 //	import _ "github.com/nuln/dbase/gorm"
 package gorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/plugin/dbresolver"
 
 	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/gate"
 )
 
+// dialectors maps each registered driver to the function that opens a
+// dialector from a DSN (or path); it is also used for replicas.
+var dialectors = map[string]func(dsn string) gorm.Dialector{
+	"sqlite":   sqlite.Open,
+	"postgres": postgres.Open,
+	"mysql":    mysql.Open,
+}
+
 func init() {
 	dbase.Register("sqlite", func(cfg *dbase.Config) (dbase.Database, error) {
-		return newDB("sqlite", sqlite.Open(cfg.Path), cfg)
-	})
+		var opts SQLiteOptions
+		if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		c := *cfg
+		c.Path = opts.dsn(cfg.Path)
+		c.Replicas = make([]string, len(cfg.Replicas))
+		for i, replica := range cfg.Replicas {
+			c.Replicas[i] = opts.dsn(replica)
+		}
+		return newDB("sqlite", sqlite.Open(c.Path), &c, opts.Options)
+	}, dbase.WithDescription("SQLite database file, through GORM"), dbase.WithAliases("sqlite3"),
+		dbase.Requires("Path"), dbase.WithOptions(SQLiteOptions{}), dbase.WithCapabilities(capabilities))
 	dbase.Register("postgres", func(cfg *dbase.Config) (dbase.Database, error) {
-		return newDB("postgres", postgres.Open(cfg.DSN), cfg)
-	})
+		var opts Options
+		if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return newDB("postgres", postgres.Open(cfg.DSN), cfg, opts)
+	}, dbase.WithDescription("PostgreSQL server, through GORM"), dbase.WithAliases("pg", "postgresql"),
+		dbase.Requires("DSN"), dbase.WithOptions(Options{}), dbase.WithCapabilities(capabilities))
 	dbase.Register("mysql", func(cfg *dbase.Config) (dbase.Database, error) {
-		return newDB("mysql", mysql.Open(cfg.DSN), cfg)
-	})
+		var opts Options
+		if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return newDB("mysql", mysql.Open(cfg.DSN), cfg, opts)
+	}, dbase.WithDescription("MySQL or MariaDB server, through GORM"), dbase.WithAliases("mariadb"),
+		dbase.Requires("DSN"), dbase.WithOptions(Options{}), dbase.WithCapabilities(capabilities))
+}
+
+// capabilities of the SQL drivers.
+var capabilities = dbase.Capabilities{
+	Operators:          dbase.AllOperators,
+	Or:                 true,
+	PartialUpdate:      true,
+	AtomicTransactions: true,
+	NestedTransactions: dbase.NestedSavepoint,
+	Aggregation:        true,
+	Replicas:           true,
 }
 
 // DB implements [dbase.Database] using GORM.
 type DB struct {
 	gdb        *gorm.DB
 	driverName string
+	resolver   *dbresolver.DBResolver // nil without replicas
+	gate       *gate.Gate             // nil for transaction scopes
 }
 
-// newDB creates a GORM-backed Database and applies pool settings.
-func newDB(driver string, dialector gorm.Dialector, cfg *dbase.Config) (*DB, error) {
-	gdb, err := gorm.Open(dialector, &gorm.Config{})
+// newDB creates a GORM-backed Database, registers replicas and applies pool
+// settings to every connection pool.
+func newDB(driver string, dialector gorm.Dialector, cfg *dbase.Config, opts Options) (*DB, error) {
+	gcfg, err := opts.config()
+	if err != nil {
+		return nil, err
+	}
+	gdb, err := gorm.Open(dialector, gcfg)
 	if err != nil {
 		return nil, fmt.Errorf("dbase/gorm: open %s: %w", driver, err)
 	}
+	d := &DB{gdb: gdb, driverName: driver, gate: &gate.Gate{}}
+
+	if len(cfg.Replicas) > 0 {
+		if err := d.useReplicas(cfg); err != nil {
+			_ = d.Close()
+			return nil, err
+		}
+	}
 
 	// Apply connection pool configuration.
 	if cfg.Pool != nil {
-		sqlDB, err := gdb.DB()
-		if err != nil {
+		if err := d.eachPool(func(sqlDB *sql.DB) error {
+			if cfg.Pool.MaxOpenConns > 0 {
+				sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+			}
+			if cfg.Pool.MaxIdleConns > 0 {
+				sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+			}
+			if cfg.Pool.ConnMaxLifetime > 0 {
+				sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+			}
+			if cfg.Pool.ConnMaxIdleTime > 0 {
+				sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
+			}
+			return nil
+		}); err != nil {
+			_ = d.Close()
 			return nil, fmt.Errorf("dbase/gorm: get sql.DB: %w", err)
 		}
-		if cfg.Pool.MaxOpenConns > 0 {
-			sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
-		}
-		if cfg.Pool.MaxIdleConns > 0 {
-			sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
-		}
-		if cfg.Pool.ConnMaxLifetime > 0 {
-			sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
-		}
-		if cfg.Pool.ConnMaxIdleTime > 0 {
-			sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
-		}
 	}
 
-	return &DB{gdb: gdb, driverName: driver}, nil
+	return d, nil
+}
+
+// useReplicas opens cfg.Replicas and routes reads to them.
+func (d *DB) useReplicas(cfg *dbase.Config) error {
+	open, ok := dialectors[d.driverName]
+	if !ok {
+		return fmt.Errorf("dbase/gorm: replicas for %s: %w", d.driverName, dbase.ErrNotSupported)
+	}
+
+	var policy dbresolver.Policy
+	switch cfg.ReplicaPolicy {
+	case "", dbase.ReplicaRandom:
+		policy = dbresolver.RandomPolicy{}
+	case dbase.ReplicaRoundRobin:
+		policy = dbresolver.StrictRoundRobinPolicy()
+	default:
+		return fmt.Errorf("dbase/gorm: unknown replica policy %q", cfg.ReplicaPolicy)
+	}
+
+	replicas := make([]gorm.Dialector, len(cfg.Replicas))
+	for i, dsn := range cfg.Replicas {
+		replicas[i] = open(dsn)
+	}
+	d.resolver = dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: policy})
+	if err := d.gdb.Use(d.resolver); err != nil {
+		d.resolver = nil
+		return fmt.Errorf("dbase/gorm: open replicas: %w", err)
+	}
+	return nil
+}
+
+// eachPool calls fn with the primary connection pool and every replica pool.
+func (d *DB) eachPool(fn func(*sql.DB) error) error {
+	primary, err := d.gdb.DB()
+	if err != nil {
+		return err
+	}
+	if d.resolver == nil {
+		return fn(primary)
+	}
+	// The resolver visits the primary as its single source, then the replicas.
+	return d.resolver.Call(func(pool gorm.ConnPool) error {
+		sqlDB, ok := pool.(*sql.DB)
+		if !ok {
+			return fmt.Errorf("unexpected connection pool %T", pool)
+		}
+		return fn(sqlDB)
+	})
+}
+
+// reader returns a session for read-only statements, pinned to the primary
+// when ctx was marked with [dbase.UsePrimary].
+func (d *DB) reader(ctx context.Context) *gorm.DB {
+	tx := d.gdb.WithContext(ctx)
+	if d.resolver != nil && dbase.PrimaryOnly(ctx) {
+		tx = tx.Clauses(dbresolver.Write)
+	}
+	return tx
 }
 
 // New creates a DB from a raw GORM dialector (for advanced usage).
 func New(driver string, dialector gorm.Dialector) (*DB, error) {
-	return newDB(driver, dialector, &dbase.Config{})
+	return newDB(driver, dialector, &dbase.Config{}, Options{})
 }
 
 // Gorm returns the underlying *gorm.DB for advanced operations.
@@ -76,18 +199,29 @@ func (d *DB) Gorm() *gorm.DB { return d.gdb }
 // Driver implements [dbase.Database].
 func (d *DB) Driver() string { return d.driverName }
 
+// Capabilities implements [dbase.Capable].
+func (d *DB) Capabilities() dbase.Capabilities { return capabilities }
+
 func (d *DB) Create(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeCreateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.gdb.WithContext(ctx).Create(model).Error; err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterCreateHooks(ctx, model)
 }
 
 func (d *DB) Get(ctx context.Context, model any, id any) error {
-	err := d.gdb.WithContext(ctx).First(model, id).Error
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	err := d.reader(ctx).First(model, byID(id)).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return dbase.ErrNotFound
 	}
@@ -95,52 +229,76 @@ func (d *DB) Get(ctx context.Context, model any, id any) error {
 }
 
 func (d *DB) Update(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeUpdateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.gdb.WithContext(ctx).Save(model).Error; err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterUpdateHooks(ctx, model)
 }
 
 func (d *DB) UpdateFields(ctx context.Context, model any, fields ...string) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeUpdateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.gdb.WithContext(ctx).Model(model).Select(fields).Updates(model).Error; err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterUpdateHooks(ctx, model)
 }
 
 func (d *DB) Save(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeCreateHooks(ctx, model); err != nil {
 		return err
 	}
 	if err := d.gdb.WithContext(ctx).Save(model).Error; err != nil {
-		return err
+		return convertError(err)
 	}
 	return dbase.RunAfterCreateHooks(ctx, model)
 }
 
 func (d *DB) Delete(ctx context.Context, model any, id any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	if err := dbase.RunBeforeDeleteHooks(ctx, model); err != nil {
 		return err
 	}
-	if err := d.gdb.WithContext(ctx).Delete(model, id).Error; err != nil {
+	if err := d.gdb.WithContext(ctx).Delete(model, byID(id)).Error; err != nil {
 		return err
 	}
 	return dbase.RunAfterDeleteHooks(ctx, model)
 }
 
 func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
-	tx := d.buildQuery(ctx, query)
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	tx := d.buildQuery(ctx, results, query)
 	return tx.Find(results).Error
 }
 
 func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error {
-	tx := d.buildQuery(ctx, query)
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	tx := d.buildQuery(ctx, result, query)
 	err := tx.First(result).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return dbase.ErrNotFound
@@ -149,9 +307,26 @@ func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error
 }
 
 func (d *DB) Count(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	if !d.gate.Enter() {
+		return 0, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if query != nil && len(query.Fields) > 0 && !query.Unique {
+		// A selected column would make GORM count its non-NULL values.
+		q := *query
+		q.Fields = nil
+		query = &q
+	}
 	var count int64
-	tx := d.buildQuery(ctx, query)
-	err := tx.Model(model).Count(&count).Error
+	tx := d.buildQuery(ctx, model, query).Model(model)
+	if query != nil && query.Unique {
+		if tx.Error != nil {
+			return 0, tx.Error
+		}
+		// GORM counts distinct values of a single column only.
+		tx = d.reader(ctx).Table("(?) AS distinct_rows", tx)
+	}
+	err := tx.Count(&count).Error
 	return count, err
 }
 
@@ -161,56 +336,105 @@ func (d *DB) Exists(ctx context.Context, model any, query *dbase.Query) (bool, e
 }
 
 func (d *DB) Transaction(ctx context.Context, fn func(tx dbase.Database) error) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
 	return d.gdb.WithContext(ctx).Transaction(func(gtx *gorm.DB) error {
+		// The transaction scope has no gate: this call keeps d open.
 		return fn(&DB{gdb: gtx, driverName: d.driverName})
 	})
 }
 
 func (d *DB) Migrate(ctx context.Context, models ...any) error {
-	return d.gdb.WithContext(ctx).AutoMigrate(models...)
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	// Schema inspection must see the primary, not a lagging replica.
+	return d.gdb.WithContext(ctx).Clauses(dbresolver.Write).AutoMigrate(models...)
 }
 
+// Close is [DB.Shutdown] without a deadline. It does nothing on
+// transaction-scoped instances.
 func (d *DB) Close() error {
-	sqlDB, err := d.gdb.DB()
-	if err != nil {
-		return err
+	return d.Shutdown(context.Background())
+}
+
+// Shutdown implements [dbase.Shutdowner]. When ctx is done before the
+// operations in flight finish, the pools are closed anyway; statements
+// still running then end as the database/sql package lets them.
+func (d *DB) Shutdown(ctx context.Context) error {
+	if d.gate == nil {
+		return nil
+	}
+	first, waitErr := d.gate.Close(ctx)
+	if !first {
+		return nil
 	}
-	return sqlDB.Close()
+	errs := []error{waitErr}
+	err := d.eachPool(func(sqlDB *sql.DB) error {
+		errs = append(errs, sqlDB.Close())
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
 }
 
 func (d *DB) Ping(ctx context.Context) error {
-	sqlDB, err := d.gdb.DB()
-	if err != nil {
-		return err
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
 	}
-	return sqlDB.PingContext(ctx)
+	defer d.gate.Leave()
+	return d.eachPool(func(sqlDB *sql.DB) error {
+		return sqlDB.PingContext(ctx)
+	})
 }
 
-// buildQuery translates a dbase.Query into a GORM query chain.
-func (d *DB) buildQuery(ctx context.Context, q *dbase.Query) *gorm.DB {
-	tx := d.gdb.WithContext(ctx)
+// buildQuery translates a dbase.Query into a GORM query chain. Field names
+// may be given as Go field names or column names of model.
+func (d *DB) buildQuery(ctx context.Context, model any, q *dbase.Query) *gorm.DB {
+	tx := d.reader(ctx)
 
 	if q == nil {
 		return tx
 	}
 
+	stmt := &gorm.Statement{DB: d.gdb}
+	_ = stmt.Parse(model) // unknown models keep the names as given
+	column := func(field string) string {
+		if stmt.Schema == nil {
+			return field
+		}
+		if f := stmt.Schema.LookUpField(field); f != nil && f.DBName != "" {
+			return f.DBName
+		}
+		return field
+	}
+
 	for _, cond := range q.Conditions {
 		var clause string
 		var args []any
 
 		switch cond.Operator {
 		case dbase.OpIn:
-			clause = fmt.Sprintf("%s IN (?)", cond.Field)
+			clause = fmt.Sprintf("%s IN (?)", column(cond.Field))
 			args = []any{cond.Value}
 		case dbase.OpNotIn:
-			clause = fmt.Sprintf("%s NOT IN (?)", cond.Field)
+			clause = fmt.Sprintf("%s NOT IN (?)", column(cond.Field))
 			args = []any{cond.Value}
 		case dbase.OpIsNull:
-			clause = fmt.Sprintf("%s IS NULL", cond.Field)
+			clause = fmt.Sprintf("%s IS NULL", column(cond.Field))
 		case dbase.OpNotNull:
-			clause = fmt.Sprintf("%s IS NOT NULL", cond.Field)
+			clause = fmt.Sprintf("%s IS NOT NULL", column(cond.Field))
+		case dbase.OpPrefix:
+			prefix, ok := cond.Value.(string)
+			if !ok {
+				_ = tx.AddError(fmt.Errorf("%w: %s on %q requires a string", dbase.ErrInvalidModel, cond.Operator, cond.Field))
+			}
+			clause = fmt.Sprintf("%s LIKE ? ESCAPE %s", column(cond.Field), d.likeEscape())
+			args = []any{likeEscaper.Replace(prefix) + "%"}
 		default:
-			clause = fmt.Sprintf("%s %s ?", cond.Field, convertOperator(cond.Operator))
+			clause = fmt.Sprintf("%s %s ?", column(cond.Field), convertOperator(cond.Operator))
 			args = []any{cond.Value}
 		}
 
@@ -221,12 +445,27 @@ func (d *DB) buildQuery(ctx context.Context, q *dbase.Query) *gorm.DB {
 		}
 	}
 
+	if len(q.Fields) > 0 || q.Unique {
+		columns := make([]any, len(q.Fields))
+		for i, field := range q.Fields {
+			if stmt.Schema != nil && stmt.Schema.LookUpField(field) == nil {
+				_ = tx.AddError(fmt.Errorf("%w: %s has no field %q", dbase.ErrInvalidModel, stmt.Schema.Name, field))
+			}
+			columns[i] = column(field)
+		}
+		if q.Unique {
+			tx = tx.Distinct(columns...)
+		} else {
+			tx = tx.Select(columns[0], columns[1:]...)
+		}
+	}
+
 	for _, order := range q.OrderBy {
 		direction := "ASC"
 		if order.Descending {
 			direction = "DESC"
 		}
-		tx = tx.Order(fmt.Sprintf("%s %s", order.Field, direction))
+		tx = tx.Order(fmt.Sprintf("%s %s", column(order.Field), direction))
 	}
 
 	if q.Limit > 0 {
@@ -239,6 +478,32 @@ func (d *DB) buildQuery(ctx context.Context, q *dbase.Query) *gorm.DB {
 	return tx
 }
 
+// byID returns a condition on the primary key. Passing id to GORM directly
+// would treat string IDs as SQL.
+func byID(id any) clause.Expression {
+	return clause.Eq{Column: clause.PrimaryColumn, Value: id}
+}
+
+// convertError maps GORM errors to the dbase sentinel errors.
+func convertError(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return fmt.Errorf("%w: %v", dbase.ErrAlreadyExists, err)
+	}
+	return err
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern with backslashes.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// likeEscape returns the ESCAPE literal for a backslash, which MySQL
+// string literals escape themselves.
+func (d *DB) likeEscape() string {
+	if d.driverName == "mysql" {
+		return `'\\'`
+	}
+	return `'\'`
+}
+
 func convertOperator(op dbase.Operator) string {
 	switch op {
 	case dbase.OpEqual:
@@ -255,11 +520,12 @@ func convertOperator(op dbase.Operator) string {
 		return "<="
 	case dbase.OpLike:
 		return "LIKE"
-	case dbase.OpPrefix:
-		return "LIKE"
 	default:
 		return "="
 	}
 }
 
-var _ dbase.Database = (*DB)(nil)
+var (
+	_ dbase.Database   = (*DB)(nil)
+	_ dbase.Shutdowner = (*DB)(nil)
+)
@@ -1,10 +1,17 @@
 package gorm_test
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 
+	"github.com/nuln/dbase"
 	"github.com/nuln/dbase/dbasetest"
 	"github.com/nuln/dbase/gorm"
 )
@@ -18,3 +25,235 @@ func TestGormSQLite(t *testing.T) {
 
 	dbasetest.Suite(t, db)
 }
+
+type Note struct {
+	ID   uint `gorm:"primaryKey"`
+	Text string
+}
+
+func TestGormReplicas(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	primaryPath, replicaPath := filepath.Join(dir, "primary.db"), filepath.Join(dir, "replica.db")
+
+	// The replica is a separate file that never receives the primary's
+	// writes, which makes it visible where each statement was routed.
+	replica := dbase.MustOpen(&dbase.Config{Type: "sqlite", Path: replicaPath})
+	require.NoError(t, replica.Migrate(ctx, &Note{}))
+	require.NoError(t, replica.Create(ctx, &Note{ID: 100, Text: "replica"}))
+	require.NoError(t, replica.Close())
+
+	db, err := dbase.Open(&dbase.Config{
+		Type:          "sqlite",
+		Path:          primaryPath,
+		Replicas:      []string{replicaPath},
+		ReplicaPolicy: dbase.ReplicaRoundRobin,
+		Pool:          &dbase.PoolConfig{MaxOpenConns: 1},
+	})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, db.Migrate(ctx, &Note{}))
+	require.NoError(t, db.Migrate(ctx, &Note{}), "migrate must inspect the primary")
+	require.NoError(t, db.Ping(ctx))
+	require.NoError(t, db.Create(ctx, &Note{ID: 1, Text: "primary"}))
+
+	var notes []Note
+	require.NoError(t, db.Find(ctx, &notes, nil))
+	require.Len(t, notes, 1)
+	assert.Equal(t, "replica", notes[0].Text)
+	assert.ErrorIs(t, db.Get(ctx, &Note{}, 1), dbase.ErrNotFound)
+
+	primary := dbase.UsePrimary(ctx)
+	var n Note
+	require.NoError(t, db.Get(primary, &n, 1))
+	assert.Equal(t, "primary", n.Text)
+	count, err := db.Count(primary, &Note{}, dbase.Eq("text", "primary"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	err = db.Transaction(ctx, func(tx dbase.Database) error {
+		var inTx Note
+		return tx.Get(ctx, &inTx, 1)
+	})
+	require.NoError(t, err, "transactions read from the primary")
+
+	stats, err := dbase.StatsOf(ctx, db)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Pool.MaxOpen, "the limits of the primary and the replica add up")
+	sqlDB, err := db.(*gorm.DB).Gorm().DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(0)
+	stats, err = dbase.StatsOf(ctx, db)
+	require.NoError(t, err)
+	assert.Zero(t, stats.Pool.MaxOpen, "one unlimited pool makes the total unlimited")
+
+	_, err = dbase.Open(&dbase.Config{Type: "sqlite", Path: primaryPath, Replicas: []string{replicaPath}, ReplicaPolicy: "nearest"})
+	assert.Error(t, err)
+}
+
+type accountV1 struct {
+	ID    uint `gorm:"primaryKey"`
+	Name  string
+	Score int
+	Old   string
+}
+
+func (accountV1) TableName() string { return "accounts" }
+
+type accountV2 struct {
+	ID    uint   `gorm:"primaryKey"`
+	Name  string `gorm:"index"`
+	Score string
+	Email string
+}
+
+func (accountV2) TableName() string { return "accounts" }
+
+func TestGormPlanMigrate(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.New("sqlite", sqlite.Open(":memory:"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	plan, err := dbase.PlanMigrate(ctx, db, &accountV1{})
+	require.NoError(t, err)
+	require.Len(t, plan.Changes, 1)
+	assert.Equal(t, dbase.ChangeCreateTable, plan.Changes[0].Kind)
+	assert.Contains(t, plan.DDL()[0], "CREATE TABLE `accounts`")
+	assert.False(t, db.Gorm().Migrator().HasTable("accounts"), "planning must not apply changes")
+
+	require.NoError(t, db.Migrate(ctx, &accountV1{}))
+	plan, err = db.PlanMigrate(ctx, &accountV1{})
+	require.NoError(t, err)
+	assert.True(t, plan.Empty())
+
+	plan, err = db.PlanMigrate(ctx, &accountV2{})
+	require.NoError(t, err)
+	kinds := make(map[dbase.ChangeKind]dbase.SchemaChange)
+	for _, c := range plan.Changes {
+		kinds[c.Kind] = c
+	}
+	require.Len(t, kinds, 4)
+	assert.Equal(t, "score", kinds[dbase.ChangeAlterColumn].Column)
+	assert.Equal(t, []string{"ALTER TABLE `accounts` ADD `email` text"}, kinds[dbase.ChangeAddColumn].DDL)
+	assert.Equal(t, "old", kinds[dbase.ChangeExtraColumn].Column)
+	assert.Equal(t, "idx_accounts_name", kinds[dbase.ChangeCreateIndex].Index)
+	assert.False(t, db.Gorm().Migrator().HasColumn(&accountV2{}, "email"))
+}
+
+func TestGormOptions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "app.db")
+	db, err := dbase.OpenURL("sqlite://" + path + "?journal_mode=wal&busy_timeout=2s&foreign_keys=true" +
+		"&table_prefix=app_&singular_table=true&log_level=silent")
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	gdb := db.(*gorm.DB).Gorm()
+	var journalMode string
+	var busyTimeout, foreignKeys int
+	require.NoError(t, gdb.Raw("PRAGMA journal_mode").Scan(&journalMode).Error)
+	require.NoError(t, gdb.Raw("PRAGMA busy_timeout").Scan(&busyTimeout).Error)
+	require.NoError(t, gdb.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error)
+	assert.Equal(t, "wal", journalMode)
+	assert.Equal(t, 2000, busyTimeout)
+	assert.Equal(t, 1, foreignKeys)
+
+	require.NoError(t, db.Migrate(ctx, &Note{}))
+	assert.True(t, gdb.Migrator().HasTable("app_note"))
+
+	_, err = dbase.Open(&dbase.Config{Type: "postgres", DSN: "host=db", Options: map[string]any{"journal_mode": "wal"}})
+	assert.ErrorContains(t, err, `unknown option "journal_mode" for driver "postgres"`)
+	_, err = dbase.Open(&dbase.Config{Type: "sqlite", Path: path, Options: map[string]any{"log_level": "loud"}})
+	assert.ErrorContains(t, err, `unknown log level "loud"`)
+}
+
+func TestGormShutdown(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.New("sqlite", sqlite.Open(filepath.Join(t.TempDir(), "app.db")))
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(ctx, &Note{}))
+
+	// A transaction still running at the deadline does not keep the
+	// database open.
+	started, release := make(chan struct{}), make(chan struct{})
+	txDone := make(chan error)
+	go func() {
+		txDone <- db.Transaction(ctx, func(tx dbase.Database) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	deadline, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, db.Shutdown(deadline), context.DeadlineExceeded)
+	assert.ErrorIs(t, db.Create(ctx, &Note{Text: "late"}), dbase.ErrClosed)
+	close(release)
+	<-txDone
+
+	_, err = db.Count(ctx, &Note{}, nil)
+	assert.ErrorIs(t, err, dbase.ErrClosed)
+	assert.ErrorIs(t, db.Ping(ctx), dbase.ErrClosed)
+	assert.NoError(t, db.Close())
+}
+
+func TestGormRaw(t *testing.T) {
+	ctx := context.Background()
+	db, err := dbase.Open(&dbase.Config{Type: "sqlite", Path: filepath.Join(t.TempDir(), "app.db")})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	require.NoError(t, db.Migrate(ctx, &Note{}))
+
+	n, err := dbase.Exec(ctx, db, "INSERT INTO notes (id, text) VALUES (?, ?), (?, ?)", 1, "a", 2, "b")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	var notes []Note
+	require.NoError(t, dbase.Raw(ctx, db, &notes, "SELECT * FROM notes ORDER BY id DESC"))
+	assert.Equal(t, []Note{{ID: 2, Text: "b"}, {ID: 1, Text: "a"}}, notes)
+	var row map[string]any
+	require.NoError(t, dbase.Raw(ctx, db, &row, "SELECT COUNT(*) AS n FROM notes"))
+	assert.EqualValues(t, 2, row["n"])
+
+	// Statements inside a transaction are rolled back with it.
+	err = db.Transaction(ctx, func(tx dbase.Database) error {
+		if _, err := dbase.Exec(ctx, tx, "DELETE FROM notes"); err != nil {
+			return err
+		}
+		var count int64
+		require.NoError(t, dbase.Raw(ctx, tx, &count, "SELECT COUNT(*) FROM notes"))
+		assert.Zero(t, count)
+		return errors.New("roll back")
+	})
+	require.EqualError(t, err, "roll back")
+	count, err := db.Count(ctx, &Note{}, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+}
+
+func TestGormCountSelect(t *testing.T) {
+	ctx := context.Background()
+	db, err := dbase.Open(&dbase.Config{Type: "sqlite", Path: filepath.Join(t.TempDir(), "app.db")})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	type Contact struct {
+		ID   uint `gorm:"primaryKey"`
+		Nick *string
+	}
+	require.NoError(t, db.Migrate(ctx, &Contact{}))
+	nick := "x"
+	require.NoError(t, db.Create(ctx, &Contact{Nick: &nick}))
+	require.NoError(t, db.Create(ctx, &Contact{}))
+
+	// The projection must not turn the count into COUNT(nick).
+	count, err := db.Count(ctx, &Contact{}, dbase.NewQuery().Select("Nick"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, count)
+
+	_, err = db.Count(ctx, &Contact{}, dbase.NewQuery().Distinct("Missing"))
+	assert.ErrorIs(t, err, dbase.ErrInvalidModel)
+}
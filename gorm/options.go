@@ -0,0 +1,110 @@
+package gorm
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// Options configures a GORM-backed database. The drivers read them from
+// [dbase.Config.Options] by the keys in their option tags.
+type Options struct {
+	// LogLevel sets GORM's logging: "silent", "error", "warn" (default) or
+	// "info", which logs every statement.
+	LogLevel string `option:"log_level"`
+
+	// PrepareStmt caches prepared statements for reuse.
+	PrepareStmt bool `option:"prepare_stmt"`
+
+	// SkipDefaultTransaction stops GORM from wrapping each single write in
+	// a transaction.
+	SkipDefaultTransaction bool `option:"skip_default_transaction"`
+
+	// TablePrefix is prepended to every table name.
+	TablePrefix string `option:"table_prefix"`
+
+	// SingularTable names tables after the model, e.g. "user" instead of
+	// "users".
+	SingularTable bool `option:"singular_table"`
+}
+
+// SQLiteOptions configures the "sqlite" driver. Besides [Options] it sets
+// pragmas on every connection, including those to replicas.
+type SQLiteOptions struct {
+	Options
+
+	// JournalMode sets the journal mode, e.g. "wal" so readers do not
+	// block the writer.
+	JournalMode string `option:"journal_mode"`
+
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection before failing with "database is locked".
+	BusyTimeout time.Duration `option:"busy_timeout"`
+
+	// ForeignKeys enforces foreign key constraints.
+	ForeignKeys bool `option:"foreign_keys"`
+
+	// Synchronous sets how often SQLite syncs to disk: "off", "normal",
+	// "full" or "extra".
+	Synchronous string `option:"synchronous"`
+}
+
+var logLevels = map[string]logger.LogLevel{
+	"silent": logger.Silent,
+	"error":  logger.Error,
+	"warn":   logger.Warn,
+	"info":   logger.Info,
+}
+
+// config returns the GORM configuration for opts.
+func (opts Options) config() (*gorm.Config, error) {
+	c := &gorm.Config{
+		TranslateError:         true,
+		PrepareStmt:            opts.PrepareStmt,
+		SkipDefaultTransaction: opts.SkipDefaultTransaction,
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   opts.TablePrefix,
+			SingularTable: opts.SingularTable,
+		},
+	}
+	if opts.LogLevel != "" {
+		level, ok := logLevels[strings.ToLower(opts.LogLevel)]
+		if !ok {
+			return nil, fmt.Errorf("dbase/gorm: unknown log level %q", opts.LogLevel)
+		}
+		c.Logger = logger.Default.LogMode(level)
+	}
+	return c, nil
+}
+
+// dsn adds the pragmas of opts to an SQLite path as parameters of the
+// go-sqlite3 driver.
+func (opts SQLiteOptions) dsn(path string) string {
+	params := url.Values{}
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	if opts.ForeignKeys {
+		params.Set("_foreign_keys", "1")
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", opts.Synchronous)
+	}
+	if len(params) == 0 {
+		return path
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + params.Encode()
+}
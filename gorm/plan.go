@@ -0,0 +1,162 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/nuln/dbase"
+)
+
+// PlanMigrate implements [dbase.MigrationPlanner]. It follows the steps of
+// AutoMigrate, inspecting the live schema and recording the statements it
+// would run instead of executing them. Foreign keys and check constraints
+// are not reported.
+func (d *DB) PlanMigrate(ctx context.Context, models ...any) (*dbase.MigrationPlan, error) {
+	if !d.gate.Enter() {
+		return nil, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	live := d.gdb.WithContext(ctx).Clauses(dbresolver.Write).Migrator()
+	rec := &recorder{Interface: logger.Discard}
+	dry := d.gdb.Session(&gorm.Session{DryRun: true, Logger: rec, Context: ctx})
+	dry.Dialector = planDialector{Dialector: dry.Dialector, rebuild: d.driverName == "sqlite"}
+
+	// capture runs fn on a dry-run migrator and returns the statements it
+	// would have executed.
+	capture := func(fn func(m gorm.Migrator) error) ([]string, error) {
+		n := len(rec.statements)
+		err := fn(dry.Migrator())
+		return slices.Clone(rec.statements[n:]), err
+	}
+
+	plan := &dbase.MigrationPlan{Driver: d.driverName}
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: d.gdb}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+		}
+		table := stmt.Schema.Table
+
+		if !live.HasTable(model) {
+			ddl, err := capture(func(m gorm.Migrator) error { return m.CreateTable(model) })
+			if err != nil {
+				return nil, fmt.Errorf("dbase/gorm: plan %s: %w", table, err)
+			}
+			plan.Changes = append(plan.Changes, dbase.SchemaChange{
+				Kind: dbase.ChangeCreateTable, Table: table, DDL: ddl,
+			})
+			continue
+		}
+
+		columnTypes, err := live.ColumnTypes(model)
+		if err != nil {
+			return nil, fmt.Errorf("dbase/gorm: plan %s: %w", table, err)
+		}
+		columns := make(map[string]gorm.ColumnType, len(columnTypes))
+		for _, ct := range columnTypes {
+			columns[strings.ToLower(ct.Name())] = ct
+		}
+
+		for _, dbName := range stmt.Schema.DBNames {
+			field := stmt.Schema.FieldsByDBName[dbName]
+			want := live.FullDataTypeOf(field).SQL
+			ct, ok := columns[strings.ToLower(dbName)]
+			delete(columns, strings.ToLower(dbName))
+			if !ok {
+				ddl, err := capture(func(m gorm.Migrator) error { return m.AddColumn(model, dbName) })
+				if err != nil {
+					return nil, fmt.Errorf("dbase/gorm: plan %s.%s: %w", table, dbName, err)
+				}
+				plan.Changes = append(plan.Changes, dbase.SchemaChange{
+					Kind: dbase.ChangeAddColumn, Table: table, Column: dbName, To: want, DDL: ddl,
+				})
+				continue
+			}
+
+			ddl, err := capture(func(m gorm.Migrator) error { return m.MigrateColumn(model, field, ct) })
+			if err != nil {
+				return nil, fmt.Errorf("dbase/gorm: plan %s.%s: %w", table, dbName, err)
+			}
+			if len(ddl) > 0 {
+				plan.Changes = append(plan.Changes, dbase.SchemaChange{
+					Kind: dbase.ChangeAlterColumn, Table: table, Column: dbName,
+					From: ct.DatabaseTypeName(), To: want, DDL: ddl,
+				})
+			}
+		}
+
+		for _, ct := range columnTypes {
+			if _, extra := columns[strings.ToLower(ct.Name())]; extra {
+				plan.Changes = append(plan.Changes, dbase.SchemaChange{
+					Kind: dbase.ChangeExtraColumn, Table: table, Column: ct.Name(), From: ct.DatabaseTypeName(),
+				})
+			}
+		}
+
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if live.HasIndex(model, idx.Name) {
+				continue
+			}
+			ddl, err := capture(func(m gorm.Migrator) error { return m.CreateIndex(model, idx.Name) })
+			if err != nil {
+				return nil, fmt.Errorf("dbase/gorm: plan index %s: %w", idx.Name, err)
+			}
+			plan.Changes = append(plan.Changes, dbase.SchemaChange{
+				Kind: dbase.ChangeCreateIndex, Table: table, Index: idx.Name, DDL: ddl,
+			})
+		}
+	}
+	return plan, nil
+}
+
+// recorder is a GORM logger that keeps the SQL of every traced statement.
+type recorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *recorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r *recorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	if sql, _ := fc(); sql != "" {
+		r.statements = append(r.statements, sql)
+	}
+}
+
+// planDialector wraps the dialector of a dry-run session. When rebuild is
+// set (SQLite), AlterColumn is not run, because it rebuilds the table from
+// the live DDL, which a dry run cannot read; the planned rebuild is noted
+// as an SQL comment instead.
+type planDialector struct {
+	gorm.Dialector
+	rebuild bool
+}
+
+func (p planDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return planMigrator{Migrator: p.Dialector.Migrator(db), db: db, rebuild: p.rebuild}
+}
+
+type planMigrator struct {
+	gorm.Migrator
+	db      *gorm.DB
+	rebuild bool
+}
+
+func (m planMigrator) AlterColumn(value any, field string) error {
+	if !m.rebuild {
+		return m.Migrator.AlterColumn(value, field)
+	}
+	m.db.Logger.Trace(m.db.Statement.Context, time.Now(), func() (string, int64) {
+		return "-- rebuild table to alter column " + field, 0
+	}, nil)
+	return nil
+}
+
+var _ dbase.MigrationPlanner = (*DB)(nil)
@@ -0,0 +1,50 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"gorm.io/gorm"
+
+	"github.com/nuln/dbase"
+)
+
+// Project implements [dbase.Projector], selecting only query.Fields.
+func (d *DB) Project(ctx context.Context, model, dest any, query *dbase.Query) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+
+	tx := d.buildQuery(ctx, model, query).Model(model)
+	switch dest := dest.(type) {
+	case *map[string]any:
+		rows, err := scanMaps(tx.Limit(1).Rows())
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return dbase.ErrNotFound
+		}
+		*dest = rows[0]
+		return nil
+	case *[]map[string]any:
+		rows, err := scanMaps(tx.Rows())
+		*dest = rows
+		return err
+	}
+
+	if v := reflect.ValueOf(dest); v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Slice {
+		return tx.Find(dest).Error
+	}
+	// Take rather than First: ordering by the primary key would break
+	// SELECT DISTINCT on other columns.
+	err := tx.Take(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return dbase.ErrNotFound
+	}
+	return err
+}
+
+var _ dbase.Projector = (*DB)(nil)
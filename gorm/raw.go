@@ -0,0 +1,84 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nuln/dbase"
+)
+
+// Raw implements [dbase.RawQuerier]. Outside transactions the query goes
+// to a replica when it is a SELECT, unless ctx was marked with
+// [dbase.UsePrimary]. Maps receive the driver's values, with []byte
+// converted to string; like other destinations they are left unchanged
+// when there are no rows.
+func (d *DB) Raw(ctx context.Context, dest any, query string, args ...any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+
+	tx := d.reader(ctx).Raw(query, args...)
+	switch dest := dest.(type) {
+	case *map[string]any:
+		rows, err := scanMaps(tx.Rows())
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 {
+			*dest = rows[0]
+		}
+		return nil
+	case *[]map[string]any:
+		rows, err := scanMaps(tx.Rows())
+		*dest = rows
+		return err
+	}
+	return convertError(tx.Scan(dest).Error)
+}
+
+// Exec implements [dbase.RawQuerier].
+func (d *DB) Exec(ctx context.Context, query string, args ...any) (int64, error) {
+	if !d.gate.Enter() {
+		return 0, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	res := d.gdb.WithContext(ctx).Exec(query, args...)
+	return res.RowsAffected, convertError(res.Error)
+}
+
+// scanMaps reads rows into maps keyed by column name.
+func scanMaps(rows *sql.Rows, err error) ([]map[string]any, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []map[string]any{}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+var _ dbase.RawQuerier = (*DB)(nil)
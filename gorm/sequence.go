@@ -0,0 +1,46 @@
+package gorm
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/nuln/dbase"
+)
+
+// SyncSequence implements [dbase.Sequencer] for PostgreSQL, whose serial
+// and identity sequences stay put when rows are inserted with explicit IDs:
+// the sequence of the auto-increment primary key is set to the highest key
+// with setval. SQLite and MySQL move their counters on such inserts, so
+// nothing is done for them.
+func (d *DB) SyncSequence(ctx context.Context, model any) error {
+	if !d.gate.Enter() {
+		return dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	if d.driverName != "postgres" {
+		return nil
+	}
+	stmt := &gorm.Statement{DB: d.gdb}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil || !pk.AutoIncrement {
+		return nil
+	}
+
+	sql := fmt.Sprintf(`SELECT setval(seq, high) FROM (
+	SELECT pg_get_serial_sequence(?, ?)::regclass AS seq, (SELECT MAX(%s) FROM %s) AS high
+) AS t WHERE seq IS NOT NULL AND high > COALESCE(pg_sequence_last_value(seq), 0)`,
+		stmt.Quote(pk.DBName), stmt.Quote(stmt.Schema.Table))
+	err := d.gdb.WithContext(ctx).Clauses(dbresolver.Write).Exec(sql, stmt.Quote(stmt.Schema.Table), pk.DBName).Error
+	if err != nil {
+		return fmt.Errorf("dbase/gorm: sync sequence of %s: %w", stmt.Schema.Table, err)
+	}
+	return nil
+}
+
+var _ dbase.Sequencer = (*DB)(nil)
@@ -0,0 +1,60 @@
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"gorm.io/plugin/dbresolver"
+
+	"github.com/nuln/dbase"
+)
+
+// sizeQueries return the size of the current database in bytes, by driver.
+var sizeQueries = map[string]string{
+	"sqlite":   "SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()",
+	"postgres": "SELECT pg_database_size(current_database())",
+	"mysql": "SELECT COALESCE(SUM(data_length + index_length), 0) FROM information_schema.tables " +
+		"WHERE table_schema = DATABASE()",
+}
+
+// Stats implements [dbase.StatsReporter]. The pool statistics add up the
+// primary and replica pools, with MaxOpen 0 if any of them is unlimited;
+// the size is that of the primary, and is left zero when the user may not
+// query it.
+func (d *DB) Stats(ctx context.Context) (*dbase.Stats, error) {
+	if !d.gate.Enter() {
+		return nil, dbase.ErrClosed
+	}
+	defer d.gate.Leave()
+	pool := &dbase.PoolStats{}
+	unlimited := false
+	err := d.eachPool(func(sqlDB *sql.DB) error {
+		s := sqlDB.Stats()
+		pool.MaxOpen += s.MaxOpenConnections
+		unlimited = unlimited || s.MaxOpenConnections == 0
+		pool.Open += s.OpenConnections
+		pool.InUse += s.InUse
+		pool.Idle += s.Idle
+		pool.WaitCount += s.WaitCount
+		pool.WaitDuration += s.WaitDuration
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("dbase/gorm: stats: %w", err)
+	}
+	if unlimited {
+		pool.MaxOpen = 0
+	}
+
+	stats := &dbase.Stats{Driver: d.driverName, Pool: pool}
+	if q, ok := sizeQueries[d.driverName]; ok {
+		var size sql.NullInt64
+		if err := d.gdb.WithContext(ctx).Clauses(dbresolver.Write).Raw(q).Scan(&size).Error; err == nil {
+			stats.Size = size.Int64
+		}
+	}
+	return stats, nil
+}
+
+var _ dbase.StatsReporter = (*DB)(nil)
@@ -0,0 +1,156 @@
+// Package health provides HTTP handlers that report the liveness and
+// readiness of named databases as JSON, for orchestrator probes and load
+// balancers.
+//
+// The readiness handler pings every database and answers 503 when one is
+// down; the liveness handler answers 503 only once a database is closed,
+// since restarting the process does not help an unreachable server.
+//
+//	h := health.New(map[string]dbase.Database{"main": db}, health.Options{})
+//	mux.Handle("/readyz", h)
+//	mux.Handle("/livez", h.Live())
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nuln/dbase"
+)
+
+// Status is the state of a database or of all of them.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Report is the JSON body written by the handlers.
+type Report struct {
+	Status    Status                     `json:"status"`
+	Databases map[string]*DatabaseReport `json:"databases"`
+}
+
+// DatabaseReport is the state of one database.
+type DatabaseReport struct {
+	Status  Status `json:"status"`
+	Driver  string `json:"driver"`
+	Latency string `json:"latency"` // of the ping, e.g. "1.2ms"
+	Error   string `json:"error,omitempty"`
+
+	// Stats are the statistics of the database, if [Options.Stats] is set
+	// and its driver reports them; see [dbase.StatsOf].
+	Stats *dbase.Stats `json:"stats,omitempty"`
+
+	closed bool
+}
+
+// Options configures a [Handler].
+type Options struct {
+	// Timeout bounds the ping of each database. It defaults to 5 seconds.
+	Timeout time.Duration
+
+	// Stats adds the statistics of each database to its report. They are
+	// off by default because SQL drivers query the database size for them,
+	// which is too slow for frequent probes on large servers.
+	Stats bool
+}
+
+// Handler is the readiness handler for a fixed set of databases.
+type Handler struct {
+	dbs  map[string]dbase.Database
+	opts Options
+}
+
+// New returns a Handler checking dbs by name.
+func New(dbs map[string]dbase.Database, opts Options) *Handler {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &Handler{dbs: maps.Clone(dbs), opts: opts}
+}
+
+// Check pings every database concurrently and collects its statistics if
+// enabled.
+func (h *Handler) Check(ctx context.Context) *Report {
+	report := &Report{Status: StatusUp, Databases: make(map[string]*DatabaseReport, len(h.dbs))}
+	var wg sync.WaitGroup
+	for _, name := range slices.Sorted(maps.Keys(h.dbs)) {
+		r := &DatabaseReport{}
+		report.Databases[name] = r
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.check(ctx, h.dbs[name], r)
+		}()
+	}
+	wg.Wait()
+	for _, r := range report.Databases {
+		if r.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (h *Handler) check(ctx context.Context, db dbase.Database, r *DatabaseReport) {
+	ctx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	defer cancel()
+
+	r.Driver = db.Driver()
+	start := time.Now()
+	err := db.Ping(ctx)
+	r.Latency = time.Since(start).String()
+	if err != nil {
+		r.Status, r.Error, r.closed = StatusDown, err.Error(), errors.Is(err, dbase.ErrClosed)
+		return
+	}
+	r.Status = StatusUp
+	if !h.opts.Stats {
+		return
+	}
+
+	if stats, err := dbase.StatsOf(ctx, db); err == nil {
+		r.Stats = stats
+	}
+}
+
+// ServeHTTP reports readiness: 200 when every database is up, 503
+// otherwise.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+	code := http.StatusOK
+	if report.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+	write(w, code, report)
+}
+
+// Live returns the liveness handler. It reports the same checks as the
+// readiness handler but answers 503 only when a database is closed.
+func (h *Handler) Live() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := h.Check(r.Context())
+		code := http.StatusOK
+		for _, db := range report.Databases {
+			if db.closed {
+				code = http.StatusServiceUnavailable
+			}
+		}
+		write(w, code, report)
+	})
+}
+
+func write(w http.ResponseWriter, code int, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,61 @@
+package health_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	_ "github.com/nuln/dbase/bolt"
+	_ "github.com/nuln/dbase/gorm"
+	"github.com/nuln/dbase/health"
+)
+
+func TestHandler(t *testing.T) {
+	dir := t.TempDir()
+	kv, err := dbase.Open(&dbase.Config{Type: "bolt", Path: filepath.Join(dir, "kv.db")})
+	require.NoError(t, err)
+	defer func() { _ = kv.Close() }()
+	sql, err := dbase.Open(&dbase.Config{Type: "sqlite", Path: filepath.Join(dir, "app.db"), Lazy: true})
+	require.NoError(t, err)
+	defer func() { _ = sql.Close() }()
+
+	h := health.New(map[string]dbase.Database{"kv": kv, "sql": sql}, health.Options{Stats: true})
+	get := func(handler http.Handler) (int, health.Report) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	code, report := get(h)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, health.StatusUp, report.Status)
+	require.NotNil(t, report.Databases["kv"].Stats)
+	assert.Positive(t, report.Databases["kv"].Stats.Size)
+	assert.NotNil(t, report.Databases["kv"].Stats.Bolt)
+	assert.Equal(t, "sqlite", report.Databases["sql"].Driver)
+	require.NotNil(t, report.Databases["sql"].Stats)
+	assert.NotNil(t, report.Databases["sql"].Stats.Pool)
+
+	// Statistics are opt-in.
+	_, report = get(health.New(map[string]dbase.Database{"kv": kv, "sql": sql}, health.Options{}))
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Nil(t, report.Databases["kv"].Stats)
+	assert.Nil(t, report.Databases["sql"].Stats)
+
+	// A closed database fails readiness and liveness.
+	require.NoError(t, kv.Close())
+	code, report = get(h)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, health.StatusDown, report.Databases["kv"].Status)
+	assert.Equal(t, health.StatusUp, report.Databases["sql"].Status)
+	code, _ = get(h.Live())
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+}
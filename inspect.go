@@ -0,0 +1,50 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+)
+
+// TableInfo describes a table or bucket found by an [Inspector].
+type TableInfo struct {
+	Name    string      `json:"name"`
+	Count   int64       `json:"count"`
+	Indexes []IndexInfo `json:"indexes,omitempty"`
+}
+
+// IndexInfo describes an index of a [TableInfo].
+type IndexInfo struct {
+	Field string `json:"field"`
+
+	// Kind is "index" or "unique", or empty if unknown.
+	Kind string `json:"kind,omitempty"`
+}
+
+// Inspector is implemented by drivers that can list and read stored data
+// without the Go model types, as used by the dbase shell.
+type Inspector interface {
+	// Tables lists the tables or buckets, sorted by name.
+	Tables(ctx context.Context) ([]TableInfo, error)
+
+	// Records returns the records of table matching query, decoded into
+	// maps.
+	Records(ctx context.Context, table string, query *Query) ([]map[string]any, error)
+
+	// CountRecords returns the number of records of table matching the
+	// conditions of query, without keeping them.
+	CountRecords(ctx context.Context, table string, query *Query) (int64, error)
+}
+
+// Inspect returns db as an [Inspector], or [ErrNotSupported] if the driver
+// does not implement it.
+func Inspect(db Database) (Inspector, error) {
+	db, err := connected(context.Background(), db)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := db.(Inspector)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s cannot be inspected", ErrNotSupported, db.Driver())
+	}
+	return i, nil
+}
@@ -0,0 +1,57 @@
+// Package atomicfile replaces files so that readers see either the old or
+// the new content, never a partial write.
+package atomicfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Replace calls fn with the path of a temporary file in the directory of
+// path, which fn must create, and then renames it to path. The temporary
+// file is removed if fn fails.
+func Replace(path string, fn func(tmp string) error) error {
+	dir, base := filepath.Split(path)
+	f, err := os.CreateTemp(dir, "."+base+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_ = f.Close()
+	_ = os.Remove(tmp) // fn creates it, e.g. SQLite's VACUUM INTO requires a new file
+	defer func() { _ = os.Remove(tmp) }()
+
+	if err := fn(tmp); err != nil {
+		return err
+	}
+	if err := syncFile(tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Write replaces path with the content written by fn.
+func Write(path string, fn func(w io.Writer) error) error {
+	return Replace(path, func(tmp string) error {
+		f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		if err := fn(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+func syncFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("sync %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	return f.Sync()
+}
@@ -0,0 +1,442 @@
+// Package eval evaluates [dbase.Query] conditions and ordering in memory.
+//
+// It is used by drivers that cannot push a query down to the storage engine
+// (or can only do so partially) and by wrappers that merge results from
+// several databases.
+package eval
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// Getter returns the value of the named field of a record and whether the
+// field exists.
+type Getter func(field string) (any, bool)
+
+// StructGetter returns a [Getter] reading fields of the struct v described by m.
+func StructGetter(m *schema.Model, v reflect.Value) Getter {
+	return func(field string) (any, bool) {
+		f := m.Field(field)
+		if f == nil {
+			return nil, false
+		}
+		return f.Interface(v), true
+	}
+}
+
+// MapGetter returns a [Getter] reading keys of a decoded record. Keys are
+// matched exactly first, then case-insensitively or by snake_case name.
+func MapGetter(rec map[string]any) Getter {
+	return func(field string) (any, bool) {
+		if v, ok := rec[field]; ok {
+			return v, true
+		}
+		snake := schema.ToSnake(field)
+		for k, v := range rec {
+			if strings.EqualFold(k, field) || k == snake {
+				return v, true
+			}
+		}
+		return nil, false
+	}
+}
+
+// Match reports whether a record satisfies conds. Conditions are combined
+// with SQL precedence: AND binds tighter than OR, so "a AND b OR c" is
+// evaluated as "(a AND b) OR c".
+func Match(get Getter, conds []dbase.Condition) (bool, error) {
+	if len(conds) == 0 {
+		return true, nil
+	}
+
+	group := true
+	for i, cond := range conds {
+		if i > 0 && cond.Or {
+			if group {
+				return true, nil
+			}
+			group = true
+		}
+		if !group {
+			continue
+		}
+		ok, err := matchOne(get, cond)
+		if err != nil {
+			return false, err
+		}
+		group = ok
+	}
+	return group, nil
+}
+
+func matchOne(get Getter, cond dbase.Condition) (bool, error) {
+	value, ok := get(cond.Field)
+	if !ok {
+		return false, fmt.Errorf("unknown field %q", cond.Field)
+	}
+	value = deref(value)
+
+	switch cond.Operator {
+	case dbase.OpIsNull:
+		return value == nil, nil
+	case dbase.OpNotNull:
+		return value != nil, nil
+	case dbase.OpIn, dbase.OpNotIn:
+		found, err := contains(cond.Value, value)
+		if err != nil {
+			return false, err
+		}
+		return found == (cond.Operator == dbase.OpIn), nil
+	case dbase.OpLike, dbase.OpPrefix:
+		s, ok := value.(string)
+		pattern, pok := deref(cond.Value).(string)
+		if !pok {
+			return false, fmt.Errorf("%s on %q requires a string pattern", cond.Operator, cond.Field)
+		}
+		if !ok {
+			return false, nil
+		}
+		if cond.Operator == dbase.OpPrefix {
+			return strings.HasPrefix(s, pattern), nil
+		}
+		return likeRegexp(pattern).MatchString(s), nil
+	}
+
+	if value == nil || deref(cond.Value) == nil {
+		// Comparisons with NULL are never true, as in SQL.
+		return false, nil
+	}
+	c, err := Compare(value, cond.Value)
+	if err != nil {
+		return false, fmt.Errorf("field %q: %w", cond.Field, err)
+	}
+
+	switch cond.Operator {
+	case dbase.OpEqual:
+		return c == 0, nil
+	case dbase.OpNotEqual:
+		return c != 0, nil
+	case dbase.OpGreater:
+		return c > 0, nil
+	case dbase.OpGreaterEqual:
+		return c >= 0, nil
+	case dbase.OpLess:
+		return c < 0, nil
+	case dbase.OpLessEqual:
+		return c <= 0, nil
+	}
+	return false, fmt.Errorf("unsupported operator %q", cond.Operator)
+}
+
+// contains reports whether the slice list holds an element equal to value.
+func contains(list, value any) (bool, error) {
+	lv := reflect.ValueOf(list)
+	if lv.Kind() != reflect.Slice && lv.Kind() != reflect.Array {
+		return false, fmt.Errorf("in/not_in requires a slice, got %T", list)
+	}
+	if value == nil {
+		return false, nil
+	}
+	for i := 0; i < lv.Len(); i++ {
+		c, err := Compare(value, lv.Index(i).Interface())
+		if err == nil && c == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var likeCache sync.Map // string -> *regexp.Regexp
+
+// likeRegexp translates an SQL LIKE pattern ('%' and '_' wildcards) into
+// an anchored regular expression.
+func likeRegexp(pattern string) *regexp.Regexp {
+	if re, ok := likeCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	re := regexp.MustCompile(b.String())
+	likeCache.Store(pattern, re)
+	return re
+}
+
+// Compare compares two values, returning -1, 0 or +1. Numbers of any kind
+// are compared numerically; strings, byte slices, booleans and times are
+// compared naturally. nil sorts before everything else.
+func Compare(a, b any) (int, error) {
+	a, b = deref(a), deref(b)
+	switch {
+	case a == nil && b == nil:
+		return 0, nil
+	case a == nil:
+		return -1, nil
+	case b == nil:
+		return 1, nil
+	}
+
+	if an, ok := number(a); ok {
+		if bn, ok := number(b); ok {
+			return an.cmp(bn), nil
+		}
+	}
+
+	switch av := a.(type) {
+	case string:
+		if bv, ok := asString(b); ok {
+			return strings.Compare(av, bv), nil
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv), nil
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0, nil
+			case !av:
+				return -1, nil
+			}
+			return 1, nil
+		}
+	case time.Time:
+		switch bv := b.(type) {
+		case time.Time:
+			return av.Compare(bv), nil
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, bv); err == nil {
+				return av.Compare(t), nil
+			}
+		}
+	}
+
+	// Named string types (e.g. type Status string) and times decoded from
+	// JSON arrive as strings.
+	if as, ok := asString(a); ok {
+		if bt, ok := b.(time.Time); ok {
+			if t, err := time.Parse(time.RFC3339Nano, as); err == nil {
+				return t.Compare(bt), nil
+			}
+		}
+		if bs, ok := asString(b); ok {
+			return strings.Compare(as, bs), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+func asString(v any) (string, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.String {
+		return rv.String(), true
+	}
+	return "", false
+}
+
+// deref follows pointers, returning nil for nil pointers.
+func deref(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+	return rv.Interface()
+}
+
+// num is a normalized numeric value.
+type num struct {
+	kind byte // 'i', 'u' or 'f'
+	i    int64
+	u    uint64
+	f    float64
+}
+
+func number(v any) (num, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return num{kind: 'i', i: rv.Int()}, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return num{kind: 'u', u: rv.Uint()}, true
+	case reflect.Float32, reflect.Float64:
+		return num{kind: 'f', f: rv.Float()}, true
+	}
+	return num{}, false
+}
+
+func (a num) cmp(b num) int {
+	switch {
+	case a.kind == 'i' && b.kind == 'i':
+		return cmp3(a.i < b.i, a.i > b.i)
+	case a.kind == 'u' && b.kind == 'u':
+		return cmp3(a.u < b.u, a.u > b.u)
+	case a.kind == 'i' && b.kind == 'u':
+		if a.i < 0 {
+			return -1
+		}
+		return cmp3(uint64(a.i) < b.u, uint64(a.i) > b.u)
+	case a.kind == 'u' && b.kind == 'i':
+		return -b.cmp(a)
+	}
+	af, bf := a.float(), b.float()
+	return cmp3(af < bf, af > bf)
+}
+
+func (a num) float() float64 {
+	switch a.kind {
+	case 'i':
+		return float64(a.i)
+	case 'u':
+		return float64(a.u)
+	}
+	return a.f
+}
+
+func cmp3(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// Sort sorts items in place by orders. Items whose fields cannot be
+// compared keep their relative order.
+func Sort[T any](items []T, orders []dbase.Order, getter func(T) Getter) {
+	if len(orders) == 0 {
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return Less(getter(items[i]), getter(items[j]), orders)
+	})
+}
+
+// Less reports whether record a sorts before record b under orders.
+func Less(a, b Getter, orders []dbase.Order) bool {
+	for _, o := range orders {
+		av, _ := a(o.Field)
+		bv, _ := b(o.Field)
+		c, err := Compare(av, bv)
+		if err != nil || c == 0 {
+			continue
+		}
+		if o.Descending {
+			return c > 0
+		}
+		return c < 0
+	}
+	return false
+}
+
+// Page applies offset and limit to items. A non-positive limit means no limit.
+func Page[T any](items []T, limit, offset int) []T {
+	if offset > 0 {
+		if offset >= len(items) {
+			return items[:0]
+		}
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
+
+// Fields resolves the field names of a projection on m. It returns nil,
+// meaning every field, when names is empty.
+func Fields(m *schema.Model, names []string) ([]*schema.Field, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	fields := make([]*schema.Field, len(names))
+	for i, name := range names {
+		if fields[i] = m.Field(name); fields[i] == nil {
+			return nil, fmt.Errorf("%w: %s has no field %q", dbase.ErrInvalidModel, m.Name, name)
+		}
+	}
+	return fields, nil
+}
+
+// Select applies the projection of q to records of m that were matched
+// and sorted already: it drops duplicates when q.Unique is set, applies
+// Limit and Offset, and zeroes the fields missing from q.Fields.
+func Select(items []reflect.Value, m *schema.Model, q *dbase.Query) ([]reflect.Value, error) {
+	fields, err := Fields(m, q.Fields)
+	if err != nil {
+		return nil, err
+	}
+	if q.Unique {
+		items = Distinct(items, m, fields)
+	}
+	items = Page(items, q.Limit, q.Offset)
+	if fields != nil {
+		for _, rec := range items {
+			Project(rec, m, fields)
+		}
+	}
+	return items, nil
+}
+
+// Distinct drops the records of m equal to an earlier one in fields, or in
+// every field when fields is nil.
+func Distinct(items []reflect.Value, m *schema.Model, fields []*schema.Field) []reflect.Value {
+	if fields == nil {
+		fields = m.Fields
+	}
+	seen := make(map[string]bool, len(items))
+	out := items[:0]
+	for _, rec := range items {
+		values := make([]any, len(fields))
+		for i, f := range fields {
+			values[i] = f.Interface(rec)
+		}
+		key := fmt.Sprintf("%#v", values)
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+// Project zeroes the fields of the record rec of m that are not in fields.
+func Project(rec reflect.Value, m *schema.Model, fields []*schema.Field) {
+	keep := make(map[*schema.Field]bool, len(fields))
+	for _, f := range fields {
+		keep[f] = true
+	}
+	for _, f := range m.Fields {
+		if v := f.Value(rec); !keep[f] && v.IsValid() && v.CanSet() {
+			v.SetZero()
+		}
+	}
+}
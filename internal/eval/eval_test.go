@@ -0,0 +1,55 @@
+package eval
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+)
+
+func TestMatch(t *testing.T) {
+	rec := MapGetter(map[string]any{"name": "Alice", "age": float64(30), "email": nil})
+
+	tests := []struct {
+		name  string
+		query *dbase.Query
+		want  bool
+	}{
+		{"eq", dbase.Eq("Name", "Alice"), true},
+		{"numeric kinds", dbase.Eq("Age", uint(30)), true},
+		{"gt", dbase.Gt("Age", 30), false},
+		{"in", dbase.In("Age", 10, 30), true},
+		{"not in", dbase.NewQuery().Where("Age", dbase.OpNotIn, []int{30}), false},
+		{"like", dbase.Like("Name", "A%e"), true},
+		{"like single", dbase.Like("Name", "Al_ce"), true},
+		{"prefix", dbase.NewQuery().Where("Name", dbase.OpPrefix, "Bo"), false},
+		{"is null", dbase.NewQuery().Where("Email", dbase.OpIsNull, nil), true},
+		{"null never equal", dbase.Eq("Email", "x"), false},
+		{"and before or", dbase.Eq("Name", "Bob").Where("Age", dbase.OpEqual, 30).Or("Age", dbase.OpLess, 40), true},
+		{"or then and", dbase.Eq("Name", "Bob").Or("Age", dbase.OpEqual, 30).Where("Name", dbase.OpEqual, "Eve"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Match(rec, tt.query.Conditions)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := Match(rec, dbase.Eq("Missing", 1).Conditions)
+	assert.Error(t, err)
+}
+
+func TestSortAndPage(t *testing.T) {
+	recs := []map[string]any{
+		{"n": "b", "v": 2}, {"n": "a", "v": 2}, {"n": "c", "v": 1},
+	}
+	Sort(recs, []dbase.Order{{Field: "v", Descending: true}, {Field: "n"}}, MapGetter)
+	assert.Equal(t, []any{"a", "b", "c"}, []any{recs[0]["n"], recs[1]["n"], recs[2]["n"]})
+
+	assert.Len(t, Page(recs, 2, 0), 2)
+	assert.Len(t, Page(recs, 0, 2), 1)
+	assert.Empty(t, Page(recs, 1, 5))
+}
@@ -0,0 +1,88 @@
+// Package gate tracks the operations in flight on a database so that it can
+// be closed gracefully: once closing starts new operations are refused, and
+// the handle is closed when the running ones have finished.
+package gate
+
+import (
+	"context"
+	"sync"
+)
+
+// Gate counts the operations in flight. The zero value is open; a nil
+// *Gate admits every operation and never closes, for instances such as
+// transaction scopes whose lifetime is covered by an enclosing operation.
+type Gate struct {
+	mu       sync.Mutex
+	inflight int
+	closing  bool
+	idle     chan struct{} // closed when inflight drops to zero while closing
+}
+
+// Enter admits an operation, reporting false once closing has started.
+// Every admitted operation must call Leave.
+func (g *Gate) Enter() bool {
+	if g == nil {
+		return true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closing {
+		return false
+	}
+	g.inflight++
+	return true
+}
+
+// Leave ends an operation admitted by Enter.
+func (g *Gate) Leave() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inflight--
+	if g.inflight == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
+}
+
+// Close refuses new operations and waits until those in flight have
+// finished or ctx is done, returning ctx's error in the latter case. It
+// reports false if the gate was already closing, so the caller closes the
+// underlying handle only once.
+func (g *Gate) Close(ctx context.Context) (first bool, err error) {
+	if g == nil {
+		return true, nil
+	}
+	g.mu.Lock()
+	if g.closing {
+		g.mu.Unlock()
+		return false, nil
+	}
+	g.closing = true
+	if g.inflight == 0 {
+		g.mu.Unlock()
+		return true, nil
+	}
+	idle := make(chan struct{})
+	g.idle = idle
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return true, nil
+	case <-ctx.Done():
+		return true, ctx.Err()
+	}
+}
+
+// Closed reports whether closing has started.
+func (g *Gate) Closed() bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.closing
+}
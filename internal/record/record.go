@@ -0,0 +1,99 @@
+// Package record holds the write plumbing shared by the drivers that store
+// models themselves (badger, redis and jsonfile): resolving models, running
+// hooks around writes, and the ID and existence rules of each write mode.
+package record
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// Mode is the kind of a write.
+type Mode int
+
+const (
+	// Create fails if the record exists.
+	Create Mode = iota
+
+	// Update fails if the record does not exist.
+	Update
+
+	// Save creates or replaces the record.
+	Save
+)
+
+// Inspect returns the model of model, which must be a non-nil pointer to a
+// struct, and the struct it points to.
+func Inspect(model any) (*schema.Model, reflect.Value, error) {
+	v, err := schema.Struct(model)
+	if err != nil {
+		return nil, reflect.Value{}, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	m, err := schema.OfType(v.Type())
+	if err != nil {
+		return nil, reflect.Value{}, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	return m, v, nil
+}
+
+// Write runs the hooks matching mode around put, which stores the struct v
+// of model with the rules of mode.
+func Write(ctx context.Context, model any, mode Mode, put func(ctx context.Context, m *schema.Model, v reflect.Value, mode Mode) error) error {
+	m, v, err := Inspect(model)
+	if err != nil {
+		return err
+	}
+
+	before, after := dbase.RunBeforeCreateHooks, dbase.RunAfterCreateHooks
+	if mode == Update {
+		before, after = dbase.RunBeforeUpdateHooks, dbase.RunAfterUpdateHooks
+	}
+	if err := before(ctx, model); err != nil {
+		return err
+	}
+	if err := put(ctx, m, v, mode); err != nil {
+		return err
+	}
+	return after(ctx, model)
+}
+
+// AssignID sets the ID of the struct v of m from next when it is zero.
+// Updates need an ID, and so do models without an increment tag.
+func AssignID(m *schema.Model, v reflect.Value, mode Mode, next func() (any, error)) error {
+	field := m.ID.Value(v)
+	if !field.IsZero() {
+		return nil
+	}
+	if mode == Update {
+		return fmt.Errorf("%w: %s has a zero ID", dbase.ErrInvalidModel, m.Name)
+	}
+	if !m.Increment {
+		return fmt.Errorf("%w: %s has a zero ID and no increment tag", dbase.ErrInvalidModel, m.Name)
+	}
+	seq, err := next()
+	if err != nil {
+		return err
+	}
+	id, err := schema.Convert(seq, field.Type())
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", dbase.ErrInvalidModel, m.Name, err)
+	}
+	field.Set(id)
+	return nil
+}
+
+// Check fails with [dbase.ErrAlreadyExists] or [dbase.ErrNotFound] when
+// mode does not allow writing the record id of m, which exists or not.
+func (mode Mode) Check(m *schema.Model, id any, exists bool) error {
+	switch {
+	case exists && mode == Create:
+		return fmt.Errorf("%w: %s %v", dbase.ErrAlreadyExists, m.Name, id)
+	case !exists && mode == Update:
+		return dbase.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+// Package schema extracts model metadata from struct types and tags.
+//
+// It is shared by the drivers that store models themselves (key-value and
+// file-based engines) and by the generic tooling in the root package, so it
+// must not import github.com/nuln/dbase.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ErrNotStruct is returned when a model is not a struct, a pointer to a
+// struct, or a pointer to a slice of either.
+var ErrNotStruct = errors.New("model must be a struct or a pointer to a struct")
+
+// Field describes a single persisted struct field.
+type Field struct {
+	// Name is the Go field name.
+	Name string
+
+	// Column is the snake_case name used by SQL drivers.
+	Column string
+
+	// Index is the field index sequence for [reflect.Value.FieldByIndex].
+	Index []int
+
+	// Type is the field type.
+	Type reflect.Type
+
+	// Indexed reports whether the field carries a secondary index tag.
+	Indexed bool
+
+	// Unique reports whether the field carries a unique index tag.
+	Unique bool
+}
+
+// Model describes a model type.
+type Model struct {
+	// Type is the struct type.
+	Type reflect.Type
+
+	// Name is the bucket name, which is the struct type name as in Storm.
+	Name string
+
+	// ID is the primary key field.
+	ID *Field
+
+	// Increment reports whether IDs are assigned from a sequence when zero.
+	Increment bool
+
+	// Fields lists all persisted fields in declaration order.
+	Fields []*Field
+
+	// Indexes lists the fields with an index or unique tag.
+	Indexes []*Field
+
+	byName map[string]*Field
+}
+
+var cache sync.Map // reflect.Type -> *Model
+
+// Of returns the metadata of model, which may be a struct, a pointer to a
+// struct, or a pointer to a slice of structs or struct pointers.
+func Of(model any) (*Model, error) {
+	if model == nil {
+		return nil, ErrNotStruct
+	}
+	return OfType(reflect.TypeOf(model))
+}
+
+// OfType is like [Of] but takes a type.
+func OfType(t reflect.Type) (*Model, error) {
+	t = ElemType(t)
+	if t.Kind() != reflect.Struct {
+		return nil, ErrNotStruct
+	}
+	if m, ok := cache.Load(t); ok {
+		return m.(*Model), nil
+	}
+
+	m, err := parse(t)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := cache.LoadOrStore(t, m)
+	return actual.(*Model), nil
+}
+
+// ElemType strips pointers and slices from t until it reaches the element type.
+func ElemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+func parse(t reflect.Type) (*Model, error) {
+	m := &Model{Type: t, Name: t.Name(), byName: make(map[string]*Field)}
+
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || (sf.Anonymous && sf.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		if sf.Tag.Get("json") == "-" || sf.Tag.Get("storm") == "-" || sf.Tag.Get("gorm") == "-" {
+			continue
+		}
+
+		f := &Field{
+			Name:   sf.Name,
+			Column: ToSnake(sf.Name),
+			Index:  sf.Index,
+			Type:   sf.Type,
+		}
+
+		storm := tagOptions(sf.Tag.Get("storm"), ",")
+		gorm := tagOptions(strings.ToLower(sf.Tag.Get("gorm")), ";")
+
+		isID := storm["id"] || gorm["primarykey"] || gorm["primary_key"]
+		if isID || (m.ID == nil && sf.Name == "ID") {
+			if isID && m.ID != nil && m.ID.Name != "ID" {
+				return nil, fmt.Errorf("%s: multiple id fields", t.Name())
+			}
+			m.ID = f
+			m.Increment = storm["increment"] || gorm["autoincrement"]
+		}
+
+		f.Unique = storm["unique"] || gorm["unique"] || gorm["uniqueindex"]
+		f.Indexed = f.Unique || storm["index"] || gorm["index"]
+
+		m.Fields = append(m.Fields, f)
+		m.byName[strings.ToLower(f.Name)] = f
+		if _, exists := m.byName[f.Column]; !exists {
+			m.byName[f.Column] = f
+		}
+	}
+
+	if m.ID == nil {
+		return nil, fmt.Errorf("%s: missing id field", t.Name())
+	}
+	for _, f := range m.Fields {
+		if f.Indexed && f != m.ID {
+			m.Indexes = append(m.Indexes, f)
+		}
+	}
+	return m, nil
+}
+
+// tagOptions splits a struct tag value into a set of lower-case keys.
+// Key/value options such as "index:idx_name" are reduced to their key.
+func tagOptions(tag, sep string) map[string]bool {
+	opts := make(map[string]bool)
+	for _, part := range strings.Split(tag, sep) {
+		part = strings.TrimSpace(part)
+		if k, _, ok := strings.Cut(part, ":"); ok {
+			part = k
+		}
+		if part != "" {
+			opts[strings.ToLower(part)] = true
+		}
+	}
+	return opts
+}
+
+// Field looks up a field by Go name (case-insensitively) or by column name.
+func (m *Model) Field(name string) *Field {
+	if f, ok := m.byName[strings.ToLower(name)]; ok {
+		return f
+	}
+	return m.byName[ToSnake(name)]
+}
+
+// New returns a pointer to a new zero value of the model type.
+func (m *Model) New() reflect.Value {
+	return reflect.New(m.Type)
+}
+
+// Value returns the value of field f in the struct v, dereferencing
+// pointers along the way. A nil pointer yields an invalid value.
+func (f *Field) Value(v reflect.Value) reflect.Value {
+	v = reflect.Indirect(v)
+	fv, err := v.FieldByIndexErr(f.Index)
+	if err != nil {
+		return reflect.Value{}
+	}
+	return fv
+}
+
+// Interface returns the value of field f in v as an interface, with nil
+// pointers reported as nil.
+func (f *Field) Interface(v reflect.Value) any {
+	fv := f.Value(v)
+	if !fv.IsValid() {
+		return nil
+	}
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return nil
+		}
+		fv = fv.Elem()
+	}
+	return fv.Interface()
+}
+
+// Struct returns the addressable struct value behind model, which must be
+// a non-nil pointer to a struct.
+func Struct(model any) (reflect.Value, error) {
+	v := reflect.ValueOf(model)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, ErrNotStruct
+	}
+	return v.Elem(), nil
+}
+
+// Convert converts value to type t when their kinds are compatible: any
+// number to any number (e.g. a float64 decoded from JSON to uint) and
+// strings to named string types.
+func Convert(value any, t reflect.Type) (reflect.Value, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	v := reflect.ValueOf(value)
+	if !v.IsValid() {
+		return reflect.Zero(t), nil
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Zero(t), nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == t {
+		return v, nil
+	}
+
+	switch {
+	case isNumber(v.Kind()) && isNumber(t.Kind()):
+		return v.Convert(t), nil
+	case v.Kind() == reflect.String && t.Kind() == reflect.String:
+		return v.Convert(t), nil
+	case v.Type().ConvertibleTo(t) && v.Kind() == t.Kind():
+		return v.Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("cannot convert %T to %s", value, t)
+}
+
+func isNumber(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// ToSnake converts a Go identifier such as "CreatedAt" or "UserID" to
+// snake_case ("created_at", "user_id"), matching GORM's default naming.
+func ToSnake(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Slice returns the slice behind results, which must be a pointer to a slice
+// of structs or struct pointers, and reports whether elements are pointers.
+func Slice(results any) (reflect.Value, bool, error) {
+	v := reflect.ValueOf(results)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, false, ErrNotStruct
+	}
+	elem := v.Elem().Type().Elem()
+	isPtr := elem.Kind() == reflect.Ptr
+	if isPtr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return reflect.Value{}, false, ErrNotStruct
+	}
+	return v.Elem(), isPtr, nil
+}
@@ -0,0 +1,497 @@
+// Package jsonfile provides a [dbase.Database] implementation backed by
+// plain JSON files, intended for fixtures, reference data and
+// configuration that is checked into version control.
+// Importing this package registers the "jsonfile" driver.
+//
+//	import _ "github.com/nuln/dbase/jsonfile"
+//
+// [dbase.Config.Path] names a directory holding one file per model, named
+// after the struct type: "User.ndjson" with one JSON object per line, or
+// "User.json" with a single JSON array. Files are loaded lazily on first
+// use and queries are evaluated in memory. Writes replace the file
+// atomically through a temporary file and rename, keeping its format.
+package jsonfile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+	"github.com/nuln/dbase/internal/record"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+func init() {
+	dbase.Register("jsonfile", func(cfg *dbase.Config) (dbase.Database, error) {
+		if len(cfg.Replicas) > 0 {
+			return nil, fmt.Errorf("dbase/jsonfile: replicas: %w", dbase.ErrNotSupported)
+		}
+		var opts Options
+		if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+			return nil, err
+		}
+		return New(cfg.Path, opts)
+	}, dbase.WithDescription("Directory of JSON or NDJSON files, one per model"), dbase.WithAliases("json"),
+		dbase.Requires("Path"), dbase.WithOptions(Options{}), dbase.WithCapabilities(capabilities))
+}
+
+// capabilities of the jsonfile driver. Each file is replaced atomically,
+// but a commit that writes several files is not.
+var capabilities = dbase.Capabilities{
+	Operators:          dbase.AllOperators,
+	Or:                 true,
+	PartialUpdate:      true,
+	AtomicTransactions: false,
+	NestedTransactions: dbase.NestedJoin,
+}
+
+// ErrReadOnly is returned by write operations on a read-only database.
+var ErrReadOnly = fmt.Errorf("%w: database is read-only", dbase.ErrNotSupported)
+
+// Options configures a file-backed database. The driver reads them from
+// [dbase.Config.Options] by the keys in their option tags.
+type Options struct {
+	// ReadOnly rejects every write with [ErrReadOnly] and never touches
+	// the files on disk.
+	ReadOnly bool `option:"read_only"`
+}
+
+// store is the state shared by a DB and its transaction-scoped instances.
+// A single mutex serializes all access; a transaction holds it until it
+// commits or rolls back.
+type store struct {
+	mu     sync.Mutex
+	dir    string
+	opts   Options
+	tables map[string]*table
+}
+
+// DB implements [dbase.Database] using JSON files.
+type DB struct {
+	store *store
+	tx    map[string]*table // tables modified by the active transaction
+}
+
+// New opens the directory at dir, creating it unless opts.ReadOnly is set.
+func New(dir string, opts Options) (*DB, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("dbase/jsonfile: path is required")
+	}
+	if opts.ReadOnly {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("dbase/jsonfile: open: %w", err)
+		}
+	} else if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("dbase/jsonfile: open: %w", err)
+	}
+	return &DB{store: &store{dir: dir, opts: opts, tables: make(map[string]*table)}}, nil
+}
+
+// Driver implements [dbase.Database].
+func (d *DB) Driver() string { return "jsonfile" }
+
+// Capabilities implements [dbase.Capable].
+func (d *DB) Capabilities() dbase.Capabilities { return capabilities }
+
+func (d *DB) Create(ctx context.Context, model any) error {
+	return record.Write(ctx, model, record.Create, d.write)
+}
+
+func (d *DB) Get(ctx context.Context, model any, id any) error {
+	m, v, err := record.Inspect(model)
+	if err != nil {
+		return err
+	}
+	return d.read(func() error {
+		t, err := d.table(m)
+		if err != nil {
+			return err
+		}
+		key, err := idString(m, id)
+		if err != nil {
+			return err
+		}
+		found, err := t.decode(key, v.Addr().Interface())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return dbase.ErrNotFound
+		}
+		return nil
+	})
+}
+
+func (d *DB) Update(ctx context.Context, model any) error {
+	return record.Write(ctx, model, record.Update, d.write)
+}
+
+// UpdateFields copies only the named fields from model into the stored
+// record.
+func (d *DB) UpdateFields(ctx context.Context, model any, fields ...string) error {
+	if len(fields) == 0 {
+		return d.Update(ctx, model)
+	}
+	m, v, err := record.Inspect(model)
+	if err != nil {
+		return err
+	}
+	selected := make([]*schema.Field, 0, len(fields))
+	for _, name := range fields {
+		f := m.Field(name)
+		if f == nil {
+			return fmt.Errorf("dbase/jsonfile: %s has no field %q", m.Name, name)
+		}
+		selected = append(selected, f)
+	}
+
+	if err := dbase.RunBeforeUpdateHooks(ctx, model); err != nil {
+		return err
+	}
+	err = d.modify(m, func(t *table) error {
+		current := m.New()
+		found, err := t.decode(idKey(m, v), current.Interface())
+		if err != nil {
+			return err
+		}
+		if !found {
+			return dbase.ErrNotFound
+		}
+		for _, f := range selected {
+			f.Value(current).Set(f.Value(v))
+		}
+		if err := t.put(m, current.Elem(), record.Update); err != nil {
+			return err
+		}
+		v.Set(current.Elem())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return dbase.RunAfterUpdateHooks(ctx, model)
+}
+
+func (d *DB) Save(ctx context.Context, model any) error {
+	return record.Write(ctx, model, record.Save, d.write)
+}
+
+func (d *DB) Delete(ctx context.Context, model any, id any) error {
+	m, _, err := record.Inspect(model)
+	if err != nil {
+		return err
+	}
+	key, err := idString(m, id)
+	if err != nil {
+		return err
+	}
+	if err := dbase.RunBeforeDeleteHooks(ctx, model); err != nil {
+		return err
+	}
+	if err := d.modify(m, func(t *table) error { return t.delete(key) }); err != nil {
+		return err
+	}
+	return dbase.RunAfterDeleteHooks(ctx, model)
+}
+
+func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
+	slice, isPtr, err := schema.Slice(results)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	m, err := schema.OfType(slice.Type())
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+
+	var items []reflect.Value
+	if err := d.read(func() error {
+		items, err = d.selectRecords(m, query)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	out := reflect.MakeSlice(slice.Type(), 0, len(items))
+	for _, item := range items {
+		if isPtr {
+			out = reflect.Append(out, item)
+		} else {
+			out = reflect.Append(out, item.Elem())
+		}
+	}
+	slice.Set(out)
+	return nil
+}
+
+func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error {
+	m, v, err := record.Inspect(result)
+	if err != nil {
+		return err
+	}
+	q := dbase.Query{}
+	if query != nil {
+		q = *query
+	}
+	q.Limit = 1
+
+	var items []reflect.Value
+	if err := d.read(func() error {
+		items, err = d.selectRecords(m, &q)
+		return err
+	}); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return dbase.ErrNotFound
+	}
+	v.Set(items[0].Elem())
+	return nil
+}
+
+func (d *DB) Count(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	m, err := schema.Of(model)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	q := dbase.Query{}
+	if query != nil {
+		q.Conditions, q.Fields, q.Unique = query.Conditions, query.Fields, query.Unique
+	}
+
+	var items []reflect.Value
+	err = d.read(func() error {
+		items, err = d.selectRecords(m, &q)
+		return err
+	})
+	return int64(len(items)), err
+}
+
+func (d *DB) Exists(ctx context.Context, model any, query *dbase.Query) (bool, error) {
+	count, err := d.Count(ctx, model, query)
+	return count > 0, err
+}
+
+// Transaction runs fn with exclusive access to the database. Tables written
+// by fn are copied and only replace the originals, on disk and in memory,
+// once fn succeeds. Each file is replaced atomically, but a crash while
+// several files are being written may leave some of them committed.
+// Nested calls join the enclosing transaction.
+func (d *DB) Transaction(ctx context.Context, fn func(tx dbase.Database) error) error {
+	if d.tx != nil {
+		return fn(d)
+	}
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+
+	tx := &DB{store: d.store, tx: make(map[string]*table)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return d.store.commit(tx.tx)
+}
+
+// Migrate creates an empty file for every model that has none yet. In
+// read-only mode it only checks that the models are valid.
+func (d *DB) Migrate(ctx context.Context, models ...any) error {
+	for _, model := range models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+		}
+		if d.store.opts.ReadOnly {
+			continue
+		}
+		err = d.read(func() error {
+			t, err := d.table(m)
+			if err != nil || t.exists {
+				return err
+			}
+			if err := t.flush(); err != nil {
+				return err
+			}
+			t.exists = true
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("dbase/jsonfile: migrate %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// Close releases the cached tables. Files are always written through, so
+// there is nothing to flush.
+func (d *DB) Close() error {
+	if d.tx != nil {
+		return nil
+	}
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+	d.store.tables = make(map[string]*table)
+	return nil
+}
+
+func (d *DB) Ping(ctx context.Context) error {
+	_, err := os.Stat(d.store.dir)
+	return err
+}
+
+// Stats implements [dbase.StatsReporter] with the total size of the table
+// files.
+func (d *DB) Stats(ctx context.Context) (*dbase.Stats, error) {
+	entries, err := os.ReadDir(d.store.dir)
+	if err != nil {
+		return nil, fmt.Errorf("dbase/jsonfile: stats: %w", err)
+	}
+	stats := &dbase.Stats{Driver: "jsonfile"}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || (ext != ".json" && ext != ".ndjson") {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("dbase/jsonfile: stats: %w", err)
+		}
+		stats.Size += fi.Size()
+	}
+	return stats, nil
+}
+
+// --- helpers ---
+
+// write puts the struct v of m into its table; see [record.Write].
+func (d *DB) write(_ context.Context, m *schema.Model, v reflect.Value, mode record.Mode) error {
+	return d.modify(m, func(t *table) error { return t.put(m, v, mode) })
+}
+
+// read runs fn with the store locked, unless a transaction already holds it.
+func (d *DB) read(fn func() error) error {
+	if d.tx != nil {
+		return fn()
+	}
+	d.store.mu.Lock()
+	defer d.store.mu.Unlock()
+	return fn()
+}
+
+// modify applies fn to a private copy of the table of m. Outside of a
+// transaction the copy is committed immediately.
+func (d *DB) modify(m *schema.Model, fn func(t *table) error) error {
+	if d.store.opts.ReadOnly {
+		return ErrReadOnly
+	}
+	if d.tx != nil {
+		t, err := d.writable(m)
+		if err != nil {
+			return err
+		}
+		return fn(t)
+	}
+	return d.Transaction(context.Background(), func(tx dbase.Database) error {
+		txdb := tx.(*DB)
+		t, err := txdb.writable(m)
+		if err != nil {
+			return err
+		}
+		return fn(t)
+	})
+}
+
+// table returns the table of m as seen by d, loading it on first use.
+func (d *DB) table(m *schema.Model) (*table, error) {
+	if t, ok := d.tx[m.Name]; ok {
+		return t, nil
+	}
+	if t, ok := d.store.tables[m.Name]; ok {
+		return t, nil
+	}
+	t, err := loadTable(d.store.dir, m)
+	if err != nil {
+		return nil, err
+	}
+	d.store.tables[m.Name] = t
+	return t, nil
+}
+
+// writable returns the transaction's private copy of the table of m.
+func (d *DB) writable(m *schema.Model) (*table, error) {
+	if t, ok := d.tx[m.Name]; ok {
+		return t, nil
+	}
+	t, err := d.table(m)
+	if err != nil {
+		return nil, err
+	}
+	c := t.clone()
+	d.tx[m.Name] = c
+	return c, nil
+}
+
+// commit writes the tables changed by a transaction and publishes them.
+func (s *store) commit(changed map[string]*table) error {
+	for _, t := range changed {
+		if err := t.flush(); err != nil {
+			return fmt.Errorf("dbase/jsonfile: write %s: %w", t.name, err)
+		}
+	}
+	for name, t := range changed {
+		t.exists = true
+		s.tables[name] = t
+	}
+	return nil
+}
+
+func (d *DB) selectRecords(m *schema.Model, query *dbase.Query) ([]reflect.Value, error) {
+	q := dbase.Query{}
+	if query != nil {
+		q = *query
+	}
+	t, err := d.table(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []reflect.Value
+	for _, key := range t.order {
+		rec := m.New()
+		if _, err := t.decode(key, rec.Interface()); err != nil {
+			return nil, err
+		}
+		ok, err := eval.Match(eval.StructGetter(m, rec), q.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, rec)
+		}
+	}
+
+	eval.Sort(items, q.OrderBy, func(rec reflect.Value) eval.Getter {
+		return eval.StructGetter(m, rec)
+	})
+	return eval.Select(items, m, &q)
+}
+
+func idString(m *schema.Model, id any) (string, error) {
+	v, err := schema.Convert(id, m.ID.Type)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s id: %v", dbase.ErrInvalidModel, m.Name, err)
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+func idKey(m *schema.Model, v reflect.Value) string {
+	return fmt.Sprint(m.ID.Value(v).Interface())
+}
+
+var (
+	_ dbase.Database      = (*DB)(nil)
+	_ dbase.StatsReporter = (*DB)(nil)
+)
@@ -0,0 +1,62 @@
+package jsonfile_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/dbasetest"
+	"github.com/nuln/dbase/jsonfile"
+)
+
+func TestJSONFile(t *testing.T) {
+	db, err := jsonfile.New(t.TempDir(), jsonfile.Options{})
+	if err != nil {
+		t.Fatalf("failed to open jsonfile: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	dbasetest.Suite(t, db)
+}
+
+type Country struct {
+	ID   string `json:"code" storm:"id"`
+	Name string `json:"name"`
+}
+
+func TestJSONFileArrayAndReadOnly(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Country.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[
+  {"code": "de", "name": "Germany"},
+  {"code": "fr", "name": "France"}
+]`), 0o600))
+
+	ro, err := jsonfile.New(dir, jsonfile.Options{ReadOnly: true})
+	require.NoError(t, err)
+
+	var countries []Country
+	require.NoError(t, ro.Find(ctx, &countries, dbase.NewQuery().OrderByAsc("Name")))
+	require.Len(t, countries, 2)
+	assert.Equal(t, "France", countries[0].Name)
+	assert.ErrorIs(t, ro.Create(ctx, &Country{ID: "it", Name: "Italy"}), jsonfile.ErrReadOnly)
+
+	rw, err := jsonfile.New(dir, jsonfile.Options{})
+	require.NoError(t, err)
+	require.NoError(t, rw.Create(ctx, &Country{ID: "it", Name: "Italy"}))
+	require.NoError(t, rw.Delete(ctx, &Country{}, "de"))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "[\n{\"code\":\"fr\",\"name\":\"France\"},\n{\"code\":\"it\",\"name\":\"Italy\"}\n]\n", string(raw))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must not be left behind")
+}
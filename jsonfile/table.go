@@ -0,0 +1,247 @@
+package jsonfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+	"github.com/nuln/dbase/internal/record"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// table holds the records of one model in file order. Records are kept
+// encoded and decoded on access, so callers never share memory with it.
+type table struct {
+	name   string
+	path   string
+	array  bool // file holds a JSON array instead of NDJSON
+	exists bool // file exists on disk
+	order  []string
+	rows   map[string]json.RawMessage
+	seq    uint64 // highest numeric ID seen
+}
+
+// loadTable reads the file of model name from dir. "<name>.ndjson" takes
+// precedence over "<name>.json"; a missing file yields an empty table
+// that will be written as NDJSON.
+func loadTable(dir string, m *schema.Model) (*table, error) {
+	t := &table{
+		name: m.Name,
+		path: filepath.Join(dir, m.Name+".ndjson"),
+		rows: make(map[string]json.RawMessage),
+	}
+
+	f, err := os.Open(t.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		arrayPath := filepath.Join(dir, m.Name+".json")
+		if f, err = os.Open(arrayPath); err == nil {
+			t.path, t.array = arrayPath, true
+		}
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dbase/jsonfile: load %s: %w", m.Name, err)
+	}
+	defer f.Close() //nolint:errcheck
+	t.exists = true
+
+	dec := json.NewDecoder(f)
+	if t.array {
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+			return nil, fmt.Errorf("dbase/jsonfile: load %s: expected a JSON array", t.path)
+		}
+	}
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("dbase/jsonfile: load %s: %w", t.path, err)
+		}
+		rec := m.New()
+		if err := json.Unmarshal(raw, rec.Interface()); err != nil {
+			return nil, fmt.Errorf("dbase/jsonfile: load %s: %w", t.path, err)
+		}
+		key := idKey(m, rec.Elem())
+		if _, dup := t.rows[key]; dup {
+			return nil, fmt.Errorf("dbase/jsonfile: load %s: duplicate id %s", t.path, key)
+		}
+		t.order = append(t.order, key)
+		t.rows[key] = compact(raw)
+		t.observe(m.ID.Value(rec.Elem()))
+	}
+	if t.array {
+		if _, err := dec.Token(); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("dbase/jsonfile: load %s: %w", t.path, err)
+		}
+	}
+	return t, nil
+}
+
+func compact(raw json.RawMessage) json.RawMessage {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+// observe raises the ID sequence to a numeric id.
+func (t *table) observe(id reflect.Value) {
+	switch id.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := id.Int(); n > 0 && uint64(n) > t.seq {
+			t.seq = uint64(n)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := id.Uint(); n > t.seq {
+			t.seq = n
+		}
+	}
+}
+
+func (t *table) clone() *table {
+	c := *t
+	c.order = slices.Clone(t.order)
+	c.rows = make(map[string]json.RawMessage, len(t.rows))
+	for k, v := range t.rows {
+		c.rows[k] = v
+	}
+	return &c
+}
+
+// decode unmarshals the record with the given key into dst.
+func (t *table) decode(key string, dst any) (bool, error) {
+	raw, ok := t.rows[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, dst)
+}
+
+// put stores the struct v, assigning the next ID when needed.
+func (t *table) put(m *schema.Model, v reflect.Value, mode record.Mode) error {
+	err := record.AssignID(m, v, mode, func() (any, error) { return t.seq + 1, nil })
+	if err != nil {
+		return err
+	}
+
+	idField := m.ID.Value(v)
+	key := idKey(m, v)
+	_, exists := t.rows[key]
+	if err := mode.Check(m, key, exists); err != nil {
+		return err
+	}
+	if err := t.checkUnique(m, v, key); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Errorf("dbase/jsonfile: encode %s: %w", m.Name, err)
+	}
+	if !exists {
+		t.order = append(t.order, key)
+	}
+	t.rows[key] = raw
+	t.observe(idField)
+	return nil
+}
+
+// checkUnique scans the other records for a value of a unique field that
+// equals the one in v.
+func (t *table) checkUnique(m *schema.Model, v reflect.Value, key string) error {
+	var unique []*schema.Field
+	for _, f := range m.Indexes {
+		if f.Unique {
+			unique = append(unique, f)
+		}
+	}
+	if len(unique) == 0 {
+		return nil
+	}
+
+	other := m.New()
+	for _, k := range t.order {
+		if k == key {
+			continue
+		}
+		other.Elem().SetZero()
+		if _, err := t.decode(k, other.Interface()); err != nil {
+			return err
+		}
+		for _, f := range unique {
+			if c, err := eval.Compare(f.Interface(v), f.Interface(other)); err == nil && c == 0 {
+				return fmt.Errorf("%w: %s.%s must be unique", dbase.ErrAlreadyExists, m.Name, f.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *table) delete(key string) error {
+	if _, ok := t.rows[key]; !ok {
+		return dbase.ErrNotFound
+	}
+	delete(t.rows, key)
+	t.order = slices.DeleteFunc(t.order, func(k string) bool { return k == key })
+	return nil
+}
+
+// flush atomically replaces the file with the current records.
+func (t *table) flush() error {
+	var buf bytes.Buffer
+	if t.array {
+		buf.WriteString("[\n")
+		for i, key := range t.order {
+			buf.Write(t.rows[key])
+			if i < len(t.order)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("]\n")
+	} else {
+		for _, key := range t.order {
+			buf.Write(t.rows[key])
+			buf.WriteByte('\n')
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(t.path), "."+filepath.Base(t.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	// Keep the permissions of the file being replaced; CreateTemp uses 0600.
+	mode := fs.FileMode(0o644)
+	if fi, err := os.Stat(t.path); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), t.path)
+}
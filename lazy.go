@@ -0,0 +1,270 @@
+package dbase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetryConfig makes [Open] retry a driver that fails to connect, waiting
+// between attempts with exponential backoff.
+type RetryConfig struct {
+	// MaxWait bounds the time spent retrying. It defaults to 30 seconds.
+	MaxWait time.Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty"`
+
+	// InitialBackoff is the wait after the first failed attempt; it
+	// doubles after each further one up to MaxBackoff. They default to
+	// 100 milliseconds and 5 seconds.
+	InitialBackoff time.Duration `json:"initial_backoff,omitempty" yaml:"initial_backoff,omitempty"`
+	MaxBackoff     time.Duration `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+}
+
+// connect opens cfg with factory, retrying as configured by cfg.Retry
+// until ctx is done. Errors wrapping [ErrInvalidConfig] or
+// [ErrNotSupported] are not retried.
+func connect(ctx context.Context, factory Factory, cfg *Config) (Database, error) {
+	if cfg.Retry == nil {
+		return factory(cfg)
+	}
+	maxWait, backoff, maxBackoff := cfg.Retry.MaxWait, cfg.Retry.InitialBackoff, cfg.Retry.MaxBackoff
+	if maxWait == 0 {
+		maxWait = 30 * time.Second
+	}
+	if backoff == 0 {
+		backoff = 100 * time.Millisecond
+	}
+	if maxBackoff == 0 {
+		maxBackoff = 5 * time.Second
+	}
+
+	deadline := time.Now().Add(maxWait)
+	for attempt := 1; ; attempt++ {
+		db, err := factory(cfg)
+		if err == nil || errors.Is(err, ErrInvalidConfig) || errors.Is(err, ErrNotSupported) {
+			return db, err
+		}
+		wait := min(backoff, time.Until(deadline))
+		if wait <= 0 {
+			return nil, fmt.Errorf("dbase: open %s: giving up after %d attempts: %w", cfg.Type, attempt, err)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("dbase: open %s: %w (last error: %v)", cfg.Type, ctx.Err(), err)
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// lazyDB is the [Database] returned by [Open] for a [Config.Lazy] config.
+// It opens the driver on first use and forwards every call to it.
+type lazyDB struct {
+	cfg  *Config
+	caps *Capabilities // declared by the driver, if any
+	open func(ctx context.Context) (Database, error)
+
+	mu         sync.Mutex
+	db         Database      // nil until connected
+	connecting chan struct{} // closed when the current attempt ends
+	closed     bool
+}
+
+// get returns the underlying database, connecting it first. Concurrent
+// callers share one connection attempt, but each stops waiting when its
+// own ctx is done.
+func (l *lazyDB) get(ctx context.Context) (Database, error) {
+	for {
+		l.mu.Lock()
+		switch {
+		case l.closed:
+			l.mu.Unlock()
+			return nil, ErrClosed
+		case l.db != nil:
+			db := l.db
+			l.mu.Unlock()
+			return db, nil
+		case l.connecting != nil:
+			wait := l.connecting
+			l.mu.Unlock()
+			select {
+			case <-wait:
+				continue
+			case <-ctx.Done():
+				return nil, fmt.Errorf("dbase: connect %s: %w", l.cfg.Type, ctx.Err())
+			}
+		}
+		done := make(chan struct{})
+		l.connecting = done
+		l.mu.Unlock()
+
+		db, err := l.open(ctx)
+
+		l.mu.Lock()
+		l.connecting = nil
+		close(done)
+		if err == nil && l.closed {
+			_ = db.Close()
+			err = ErrClosed
+		} else if err == nil {
+			l.db = db
+		}
+		l.mu.Unlock()
+		return db, err
+	}
+}
+
+// connected returns the database behind db, connecting it first when db
+// is lazy, so that optional interfaces such as [Backupper] can be checked.
+func connected(ctx context.Context, db Database) (Database, error) {
+	if l, ok := db.(*lazyDB); ok {
+		return l.get(ctx)
+	}
+	return db, nil
+}
+
+func (l *lazyDB) Driver() string { return l.cfg.Type }
+
+// Capabilities implements [Capable] with those the driver declares, or
+// those of the database once connected.
+func (l *lazyDB) Capabilities() Capabilities {
+	l.mu.Lock()
+	db := l.db
+	l.mu.Unlock()
+	if db == nil && l.caps != nil {
+		return *l.caps
+	}
+	return CapabilitiesOf(db)
+}
+
+// Ping connects the database if needed, so it fails while the database
+// cannot be reached, then pings it.
+func (l *lazyDB) Ping(ctx context.Context) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Ping(ctx)
+}
+
+// Close closes the database if it was connected. Later calls fail with
+// [ErrClosed].
+func (l *lazyDB) Close() error {
+	return l.Shutdown(context.Background())
+}
+
+// Shutdown implements [Shutdowner] by shutting down the database if it was
+// connected.
+func (l *lazyDB) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	db := l.db
+	l.mu.Unlock()
+	if db == nil {
+		return nil
+	}
+	return Shutdown(ctx, db)
+}
+
+func (l *lazyDB) Create(ctx context.Context, model any) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Create(ctx, model)
+}
+
+func (l *lazyDB) Get(ctx context.Context, model any, id any) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Get(ctx, model, id)
+}
+
+func (l *lazyDB) Update(ctx context.Context, model any) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Update(ctx, model)
+}
+
+func (l *lazyDB) UpdateFields(ctx context.Context, model any, fields ...string) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.UpdateFields(ctx, model, fields...)
+}
+
+func (l *lazyDB) Save(ctx context.Context, model any) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Save(ctx, model)
+}
+
+func (l *lazyDB) Delete(ctx context.Context, model any, id any) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Delete(ctx, model, id)
+}
+
+func (l *lazyDB) Find(ctx context.Context, results any, query *Query) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Find(ctx, results, query)
+}
+
+func (l *lazyDB) FindOne(ctx context.Context, result any, query *Query) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.FindOne(ctx, result, query)
+}
+
+func (l *lazyDB) Count(ctx context.Context, model any, query *Query) (int64, error) {
+	db, err := l.get(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return db.Count(ctx, model, query)
+}
+
+func (l *lazyDB) Exists(ctx context.Context, model any, query *Query) (bool, error) {
+	db, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	return db.Exists(ctx, model, query)
+}
+
+func (l *lazyDB) Transaction(ctx context.Context, fn func(tx Database) error) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Transaction(ctx, fn)
+}
+
+func (l *lazyDB) Migrate(ctx context.Context, models ...any) error {
+	db, err := l.get(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Migrate(ctx, models...)
+}
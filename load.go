@@ -0,0 +1,291 @@
+package dbase
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadOptions configures [LoadConfig].
+type LoadOptions struct {
+	// EnvPrefix enables overrides from environment variables with this
+	// prefix, such as "APP_DB_" for APP_DB_DSN. Without a prefix the
+	// environment is only used to expand ${VAR} in the file.
+	EnvPrefix string
+
+	// Getenv looks up environment variables. It defaults to os.Getenv.
+	Getenv func(key string) string
+}
+
+// configStrings are the string fields of Config that LoadConfig reads
+// from files and the environment, by file key.
+var configStrings = []struct {
+	key   string
+	field func(*Config) *string
+}{
+	{"type", func(c *Config) *string { return &c.Type }},
+	{"path", func(c *Config) *string { return &c.Path }},
+	{"dsn", func(c *Config) *string { return &c.DSN }},
+	{"replica_policy", func(c *Config) *string { return &c.ReplicaPolicy }},
+}
+
+// LoadConfig reads a [Config] from a YAML or JSON file, then applies
+// overrides from the environment. path may be empty to load from the
+// environment alone.
+//
+// String values in the file may reference environment variables as ${VAR},
+// or ${VAR:-default} when VAR may be unset or empty; "$$" stands for "$".
+// A key with a _file suffix, such as dsn_file, reads the setting from the
+// named file, for secrets mounted as files. Unknown keys are an error, and
+// durations are written as strings such as "5m".
+//
+// With [LoadOptions.EnvPrefix] set, these variables override the file, in
+// this order:
+//
+//	<prefix>URL                 a URL for [ParseURL]; the settings it holds win
+//	<prefix>TYPE, PATH, DSN     the field of the same name
+//	<prefix>REPLICA_POLICY
+//	<prefix>REPLICAS            comma-separated replicas
+//	<prefix>MAX_OPEN_CONNS      and the other pool settings, named as in URLs
+//	<prefix>DSN_FILE            like dsn_file, for each of the fields above
+//
+// The result is not validated; [Open] does that, or call [Config.Validate].
+func LoadConfig(path string, opts LoadOptions) (*Config, error) {
+	getenv := opts.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	cfg := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("dbase: load config: %w", err)
+		}
+		if err := cfg.decode(data, getenv); err != nil {
+			return nil, fmt.Errorf("dbase: load config %s: %w", path, err)
+		}
+	}
+	if opts.EnvPrefix != "" {
+		if err := cfg.applyEnv(opts.EnvPrefix, getenv); err != nil {
+			return nil, fmt.Errorf("dbase: load config: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// decode parses a config file into cfg, expanding variables and reading
+// _file keys first.
+func (cfg *Config) decode(data []byte, getenv func(string) string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping", root.Line)
+	}
+	if err := expandNode(root, getenv); err != nil {
+		return err
+	}
+	if err := readSecretKeys(root); err != nil {
+		return err
+	}
+
+	// Decode the rewritten document again to reject unknown keys, which
+	// yaml.Node.Decode does not support.
+	out, err := yaml.Marshal(root)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(out))
+	dec.KnownFields(true)
+	return dec.Decode(cfg)
+}
+
+// expandNode expands variables in the scalar values below n.
+func expandNode(n *yaml.Node, getenv func(string) string) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		v, err := expand(n.Value, getenv)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n.Line, err)
+		}
+		if v != n.Value {
+			n.Value = v
+			if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle) == 0 {
+				n.Tag = "" // resolve the expanded value, e.g. as a number
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			if err := expandNode(n.Content[i], getenv); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, c := range n.Content {
+			if err := expandNode(c, getenv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expand replaces ${VAR} and ${VAR:-default} in s, and "$$" with "$".
+func expand(s string, getenv func(string) string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] != '$' || i+1 == len(s):
+			b.WriteByte(s[i])
+		case s[i+1] == '$':
+			b.WriteByte('$')
+			i++
+		case s[i+1] == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${ in %q", s)
+			}
+			name, def, hasDef := strings.Cut(s[i+2:i+end], ":-")
+			v := getenv(name)
+			if v == "" && !hasDef {
+				return "", fmt.Errorf("environment variable %s is not set", name)
+			}
+			if v == "" {
+				v = def
+			}
+			b.WriteString(v)
+			i += end
+		default:
+			b.WriteByte('$')
+		}
+	}
+	return b.String(), nil
+}
+
+// readSecretKeys replaces the top-level keys such as dsn_file in root with
+// the setting read from the file.
+func readSecretKeys(root *yaml.Node) error {
+	keys := make(map[string]bool)
+	for i := 0; i < len(root.Content); i += 2 {
+		keys[root.Content[i].Value] = true
+	}
+	for i := 0; i < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		name, ok := strings.CutSuffix(key.Value, "_file")
+		if !ok || !isConfigString(name) {
+			continue
+		}
+		if keys[name] {
+			return fmt.Errorf("line %d: both %s and %s are set", key.Line, name, key.Value)
+		}
+		secret, err := readSecret(value.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", key.Line, key.Value, err)
+		}
+		key.Value = name
+		value.Value, value.Tag, value.Style = secret, "!!str", yaml.DoubleQuotedStyle
+	}
+	return nil
+}
+
+func isConfigString(key string) bool {
+	for _, s := range configStrings {
+		if s.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// readSecret reads a setting from a file, without the trailing newline.
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// applyEnv overrides cfg with the environment variables named by prefix.
+func (cfg *Config) applyEnv(prefix string, getenv func(string) string) error {
+	if u := getenv(prefix + "URL"); u != "" {
+		c, err := ParseURL(u)
+		if err != nil {
+			return fmt.Errorf("%sURL: %w", prefix, err)
+		}
+		cfg.merge(c)
+	}
+
+	for _, s := range configStrings {
+		name := prefix + strings.ToUpper(s.key)
+		v := getenv(name)
+		if file := getenv(name + "_FILE"); file != "" {
+			if v != "" {
+				return fmt.Errorf("both %s and %s_FILE are set", name, name)
+			}
+			var err error
+			if v, err = readSecret(file); err != nil {
+				return fmt.Errorf("%s_FILE: %w", name, err)
+			}
+		}
+		if v != "" {
+			*s.field(cfg) = v
+		}
+	}
+	if v := getenv(prefix + "REPLICAS"); v != "" {
+		cfg.Replicas = strings.Split(v, ",")
+	}
+
+	for _, param := range poolParams {
+		name := prefix + strings.ToUpper(param)
+		if v := getenv(name); v != "" {
+			if _, err := cfg.takeParams(url.Values{param: {v}}); err != nil {
+				return fmt.Errorf("%s: %w", name, errors.Unwrap(err))
+			}
+		}
+	}
+	return nil
+}
+
+// merge overrides cfg with the settings that are set in other.
+func (cfg *Config) merge(other *Config) {
+	for _, s := range configStrings {
+		if v := *s.field(other); v != "" {
+			*s.field(cfg) = v
+		}
+	}
+	if len(other.Replicas) > 0 {
+		cfg.Replicas = other.Replicas
+	}
+	if p := other.Pool; p != nil {
+		if p.MaxOpenConns != 0 {
+			cfg.pool().MaxOpenConns = p.MaxOpenConns
+		}
+		if p.MaxIdleConns != 0 {
+			cfg.pool().MaxIdleConns = p.MaxIdleConns
+		}
+		if p.ConnMaxLifetime != 0 {
+			cfg.pool().ConnMaxLifetime = p.ConnMaxLifetime
+		}
+		if p.ConnMaxIdleTime != 0 {
+			cfg.pool().ConnMaxIdleTime = p.ConnMaxIdleTime
+		}
+	}
+	for name, v := range other.Options {
+		if cfg.Options == nil {
+			cfg.Options = make(map[string]any)
+		}
+		cfg.Options[name] = v
+	}
+}
@@ -0,0 +1,10 @@
+package migrate
+
+// Clock and Timer let tests drive the lock of a Migrator without sleeping.
+type (
+	Clock = clock
+	Timer = timer
+)
+
+// SetClock replaces the time source of m.
+func SetClock(m *Migrator, c Clock) { m.clock = c }
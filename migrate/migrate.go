@@ -0,0 +1,432 @@
+// Package migrate runs versioned migrations against a [dbase.Database] and
+// records them in a history table (a bucket on KV drivers).
+//
+// Migrations run in the order they are given. Each one runs in its own
+// transaction together with its history record unless NoTx is set. A lock
+// record keeps concurrent instances from migrating at the same time; the
+// others wait until it is released and then find nothing left to do.
+//
+//	m, err := migrate.New(db, migrate.Options{},
+//	    migrate.Migration{
+//	        ID:   "0001",
+//	        Name: "create users",
+//	        Up: func(ctx context.Context, db dbase.Database) error {
+//	            return db.Migrate(ctx, &User{})
+//	        },
+//	    },
+//	)
+//	err = m.Up(ctx)
+//
+// Migrations that need the raw handle can reach it through the driver
+// type, e.g. db.(*gorm.DB).Gorm().Exec(...).
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nuln/dbase"
+)
+
+var (
+	// ErrLocked is returned when the migration lock could not be acquired
+	// before the context was done.
+	ErrLocked = errors.New("dbase/migrate: migrations locked by another instance")
+
+	// ErrLockLost is returned when the migration lock expired or was taken
+	// over while migrating. The running migration is cancelled through its
+	// context.
+	ErrLockLost = errors.New("dbase/migrate: migration lock lost")
+
+	// ErrIrreversible is returned by [Migrator.Down] for a migration
+	// without a Down step.
+	ErrIrreversible = fmt.Errorf("%w: irreversible migration", dbase.ErrNotSupported)
+)
+
+// Migration is a single schema or data change.
+type Migration struct {
+	// ID identifies the migration in the history. It must be unique and
+	// must not change once the migration has been applied.
+	ID string
+
+	// Name is a human-readable description.
+	Name string
+
+	// Up applies the migration.
+	Up func(ctx context.Context, db dbase.Database) error
+
+	// Down reverts the migration. It is optional.
+	Down func(ctx context.Context, db dbase.Database) error
+
+	// NoTx runs the migration outside a transaction, for statements that
+	// cannot run inside one (e.g. CREATE INDEX CONCURRENTLY).
+	NoTx bool
+}
+
+// SchemaMigration is a history record of an applied migration.
+type SchemaMigration struct {
+	ID        string `gorm:"primaryKey" storm:"id"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// SchemaMigrationLock is the lock record held while migrating.
+type SchemaMigrationLock struct {
+	ID        string `gorm:"primaryKey" storm:"id"`
+	Owner     string
+	ExpiresAt time.Time
+}
+
+const lockID = "lock"
+
+// Options configures a [Migrator].
+type Options struct {
+	// LockTTL is how long a lock is honored before another instance may
+	// take it over, e.g. after a crash. The holder renews it every third of
+	// LockTTL and cancels the running migration if it cannot renew it in
+	// time. Drivers with a single writer, such as bolt and SQLite, cannot
+	// renew it while a migration's transaction is open, so LockTTL must
+	// exceed the longest transactional migration there. Defaults to 10
+	// minutes.
+	LockTTL time.Duration
+
+	// PollInterval is how often a waiting instance retries the lock.
+	// Defaults to one second.
+	PollInterval time.Duration
+}
+
+// Status describes a migration and whether it has been applied.
+type Status struct {
+	ID        string
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+
+	// Unknown is set for history records without a matching migration.
+	Unknown bool
+}
+
+// Migrator applies and reverts a list of migrations.
+type Migrator struct {
+	db         dbase.Database
+	opts       Options
+	migrations []Migration
+	owner      string
+	clock      clock
+}
+
+// clock is the time source of a Migrator, replaced in tests.
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) timer
+}
+
+// timer is the part of [time.Timer] used by a Migrator.
+type timer interface {
+	Reset(d time.Duration) bool
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                            { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time    { return time.After(d) }
+func (realClock) AfterFunc(d time.Duration, f func()) timer { return time.AfterFunc(d, f) }
+
+// New returns a Migrator for migrations, which run in the given order.
+func New(db dbase.Database, opts Options, migrations ...Migration) (*Migrator, error) {
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = 10 * time.Minute
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+
+	seen := make(map[string]bool, len(migrations))
+	for i, m := range migrations {
+		switch {
+		case m.ID == "":
+			return nil, fmt.Errorf("dbase/migrate: migration %d has no ID", i)
+		case seen[m.ID]:
+			return nil, fmt.Errorf("dbase/migrate: duplicate migration ID %q", m.ID)
+		case m.Up == nil:
+			return nil, fmt.Errorf("dbase/migrate: migration %q has no Up step", m.ID)
+		}
+		seen[m.ID] = true
+	}
+
+	return &Migrator{db: db, opts: opts, migrations: migrations, owner: newOwner(), clock: realClock{}}, nil
+}
+
+func newOwner() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s/%d/%s", host, os.Getpid(), hex.EncodeToString(b))
+}
+
+// Up applies all pending migrations in order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.history(ctx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.ID]; ok {
+				continue
+			}
+			if err := m.apply(ctx, mig); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down reverts the last n applied migrations in reverse order.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.history(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.ID]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, mig); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Status lists the migrations in order followed by history records that
+// match no known migration.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.db.Migrate(ctx, &SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("dbase/migrate: create history: %w", err)
+	}
+	applied, err := m.history(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{ID: mig.ID, Name: mig.Name}
+		if rec, ok := applied[mig.ID]; ok {
+			st.Applied, st.AppliedAt = true, rec.AppliedAt
+			delete(applied, mig.ID)
+		}
+		statuses = append(statuses, st)
+	}
+	unknown := make([]Status, 0, len(applied))
+	for _, rec := range applied {
+		unknown = append(unknown, Status{
+			ID: rec.ID, Name: rec.Name, Applied: true, AppliedAt: rec.AppliedAt, Unknown: true,
+		})
+	}
+	slices.SortFunc(unknown, func(a, b Status) int { return strings.Compare(a.ID, b.ID) })
+	statuses = append(statuses, unknown...)
+	return statuses, nil
+}
+
+// history returns the applied migrations by ID.
+func (m *Migrator) history(ctx context.Context) (map[string]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := m.db.Find(ctx, &records, nil); err != nil {
+		return nil, fmt.Errorf("dbase/migrate: read history: %w", err)
+	}
+	applied := make(map[string]SchemaMigration, len(records))
+	for _, rec := range records {
+		applied[rec.ID] = rec
+	}
+	return applied, nil
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	run := func(db dbase.Database) error {
+		if err := mig.Up(ctx, db); err != nil {
+			return err
+		}
+		rec := &SchemaMigration{ID: mig.ID, Name: mig.Name, AppliedAt: m.clock.Now().UTC()}
+		return db.Create(ctx, rec)
+	}
+	if err := m.run(ctx, mig, run); err != nil {
+		return fmt.Errorf("dbase/migrate: up %s: %w", mig.ID, err)
+	}
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, mig Migration) error {
+	if mig.Down == nil {
+		return fmt.Errorf("dbase/migrate: down %s: %w", mig.ID, ErrIrreversible)
+	}
+	run := func(db dbase.Database) error {
+		if err := mig.Down(ctx, db); err != nil {
+			return err
+		}
+		return db.Delete(ctx, &SchemaMigration{}, mig.ID)
+	}
+	if err := m.run(ctx, mig, run); err != nil {
+		return fmt.Errorf("dbase/migrate: down %s: %w", mig.ID, err)
+	}
+	return nil
+}
+
+func (m *Migrator) run(ctx context.Context, mig Migration, fn func(db dbase.Database) error) error {
+	if mig.NoTx {
+		return fn(m.db)
+	}
+	return m.db.Transaction(ctx, fn)
+}
+
+// locked runs fn while holding the migration lock, waiting for it as long
+// as ctx allows. The lock is renewed while fn runs; if that fails until it
+// expires, or another instance took it over, the context passed to fn is
+// cancelled and [ErrLockLost] returned unless fn succeeded anyway.
+func (m *Migrator) locked(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := m.db.Migrate(ctx, &SchemaMigration{}, &SchemaMigrationLock{}); err != nil {
+		return fmt.Errorf("dbase/migrate: create history: %w", err)
+	}
+
+	var expires time.Time
+	for {
+		expires = m.clock.Now().UTC().Add(m.opts.LockTTL)
+		ok, err := m.tryLock(ctx, expires)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrLocked, ctx.Err())
+		case <-m.clock.After(m.opts.PollInterval):
+		}
+	}
+	defer m.unlock(context.WithoutCancel(ctx))
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	expiry := m.clock.AfterFunc(expires.Sub(m.clock.Now()), func() { cancel(ErrLockLost) })
+	defer expiry.Stop()
+
+	done := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		defer close(renewed)
+		m.keepLock(ctx, done, expiry, cancel)
+	}()
+	err := fn(ctx)
+	close(done)
+	<-renewed
+
+	// Work fn returned successfully from is committed, so losing the lock
+	// afterwards does not matter. That happens on single-writer drivers,
+	// where the renewal waits for the migration's transaction to end.
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) {
+		return cause
+	}
+	return err
+}
+
+// keepLock renews the lock every third of LockTTL until done is closed,
+// pushing back expiry on success. Failed renewals are retried at the next
+// tick; expiry cancels the migration if none succeeds in time.
+func (m *Migrator) keepLock(ctx context.Context, done <-chan struct{}, expiry timer, cancel context.CancelCauseFunc) {
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-m.clock.After(m.opts.LockTTL / 3):
+		}
+		expires, err := m.renew(ctx)
+		switch {
+		case errors.Is(err, ErrLockLost):
+			cancel(err)
+			return
+		case err == nil:
+			expiry.Reset(expires.Sub(m.clock.Now()))
+		}
+	}
+}
+
+// renew extends the lock held by m, failing with [ErrLockLost] if m no
+// longer holds it.
+func (m *Migrator) renew(ctx context.Context) (time.Time, error) {
+	expires := m.clock.Now().UTC().Add(m.opts.LockTTL)
+	err := m.db.Transaction(ctx, func(tx dbase.Database) error {
+		var held SchemaMigrationLock
+		err := tx.Get(ctx, &held, lockID)
+		switch {
+		case errors.Is(err, dbase.ErrNotFound):
+			return ErrLockLost
+		case err != nil:
+			return err
+		case held.Owner != m.owner:
+			return ErrLockLost
+		}
+		held.ExpiresAt = expires
+		return tx.Update(ctx, &held)
+	})
+	return expires, err
+}
+
+// tryLock takes the lock until expires unless another owner holds an
+// unexpired one.
+func (m *Migrator) tryLock(ctx context.Context, expires time.Time) (bool, error) {
+	now := m.clock.Now().UTC()
+	err := m.db.Transaction(ctx, func(tx dbase.Database) error {
+		var held SchemaMigrationLock
+		err := tx.Get(ctx, &held, lockID)
+		switch {
+		case err == nil && held.Owner != m.owner && held.ExpiresAt.After(now):
+			return ErrLocked
+		case err == nil:
+			if err := tx.Delete(ctx, &SchemaMigrationLock{}, lockID); err != nil {
+				return err
+			}
+		case !errors.Is(err, dbase.ErrNotFound):
+			return err
+		}
+		return tx.Create(ctx, &SchemaMigrationLock{ID: lockID, Owner: m.owner, ExpiresAt: expires})
+	})
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrLocked), errors.Is(err, dbase.ErrAlreadyExists), errors.Is(err, dbase.ErrTxFailed):
+		return false, nil // lost the race; retry
+	default:
+		return false, fmt.Errorf("dbase/migrate: lock: %w", err)
+	}
+}
+
+func (m *Migrator) unlock(ctx context.Context) {
+	_ = m.db.Transaction(ctx, func(tx dbase.Database) error {
+		var held SchemaMigrationLock
+		if err := tx.Get(ctx, &held, lockID); err != nil || held.Owner != m.owner {
+			return err
+		}
+		return tx.Delete(ctx, &SchemaMigrationLock{}, lockID)
+	})
+}
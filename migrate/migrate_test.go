@@ -0,0 +1,337 @@
+package migrate_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/bolt"
+	"github.com/nuln/dbase/gorm"
+	"github.com/nuln/dbase/migrate"
+)
+
+type Setting struct {
+	ID    string `gorm:"primaryKey" storm:"id"`
+	Value string
+}
+
+func migrations() []migrate.Migration {
+	return []migrate.Migration{
+		{
+			ID:   "0001",
+			Name: "create settings",
+			Up: func(ctx context.Context, db dbase.Database) error {
+				return db.Migrate(ctx, &Setting{})
+			},
+			Down: func(context.Context, dbase.Database) error { return nil },
+		},
+		{
+			ID:   "0002",
+			Name: "seed theme",
+			Up: func(ctx context.Context, db dbase.Database) error {
+				return db.Create(ctx, &Setting{ID: "theme", Value: "dark"})
+			},
+			Down: func(ctx context.Context, db dbase.Database) error {
+				return db.Delete(ctx, &Setting{}, "theme")
+			},
+		},
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	boltDB, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	gormDB, err := gorm.New("sqlite", sqlite.Open(filepath.Join(t.TempDir(), "test.sqlite")))
+	require.NoError(t, err)
+
+	for _, db := range []dbase.Database{boltDB, gormDB} {
+		t.Run(db.Driver(), func(t *testing.T) {
+			defer func() { _ = db.Close() }()
+			testMigrate(t, db)
+		})
+	}
+}
+
+func testMigrate(t *testing.T, db dbase.Database) {
+	ctx := context.Background()
+	m, err := migrate.New(db, migrate.Options{}, migrations()...)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Up(ctx))
+	require.NoError(t, m.Up(ctx), "Up must be idempotent")
+	var s Setting
+	require.NoError(t, db.Get(ctx, &s, "theme"))
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 2)
+	assert.True(t, status[0].Applied && status[1].Applied)
+	assert.False(t, status[1].AppliedAt.IsZero())
+
+	require.NoError(t, m.Down(ctx, 1))
+	assert.ErrorIs(t, db.Get(ctx, &s, "theme"), dbase.ErrNotFound)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status[0].Applied)
+	assert.False(t, status[1].Applied)
+
+	// A failing migration leaves neither its changes nor a history record.
+	failing := append(migrations(), migrate.Migration{
+		ID: "0003",
+		Up: func(ctx context.Context, db dbase.Database) error {
+			if err := db.Create(ctx, &Setting{ID: "lang", Value: "en"}); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+	m, err = migrate.New(db, migrate.Options{}, failing...)
+	require.NoError(t, err)
+	assert.ErrorContains(t, m.Up(ctx), "boom")
+	assert.ErrorIs(t, db.Get(ctx, &s, "lang"), dbase.ErrNotFound)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status[1].Applied, "migrations before the failure stay applied")
+	assert.False(t, status[2].Applied)
+
+	// Only the first migration has a Down step left to run after "0002".
+	m, err = migrate.New(db, migrate.Options{}, migrate.Migration{
+		ID: "0001", Up: migrations()[0].Up,
+	}, migrations()[1])
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Down(ctx, 2), migrate.ErrIrreversible)
+
+	// The history now contains records unknown to this migrator.
+	m, err = migrate.New(db, migrate.Options{})
+	require.NoError(t, err)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	assert.True(t, status[0].Unknown)
+	assert.Equal(t, "0001", status[0].ID)
+}
+
+func TestMigrateLock(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, db.Migrate(ctx, &migrate.SchemaMigrationLock{}))
+	require.NoError(t, db.Create(ctx, &migrate.SchemaMigrationLock{
+		ID: "lock", Owner: "other", ExpiresAt: time.Now().Add(time.Hour),
+	}))
+
+	m, err := migrate.New(db, migrate.Options{PollInterval: 10 * time.Millisecond}, migrations()...)
+	require.NoError(t, err)
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, m.Up(waitCtx), migrate.ErrLocked)
+
+	// An expired lock is taken over.
+	require.NoError(t, db.Update(ctx, &migrate.SchemaMigrationLock{
+		ID: "lock", Owner: "other", ExpiresAt: time.Now().Add(-time.Minute),
+	}))
+	require.NoError(t, m.Up(ctx))
+	var lock migrate.SchemaMigrationLock
+	assert.ErrorIs(t, db.Get(ctx, &lock, "lock"), dbase.ErrNotFound, "the lock is released")
+}
+
+// fakeClock is a [migrate.Clock] that only moves when advanced.
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	fire  func()
+	done  bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.AfterFunc(d, func() { ch <- c.Now() })
+	return ch
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) migrate.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), fire: f}
+	c.waiters = append(c.waiters, t)
+	return t
+}
+
+// Advance moves the clock forward and fires the timers that came due.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []func()
+	for _, t := range c.waiters {
+		if !t.done && !t.at.After(c.now) {
+			t.done = true
+			due = append(due, t.fire)
+		}
+	}
+	c.mu.Unlock()
+	for _, f := range due {
+		f()
+	}
+}
+
+// Pending returns the number of timers that have not fired or stopped.
+func (c *fakeClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, t := range c.waiters {
+		if !t.done {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := !t.done
+	t.at, t.done = t.clock.now.Add(d), false
+	return active
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := !t.done
+	t.done = true
+	return active
+}
+
+// waitForRenewal returns once a running migration waits for both the lock
+// expiry and the next renewal.
+func waitForRenewal(t *testing.T, clock *fakeClock) {
+	t.Helper()
+	require.Eventually(t, func() bool { return clock.Pending() == 2 }, 5*time.Second, time.Millisecond)
+}
+
+func TestMigrateLockRenewal(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	clock := newFakeClock()
+	started, release := make(chan struct{}), make(chan struct{})
+	m, err := migrate.New(db, migrate.Options{LockTTL: time.Minute}, migrate.Migration{
+		ID:   "0001",
+		NoTx: true,
+		Up: func(ctx context.Context, db dbase.Database) error {
+			close(started)
+			<-release
+			return ctx.Err()
+		},
+	})
+	require.NoError(t, err)
+	migrate.SetClock(m, clock)
+
+	errc := make(chan error, 1)
+	go func() { errc <- m.Up(ctx) }()
+	<-started
+
+	// Five renewals span more than one LockTTL.
+	for range 5 {
+		waitForRenewal(t, clock)
+		clock.Advance(20 * time.Second)
+		want := clock.Now().Add(time.Minute)
+		require.Eventually(t, func() bool {
+			var lock migrate.SchemaMigrationLock
+			return db.Get(ctx, &lock, "lock") == nil && lock.ExpiresAt.Equal(want)
+		}, 5*time.Second, time.Millisecond, "the lock must be renewed while migrating")
+	}
+	close(release)
+	require.NoError(t, <-errc)
+}
+
+func TestMigrateLockLost(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	clock := newFakeClock()
+	started := make(chan struct{})
+	m, err := migrate.New(db, migrate.Options{LockTTL: time.Minute}, migrate.Migration{
+		ID:   "0001",
+		NoTx: true,
+		Up: func(ctx context.Context, db dbase.Database) error {
+			if err := db.Update(ctx, &migrate.SchemaMigrationLock{
+				ID: "lock", Owner: "other", ExpiresAt: clock.Now().Add(time.Hour),
+			}); err != nil {
+				return err
+			}
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	})
+	require.NoError(t, err)
+	migrate.SetClock(m, clock)
+
+	errc := make(chan error, 1)
+	go func() { errc <- m.Up(ctx) }()
+	<-started
+	waitForRenewal(t, clock)
+	clock.Advance(20 * time.Second)
+	assert.ErrorIs(t, <-errc, migrate.ErrLockLost)
+
+	var lock migrate.SchemaMigrationLock
+	require.NoError(t, db.Get(ctx, &lock, "lock"))
+	assert.Equal(t, "other", lock.Owner, "a lock taken over is left to its new owner")
+}
+
+func TestMigrateLockExpiredAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	db, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// The renewal cannot run while the migration's bolt transaction is
+	// open, so the lock expires before the migration commits.
+	clock := newFakeClock()
+	m, err := migrate.New(db, migrate.Options{LockTTL: time.Minute}, migrate.Migration{
+		ID: "0001",
+		Up: func(ctx context.Context, db dbase.Database) error {
+			clock.Advance(2 * time.Minute)
+			return db.Migrate(ctx, &Setting{})
+		},
+	})
+	require.NoError(t, err)
+	migrate.SetClock(m, clock)
+
+	require.NoError(t, m.Up(ctx), "a committed migration must not be reported as failed")
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status[0].Applied)
+}
@@ -0,0 +1,183 @@
+package dbase
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithOptions declares the driver's options as a struct whose fields are
+// tagged with their [Config.Options] key, such as `option:"timeout"`.
+// [Config.Validate] then rejects unknown keys and values of the wrong type,
+// and [ParseURL] takes URL parameters with these names as options.
+func WithOptions(options any) DriverOption {
+	t := reflect.TypeOf(options)
+	return func(d *driver) {
+		d.options = t
+	}
+}
+
+// DecodeOptions decodes cfg.Options into the struct pointed to by dst,
+// matching keys with the `option` tags of its fields, including those of
+// embedded structs. Besides values of the field's type, it accepts strings,
+// as found in URLs and environment variables, for booleans, numbers and
+// durations, and comma-separated strings for string slices. Unknown keys
+// and invalid values are reported in an error wrapping [ErrInvalidConfig].
+//
+// Drivers call it from their [Factory]:
+//
+//	var opts Options
+//	if err := dbase.DecodeOptions(cfg, &opts); err != nil {
+//		return nil, err
+//	}
+func DecodeOptions(cfg *Config, dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dbase: decode options: %T is not a pointer to a struct", dst)
+	}
+	if problems := decodeOptions(cfg, v.Elem()); len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// decodeOptions decodes cfg.Options into the struct v and returns the
+// problems found.
+func decodeOptions(cfg *Config, v reflect.Value) []string {
+	fields := optionFields(v.Type())
+	var problems []string
+	for _, key := range slices.Sorted(maps.Keys(cfg.Options)) {
+		index, ok := fields[key]
+		if !ok {
+			msg := fmt.Sprintf("unknown option %q for driver %q", key, cfg.Type)
+			if s := suggest(key, slices.Collect(maps.Keys(fields))); s != "" {
+				msg += fmt.Sprintf(" (did you mean %q?)", s)
+			}
+			problems = append(problems, msg)
+			continue
+		}
+		if err := setOption(v.FieldByIndex(index), cfg.Options[key]); err != nil {
+			problems = append(problems, fmt.Sprintf("option %q for driver %q: %v", key, cfg.Type, err))
+		}
+	}
+	return problems
+}
+
+// optionFields maps the option keys of struct type t to field indexes.
+func optionFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for _, f := range reflect.VisibleFields(t) {
+		if key, ok := f.Tag.Lookup("option"); ok && f.IsExported() {
+			fields[key] = f.Index
+		}
+	}
+	return fields
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// setOption stores value in field, converting it as described in
+// [DecodeOptions].
+func setOption(field reflect.Value, value any) error {
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Type().AssignableTo(field.Type()) {
+		field.Set(rv)
+		return nil
+	}
+	s, isString := value.(string)
+
+	switch {
+	case field.Type() == durationType:
+		if !isString {
+			return fmt.Errorf("expected a duration such as \"5s\", got %T", value)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.Bool && isString:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("expected a boolean, got %q", s)
+		}
+		field.SetBool(b)
+	case field.CanInt() || field.CanUint():
+		n, err := optionInt(value)
+		if err != nil {
+			return err
+		}
+		if field.CanInt() {
+			if field.OverflowInt(n) {
+				return fmt.Errorf("%d is out of range", n)
+			}
+			field.SetInt(n)
+		} else {
+			if n < 0 || field.OverflowUint(uint64(n)) {
+				return fmt.Errorf("%d is out of range", n)
+			}
+			field.SetUint(uint64(n))
+		}
+	case field.CanFloat():
+		switch {
+		case isString:
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("expected a number, got %q", s)
+			}
+			field.SetFloat(f)
+		case rv.CanConvert(field.Type()) && rv.Kind() != reflect.String && rv.Kind() != reflect.Bool:
+			field.Set(rv.Convert(field.Type()))
+		default:
+			return fmt.Errorf("expected a number, got %T", value)
+		}
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		switch t := value.(type) {
+		case string:
+			items = strings.Split(t, ",")
+		case []any:
+			for _, item := range t {
+				s, ok := item.(string)
+				if !ok {
+					return fmt.Errorf("expected a list of strings, got %T", item)
+				}
+				items = append(items, s)
+			}
+		default:
+			return fmt.Errorf("expected a list of strings, got %T", value)
+		}
+		field.Set(reflect.ValueOf(items).Convert(field.Type()))
+	default:
+		return fmt.Errorf("expected %s, got %T", field.Type(), value)
+	}
+	return nil
+}
+
+// optionInt converts an integer option given as a number or string.
+func optionInt(value any) (int64, error) {
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.CanInt():
+		return rv.Int(), nil
+	case rv.CanUint() && rv.Uint() <= math.MaxInt64:
+		return int64(rv.Uint()), nil
+	case rv.CanFloat() && rv.Float() == math.Trunc(rv.Float()) && math.Abs(rv.Float()) <= 1<<53:
+		return int64(rv.Float()), nil // as decoded from JSON
+	case rv.Kind() == reflect.String:
+		n, err := strconv.ParseInt(rv.String(), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("expected an integer, got %q", rv.String())
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("expected an integer, got %v", value)
+}
@@ -0,0 +1,349 @@
+package dbase
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseQuery parses the textual filter syntax used by the dbase shell into
+// a [Query]:
+//
+//	Name = "Dune" and Year >= 1960 or Title like 'E%'
+//	    order by Year desc, Title limit 10 offset 20
+//
+// Conditions compare a field with a value using =, !=, <>, >, >=, <, <=,
+// like, prefix, in (...), not in (...), is null and is not null, and are
+// joined with and/or (and binds tighter). Values are quoted strings,
+// numbers, true, false or bare words, which are taken as strings. Keywords
+// are case-insensitive. An empty string yields an empty query.
+func ParseQuery(s string) (*Query, error) {
+	p := &queryParser{}
+	if err := p.tokenize(s); err != nil {
+		return nil, err
+	}
+	q := NewQuery()
+	if err := p.parse(q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+	pos    int
+}
+
+type queryParser struct {
+	tokens []token
+	i      int
+}
+
+func (p *queryParser) tokenize(s string) error {
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			text, n, err := unquote(s[i:])
+			if err != nil {
+				return fmt.Errorf("dbase: parse query: at %d: %w", i, err)
+			}
+			p.tokens = append(p.tokens, token{text: text, quoted: true, pos: i})
+			i += n
+		case strings.ContainsRune("(),", c):
+			p.tokens = append(p.tokens, token{text: string(c), pos: i})
+			i++
+		case strings.ContainsRune("=!<>", c):
+			n := 1
+			if i+1 < len(s) && strings.Contains("=>", string(s[i+1])) && s[i:i+2] != "=>" {
+				n = 2
+			}
+			p.tokens = append(p.tokens, token{text: s[i : i+n], pos: i})
+			i += n
+		default:
+			start := i
+			for i < len(s) && !unicode.IsSpace(rune(s[i])) && !strings.ContainsRune("(),=!<>\"'", rune(s[i])) {
+				i++
+			}
+			p.tokens = append(p.tokens, token{text: s[start:i], pos: start})
+		}
+	}
+	return nil
+}
+
+// unquote reads a string literal at the start of s and returns its value
+// and length. Double-quoted strings use Go escapes; single-quoted strings
+// use SQL style, where a quote is written twice.
+func unquote(s string) (string, int, error) {
+	if s[0] == '"' {
+		prefix, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", 0, fmt.Errorf("unterminated string")
+		}
+		v, err := strconv.Unquote(prefix)
+		return v, len(prefix), err
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\'' {
+			b.WriteByte('\'')
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+func (p *queryParser) peek() (token, bool) {
+	if p.i >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.i], true
+}
+
+func (p *queryParser) next() (token, error) {
+	t, ok := p.peek()
+	if !ok {
+		return token{}, fmt.Errorf("dbase: parse query: unexpected end")
+	}
+	p.i++
+	return t, nil
+}
+
+// keyword consumes the next token if it is one of the given keywords.
+func (p *queryParser) keyword(words ...string) (string, bool) {
+	t, ok := p.peek()
+	if !ok || t.quoted {
+		return "", false
+	}
+	for _, w := range words {
+		if strings.EqualFold(t.text, w) {
+			p.i++
+			return w, true
+		}
+	}
+	return "", false
+}
+
+func (p *queryParser) expect(words ...string) error {
+	if _, ok := p.keyword(words...); ok {
+		return nil
+	}
+	return p.unexpected(strings.Join(words, " or "))
+}
+
+func (p *queryParser) unexpected(want string) error {
+	t, ok := p.peek()
+	if !ok {
+		return fmt.Errorf("dbase: parse query: expected %s at end", want)
+	}
+	return fmt.Errorf("dbase: parse query: expected %s at %d, got %q", want, t.pos, t.text)
+}
+
+func (p *queryParser) parse(q *Query) error {
+	if _, ok := p.peek(); ok && !p.clauseStart() {
+		or := false
+		for {
+			cond, err := p.condition()
+			if err != nil {
+				return err
+			}
+			cond.Or = or
+			q.Conditions = append(q.Conditions, cond)
+			join, ok := p.keyword("and", "or")
+			if !ok {
+				break
+			}
+			or = join == "or"
+		}
+	}
+
+	if _, ok := p.keyword("order"); ok {
+		if err := p.expect("by"); err != nil {
+			return err
+		}
+		for {
+			field, err := p.field()
+			if err != nil {
+				return err
+			}
+			dir, _ := p.keyword("asc", "desc")
+			q.OrderBy = append(q.OrderBy, Order{Field: field, Descending: dir == "desc"})
+			if t, ok := p.peek(); !ok || t.text != "," {
+				break
+			}
+			p.i++
+		}
+	}
+	if _, ok := p.keyword("limit"); ok {
+		n, err := p.integer()
+		if err != nil {
+			return err
+		}
+		q.Limit = n
+	}
+	if _, ok := p.keyword("offset"); ok {
+		n, err := p.integer()
+		if err != nil {
+			return err
+		}
+		q.Offset = n
+	}
+	if _, ok := p.peek(); ok {
+		return p.unexpected("and, or, order by, limit or offset")
+	}
+	return nil
+}
+
+// clauseStart reports whether the next token starts a clause after the
+// conditions.
+func (p *queryParser) clauseStart() bool {
+	t, _ := p.peek()
+	if t.quoted {
+		return false
+	}
+	for _, w := range []string{"order", "limit", "offset"} {
+		if strings.EqualFold(t.text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) field() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if t.quoted || strings.ContainsAny(t.text, "(),=!<>") {
+		p.i--
+		return "", p.unexpected("field name")
+	}
+	return t.text, nil
+}
+
+func (p *queryParser) integer() (int, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(t.text)
+	if err != nil || n < 0 || t.quoted {
+		return 0, fmt.Errorf("dbase: parse query: expected a count at %d, got %q", t.pos, t.text)
+	}
+	return n, nil
+}
+
+var comparisons = map[string]Operator{
+	"=": OpEqual, "==": OpEqual, "!=": OpNotEqual, "<>": OpNotEqual,
+	">": OpGreater, ">=": OpGreaterEqual, "<": OpLess, "<=": OpLessEqual,
+	"like": OpLike, "prefix": OpPrefix,
+}
+
+func (p *queryParser) condition() (Condition, error) {
+	field, err := p.field()
+	if err != nil {
+		return Condition{}, err
+	}
+	cond := Condition{Field: field}
+
+	if _, ok := p.keyword("is"); ok {
+		cond.Operator = OpIsNull
+		if _, not := p.keyword("not"); not {
+			cond.Operator = OpNotNull
+		}
+		return cond, p.expect("null")
+	}
+	if _, ok := p.keyword("not"); ok {
+		if err := p.expect("in"); err != nil {
+			return Condition{}, err
+		}
+		cond.Operator = OpNotIn
+		cond.Value, err = p.list()
+		return cond, err
+	}
+	if _, ok := p.keyword("in"); ok {
+		cond.Operator = OpIn
+		cond.Value, err = p.list()
+		return cond, err
+	}
+
+	t, err := p.next()
+	if err != nil {
+		return Condition{}, err
+	}
+	op, ok := comparisons[strings.ToLower(t.text)]
+	if !ok || t.quoted {
+		p.i--
+		return Condition{}, p.unexpected("an operator")
+	}
+	cond.Operator = op
+	cond.Value, err = p.value()
+	return cond, err
+}
+
+func (p *queryParser) list() ([]any, error) {
+	if t, err := p.next(); err != nil || t.text != "(" || t.quoted {
+		if err == nil {
+			p.i--
+		}
+		return nil, p.unexpected("(")
+	}
+	var values []any
+	for {
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		t, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.quoted:
+		case t.text == ",":
+			continue
+		case t.text == ")":
+			return values, nil
+		}
+		p.i--
+		return nil, p.unexpected(", or )")
+	}
+}
+
+func (p *queryParser) value() (any, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.quoted {
+		return t.text, nil
+	}
+	if strings.ContainsAny(t.text, "(),=!<>") {
+		p.i--
+		return nil, p.unexpected("a value")
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return f, nil
+	}
+	return t.text, nil
+}
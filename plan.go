@@ -0,0 +1,115 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ChangeKind classifies a [SchemaChange].
+type ChangeKind string
+
+const (
+	// ChangeCreateTable is a missing table (or bucket) that Migrate creates.
+	ChangeCreateTable ChangeKind = "create_table"
+
+	// ChangeAddColumn is a model field without a column.
+	ChangeAddColumn ChangeKind = "add_column"
+
+	// ChangeAlterColumn is a column whose type or constraints differ from
+	// the model.
+	ChangeAlterColumn ChangeKind = "alter_column"
+
+	// ChangeCreateIndex is an index declared by the model but missing from
+	// the database.
+	ChangeCreateIndex ChangeKind = "create_index"
+
+	// ChangeExtraColumn is a column without a model field. Migrate leaves
+	// it in place; it is reported for information only.
+	ChangeExtraColumn ChangeKind = "extra_column"
+
+	// ChangeExtraIndex is an index that the model no longer declares.
+	ChangeExtraIndex ChangeKind = "extra_index"
+)
+
+// SchemaChange is a single difference between a model and the live schema.
+type SchemaChange struct {
+	Kind ChangeKind `json:"kind" yaml:"kind"`
+
+	// Table is the table or bucket name.
+	Table string `json:"table" yaml:"table"`
+
+	// Column is the affected column or field, if any.
+	Column string `json:"column,omitempty" yaml:"column,omitempty"`
+
+	// Index is the affected index, if any.
+	Index string `json:"index,omitempty" yaml:"index,omitempty"`
+
+	// From and To describe the current and the desired definition.
+	From string `json:"from,omitempty" yaml:"from,omitempty"`
+	To   string `json:"to,omitempty" yaml:"to,omitempty"`
+
+	// DDL holds the statements Migrate would run for this change. It is
+	// empty for drivers without DDL and for informational changes.
+	DDL []string `json:"ddl,omitempty" yaml:"ddl,omitempty"`
+}
+
+// String returns a one-line description of the change.
+func (c SchemaChange) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", c.Kind, c.Table)
+	if c.Column != "" {
+		fmt.Fprintf(&b, ".%s", c.Column)
+	}
+	if c.Index != "" {
+		fmt.Fprintf(&b, " index %s", c.Index)
+	}
+	switch {
+	case c.From != "" && c.To != "":
+		fmt.Fprintf(&b, ": %s -> %s", c.From, c.To)
+	case c.To != "":
+		fmt.Fprintf(&b, ": %s", c.To)
+	case c.From != "":
+		fmt.Fprintf(&b, ": %s", c.From)
+	}
+	return b.String()
+}
+
+// MigrationPlan is the result of a dry-run migration.
+type MigrationPlan struct {
+	Driver  string         `json:"driver" yaml:"driver"`
+	Changes []SchemaChange `json:"changes" yaml:"changes"`
+}
+
+// Empty reports whether the plan has no changes.
+func (p *MigrationPlan) Empty() bool { return len(p.Changes) == 0 }
+
+// DDL returns the statements of all changes in order.
+func (p *MigrationPlan) DDL() []string {
+	var ddl []string
+	for _, c := range p.Changes {
+		ddl = append(ddl, c.DDL...)
+	}
+	return ddl
+}
+
+// MigrationPlanner is implemented by drivers that can compare models to
+// the live schema without changing it.
+type MigrationPlanner interface {
+	// PlanMigrate reports what Migrate would change for models.
+	PlanMigrate(ctx context.Context, models ...any) (*MigrationPlan, error)
+}
+
+// PlanMigrate runs a dry-run migration of models on db. It returns
+// [ErrNotSupported] if the driver does not implement [MigrationPlanner].
+func PlanMigrate(ctx context.Context, db Database, models ...any) (*MigrationPlan, error) {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := db.(MigrationPlanner)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s cannot plan migrations", ErrNotSupported, db.Driver())
+	}
+	return p.PlanMigrate(ctx, models...)
+}
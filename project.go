@@ -0,0 +1,168 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// Projector is implemented by drivers that load projections directly,
+// reading only the selected columns.
+type Projector interface {
+	// Project loads the records of model matching query into dest; see
+	// [Project].
+	Project(ctx context.Context, model, dest any, query *Query) error
+}
+
+// Project loads the records of model matching query into dest, which may
+// be of another type than model: a pointer to a struct, a slice of
+// structs, a map[string]any or a slice of such maps. Struct fields are
+// filled from the model fields of the same Go or column name; maps are
+// keyed by column name. Only query.Fields are loaded, or every field when
+// it is empty. A single destination receives the first record, or
+// [ErrNotFound] is returned.
+//
+//	var cities []struct{ City string }
+//	err := dbase.Project(ctx, db, &User{}, &cities, dbase.NewQuery().Distinct("City"))
+//
+// Drivers implementing [Projector] load the columns directly; for the
+// others the records are read with Find and copied.
+func Project(ctx context.Context, db Database, model, dest any, query *Query) error {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return err
+	}
+	if p, ok := db.(Projector); ok {
+		return p.Project(ctx, model, dest, query)
+	}
+
+	m, err := schema.Of(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidModel, err)
+	}
+	fields := m.Fields
+	if query != nil && len(query.Fields) > 0 {
+		fields = make([]*schema.Field, len(query.Fields))
+		for i, name := range query.Fields {
+			if fields[i] = m.Field(name); fields[i] == nil {
+				return fmt.Errorf("%w: %s has no field %q", ErrInvalidModel, m.Name, name)
+			}
+		}
+	}
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("%w: projection target must be a non-nil pointer", ErrInvalidModel)
+	}
+	target := v.Elem()
+
+	if target.Kind() == reflect.Slice {
+		records := reflect.New(reflect.SliceOf(reflect.PointerTo(m.Type)))
+		if err := db.Find(ctx, records.Interface(), query); err != nil {
+			return err
+		}
+		out := reflect.MakeSlice(target.Type(), 0, records.Elem().Len())
+		for i := 0; i < records.Elem().Len(); i++ {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := projectInto(elem, records.Elem().Index(i), fields); err != nil {
+				return err
+			}
+			out = reflect.Append(out, elem)
+		}
+		target.Set(out)
+		return nil
+	}
+
+	rec := m.New()
+	if err := db.FindOne(ctx, rec.Interface(), query); err != nil {
+		return err
+	}
+	return projectInto(target, rec, fields)
+}
+
+// projectInto copies fields of the record rec into dst, a struct, a
+// pointer to one or a map[string]any.
+func projectInto(dst, rec reflect.Value, fields []*schema.Field) error {
+	switch {
+	case dst.Kind() == reflect.Ptr:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return projectInto(dst.Elem(), rec, fields)
+
+	case dst.Kind() == reflect.Map && dst.Type().Key().Kind() == reflect.String &&
+		dst.Type().Elem().Kind() == reflect.Interface:
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(fields)))
+		}
+		for _, f := range fields {
+			value := reflect.ValueOf(f.Interface(rec))
+			if !value.IsValid() {
+				value = reflect.Zero(dst.Type().Elem())
+			}
+			dst.SetMapIndex(reflect.ValueOf(f.Column).Convert(dst.Type().Key()), value)
+		}
+		return nil
+
+	case dst.Kind() == reflect.Struct:
+		byName := make(map[string]*schema.Field, 2*len(fields))
+		for _, f := range fields {
+			byName[strings.ToLower(f.Name)] = f
+			byName[f.Column] = f
+		}
+		t := dst.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			f := byName[strings.ToLower(sf.Name)]
+			if f == nil {
+				f = byName[schema.ToSnake(sf.Name)]
+			}
+			if f == nil {
+				continue
+			}
+			if err := assign(dst.Field(i), f.Value(rec)); err != nil {
+				return fmt.Errorf("dbase: project %s: %w", f.Name, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: cannot project into %s", ErrInvalidModel, dst.Type())
+}
+
+// assign sets dst to value, converting between compatible types and
+// between pointers and the values they point to.
+func assign(dst, value reflect.Value) error {
+	if !value.IsValid() {
+		return nil
+	}
+	if value.Type().AssignableTo(dst.Type()) {
+		dst.Set(value)
+		return nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			dst.SetZero()
+			return nil
+		}
+		return assign(dst, value.Elem())
+	}
+	if dst.Kind() == reflect.Ptr {
+		p := reflect.New(dst.Type().Elem())
+		if err := assign(p.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(p)
+		return nil
+	}
+	v, err := schema.Convert(value.Interface(), dst.Type())
+	if err != nil {
+		return err
+	}
+	dst.Set(v)
+	return nil
+}
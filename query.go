@@ -6,6 +6,15 @@ type Query struct {
 	Limit      int
 	Offset     int
 	OrderBy    []Order
+
+	// Fields lists the fields to load, by Go or column name; the others
+	// are left zero. Empty loads every field.
+	Fields []string
+
+	// Unique drops results equal to an earlier one in Fields, or in every
+	// field when Fields is empty, as SELECT DISTINCT does. Limit and
+	// Offset apply to the remaining results.
+	Unique bool
 }
 
 // Condition represents a single query condition.
@@ -90,6 +99,19 @@ func (q *Query) SetOffset(offset int) *Query {
 	return q
 }
 
+// Select restricts the loaded fields to fields; see [Query.Fields].
+func (q *Query) Select(fields ...string) *Query {
+	q.Fields = append(q.Fields, fields...)
+	return q
+}
+
+// Distinct drops duplicate results, comparing the given fields, which are
+// also selected; see [Query.Unique].
+func (q *Query) Distinct(fields ...string) *Query {
+	q.Unique = true
+	return q.Select(fields...)
+}
+
 // IsEmpty reports whether the query has no conditions.
 func (q *Query) IsEmpty() bool {
 	return q == nil || len(q.Conditions) == 0
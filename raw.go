@@ -0,0 +1,53 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+)
+
+// RawQuerier is implemented by SQL drivers to run hand-written statements
+// when the query builder is not enough. The Database passed to a
+// Transaction function implements it as well, running the statements in
+// the transaction.
+type RawQuerier interface {
+	// Raw runs a query and scans its rows into dest, a pointer to a
+	// struct, a slice of structs, a map[string]any, a slice of such maps
+	// or a single value.
+	Raw(ctx context.Context, dest any, query string, args ...any) error
+
+	// Exec runs a statement and returns the number of rows affected.
+	Exec(ctx context.Context, query string, args ...any) (int64, error)
+}
+
+// Raw runs query on db and scans its rows into dest; see [RawQuerier]. It
+// returns [ErrNotSupported] if the driver does not implement RawQuerier.
+func Raw(ctx context.Context, db Database, dest any, query string, args ...any) error {
+	r, err := rawQuerier(ctx, db)
+	if err != nil {
+		return err
+	}
+	return r.Raw(ctx, dest, query, args...)
+}
+
+// Exec runs a statement on db and returns the number of rows affected. It
+// returns [ErrNotSupported] if the driver does not implement
+// [RawQuerier].
+func Exec(ctx context.Context, db Database, query string, args ...any) (int64, error) {
+	r, err := rawQuerier(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+	return r.Exec(ctx, query, args...)
+}
+
+func rawQuerier(ctx context.Context, db Database) (RawQuerier, error) {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := db.(RawQuerier)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s cannot run raw queries", ErrNotSupported, db.Driver())
+	}
+	return r, nil
+}
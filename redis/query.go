@@ -0,0 +1,257 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// selectRecords returns pointers to the records of m matching query, sorted
+// and paginated. Equality conditions on the ID or an indexed field narrow
+// the candidates through index sets; everything else is evaluated in
+// memory over the candidates.
+func (d *DB) selectRecords(ctx context.Context, m *schema.Model, query *dbase.Query) ([]reflect.Value, error) {
+	q := dbase.Query{}
+	if query != nil {
+		q = *query
+	}
+
+	ids, source, err := d.candidates(ctx, m, q.Conditions)
+	if err != nil {
+		return nil, err
+	}
+	raws, err := d.load(ctx, m, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []reflect.Value
+	var stale []any
+	for i, raw := range raws {
+		if raw == nil {
+			stale = append(stale, ids[i])
+			continue
+		}
+		rec := m.New()
+		if err := json.Unmarshal([]byte(*raw), rec.Interface()); err != nil {
+			return nil, err
+		}
+		ok, err := eval.Match(eval.StructGetter(m, rec), q.Conditions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			items = append(items, rec)
+		}
+	}
+
+	// Records that expired through a TTL leave their IDs behind in the sets
+	// they were found through; prune them lazily.
+	if len(stale) > 0 && source != "" && d.tx == nil {
+		_ = d.client.SRem(ctx, source, stale...).Err()
+	}
+
+	eval.Sort(items, q.OrderBy, func(rec reflect.Value) eval.Getter {
+		return eval.StructGetter(m, rec)
+	})
+	return eval.Select(items, m, &q)
+}
+
+// candidates returns the IDs that may match conds and the set they were
+// read from, if any. Inside a transaction that wrote to m, pending records
+// are merged in and indexes are bypassed.
+func (d *DB) candidates(ctx context.Context, m *schema.Model, conds []dbase.Condition) ([]string, string, error) {
+	if d.tx == nil || len(d.tx.touched[m.Name]) == 0 {
+		if ids, ok, err := d.lookupIndex(ctx, m, conds); ok || err != nil {
+			return ids, "", err
+		}
+	}
+
+	ids, err := d.client.SMembers(ctx, d.idsKey(m)).Result()
+	if err != nil {
+		return nil, "", err
+	}
+	if d.tx != nil {
+		seen := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			seen[id] = true
+		}
+		for id := range d.tx.touched[m.Name] {
+			if !seen[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, d.idsKey(m), nil
+}
+
+// lookupIndex resolves the first equality or IN condition on the ID or an
+// indexed field of a conjunctive query. ok is false if none applies.
+func (d *DB) lookupIndex(ctx context.Context, m *schema.Model, conds []dbase.Condition) (ids []string, ok bool, err error) {
+	for _, c := range conds {
+		if c.Or {
+			return nil, false, nil
+		}
+	}
+
+	for _, c := range conds {
+		f := m.Field(c.Field)
+		if f == nil || (f != m.ID && !f.Indexed) {
+			continue
+		}
+
+		var values []string
+		switch c.Operator {
+		case dbase.OpEqual:
+			v, err := schema.Convert(c.Value, f.Type)
+			if err != nil {
+				continue
+			}
+			values = []string{keyString(v)}
+		case dbase.OpIn:
+			list := reflect.ValueOf(c.Value)
+			if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+				continue
+			}
+			for i := 0; i < list.Len(); i++ {
+				v, err := schema.Convert(list.Index(i).Interface(), f.Type)
+				if err != nil {
+					return nil, false, nil
+				}
+				values = append(values, keyString(v))
+			}
+		default:
+			continue
+		}
+
+		switch {
+		case f == m.ID:
+			return values, true, nil
+		case f.Unique:
+			owners, err := d.client.HMGet(ctx, d.uniqueKey(m, f), values...).Result()
+			if err != nil {
+				return nil, true, err
+			}
+			for _, o := range owners {
+				if s, isString := o.(string); isString {
+					ids = append(ids, s)
+				}
+			}
+			return ids, true, nil
+		default:
+			keys := make([]string, len(values))
+			for i, v := range values {
+				keys[i] = d.indexKey(m, f, v)
+			}
+			ids, err := d.client.SUnion(ctx, keys...).Result()
+			return ids, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// load fetches the raw records for ids, preferring pending transaction
+// writes. Missing records yield nil entries.
+func (d *DB) load(ctx context.Context, m *schema.Model, ids []string) ([]*string, error) {
+	out := make([]*string, len(ids))
+	var keys []string
+	var pos []int
+	for i, id := range ids {
+		key := d.recordKey(m, id)
+		if d.tx != nil {
+			if v, ok := d.tx.records[key]; ok {
+				out[i] = v
+				continue
+			}
+		}
+		keys = append(keys, key)
+		pos = append(pos, i)
+	}
+	if len(keys) == 0 {
+		return out, nil
+	}
+
+	values, err := d.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for j, v := range values {
+		if s, ok := v.(string); ok {
+			out[pos[j]] = &s
+		}
+	}
+	return out, nil
+}
+
+// migrate rebuilds the index sets and unique hashes of m when its index
+// layout differs from the one recorded by the previous migration.
+func (d *DB) migrate(ctx context.Context, m *schema.Model) error {
+	layout := make([]string, 0, len(m.Indexes))
+	for _, f := range m.Indexes {
+		kind := "index"
+		if f.Unique {
+			kind = "unique"
+		}
+		layout = append(layout, f.Name+":"+kind)
+	}
+	want := strings.Join(layout, ",")
+
+	have, err := d.client.Get(ctx, d.metaKey(m)).Result()
+	switch {
+	case err == nil && have == want:
+		return nil
+	case err != nil && !errors.Is(err, redis.Nil):
+		return err
+	}
+
+	for _, pattern := range []string{
+		d.opts.Prefix + m.Name + ":idx:*",
+		d.opts.Prefix + m.Name + ":uniq:*",
+	} {
+		iter := d.client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			if err := d.client.Del(ctx, iter.Val()).Err(); err != nil {
+				return err
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+	}
+
+	ids, err := d.client.SMembers(ctx, d.idsKey(m)).Result()
+	if err != nil {
+		return err
+	}
+	raws, err := d.load(ctx, m, ids)
+	if err != nil {
+		return err
+	}
+	tx := newTxState(d)
+	for i, raw := range raws {
+		if raw == nil {
+			continue
+		}
+		rec := m.New()
+		if err := json.Unmarshal([]byte(*raw), rec.Interface()); err != nil {
+			return err
+		}
+		tx.index(m, rec, ids[i])
+	}
+	tx.queue(func(ctx context.Context, p redis.Pipeliner) { p.Set(ctx, d.metaKey(m), want, 0) })
+	_, err = d.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		for _, cmd := range tx.cmds {
+			cmd(ctx, p)
+		}
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,113 @@
+package redis_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/dbasetest"
+	"github.com/nuln/dbase/redis"
+)
+
+func TestRedis(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	db, err := redis.New("redis://"+mr.Addr(), redis.Options{Prefix: "test:"})
+	if err != nil {
+		t.Fatalf("failed to open redis: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	dbasetest.Suite(t, db)
+}
+
+type session struct {
+	ID     string `storm:"id"`
+	UserID int    `storm:"index"`
+	Token  string `storm:"unique"`
+}
+
+func TestRedisReservedIDs(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	db, err := redis.New("redis://"+mr.Addr(), redis.Options{})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	require.NoError(t, db.Migrate(ctx, &session{}))
+
+	// IDs named like the model's internal keys must not clobber them.
+	ids := []string{"ids", "seq", "meta", "idx:x", "uniq:Token"}
+	for i, id := range ids {
+		require.NoError(t, db.Create(ctx, &session{ID: id, UserID: 1, Token: fmt.Sprint(i)}))
+	}
+	var found []session
+	require.NoError(t, db.Find(ctx, &found, dbase.Eq("UserID", 1)))
+	assert.Len(t, found, len(ids))
+
+	// Changing the index tags rebuilds the index keys, which must leave
+	// the records alone.
+	{
+		type session struct {
+			ID     string `storm:"id"`
+			UserID int
+			Token  string `storm:"unique"`
+		}
+		require.NoError(t, db.Migrate(ctx, &session{}))
+		for _, id := range ids {
+			var s session
+			require.NoError(t, db.Get(ctx, &s, id), id)
+		}
+		count, err := db.Count(ctx, &session{}, nil)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(ids), count)
+	}
+}
+
+func TestRedisTTLAndConflicts(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	db, err := redis.New("redis://"+mr.Addr(), redis.Options{TTL: time.Minute})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, db.Create(ctx, &session{ID: "s1", UserID: 7, Token: "a"}))
+	require.NoError(t, db.Create(redis.WithTTL(ctx, 0), &session{ID: "s2", UserID: 7, Token: "b"}))
+	assert.Equal(t, time.Minute, mr.TTL("session:r:s1"))
+	assert.Zero(t, mr.TTL("session:r:s2"))
+
+	err = db.Create(ctx, &session{ID: "s3", UserID: 8, Token: "a"})
+	assert.ErrorIs(t, err, dbase.ErrAlreadyExists)
+
+	mr.FastForward(2 * time.Minute)
+	var found []session
+	require.NoError(t, db.Find(ctx, &found, dbase.Eq("UserID", 7)))
+	require.Len(t, found, 1)
+	assert.Equal(t, "s2", found[0].ID)
+
+	// The expired owner no longer holds its unique value.
+	require.NoError(t, db.Create(ctx, &session{ID: "s3", UserID: 8, Token: "a"}))
+
+	err = db.Transaction(ctx, func(tx dbase.Database) error {
+		var s session
+		if err := tx.Get(ctx, &s, "s2"); err != nil {
+			return err
+		}
+		s.UserID = 9
+		if err := tx.Update(ctx, &s); err != nil {
+			return err
+		}
+		// A concurrent writer changes the record read by the transaction.
+		return db.UpdateFields(ctx, &session{ID: "s2", UserID: 10, Token: "b"}, "UserID")
+	})
+	assert.ErrorIs(t, err, dbase.ErrTxFailed)
+
+	var s session
+	require.NoError(t, db.Get(ctx, &s, "s2"))
+	assert.Equal(t, 10, s.UserID)
+}
@@ -0,0 +1,285 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/record"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+var errConflict = errors.New("watched key changed")
+
+// observed is the value of a key (or hash field) seen by a transaction.
+type observed struct {
+	value string
+	found bool
+}
+
+// txState buffers the writes of a transaction. Reads are recorded so that
+// commit can WATCH the keys, verify they still hold the observed values
+// and then apply the buffered commands with MULTI/EXEC.
+type txState struct {
+	db    *DB
+	reads map[string]observed // key or key+"\x00"+field -> observed value
+	cmds  []func(ctx context.Context, p redis.Pipeliner)
+
+	// records and hashes hold pending values; a nil entry means deleted.
+	records map[string]*string
+	hashes  map[string]*string
+
+	// touched lists the IDs written per model, so that queries inside the
+	// transaction can include pending records.
+	touched map[string]map[string]bool
+}
+
+func newTxState(d *DB) *txState {
+	return &txState{
+		db:      d,
+		reads:   make(map[string]observed),
+		records: make(map[string]*string),
+		hashes:  make(map[string]*string),
+		touched: make(map[string]map[string]bool),
+	}
+}
+
+// get returns the value of key as seen by the transaction.
+func (tx *txState) get(ctx context.Context, key string) (string, bool, error) {
+	if v, ok := tx.records[key]; ok {
+		if v == nil {
+			return "", false, nil
+		}
+		return *v, true, nil
+	}
+	if o, ok := tx.reads[key]; ok {
+		return o.value, o.found, nil
+	}
+	raw, err := tx.db.client.Get(ctx, key).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", false, err
+	}
+	tx.reads[key] = observed{value: raw, found: err == nil}
+	return raw, err == nil, nil
+}
+
+// hget returns a hash field as seen by the transaction.
+func (tx *txState) hget(ctx context.Context, key, field string) (string, bool, error) {
+	id := key + "\x00" + field
+	if v, ok := tx.hashes[id]; ok {
+		if v == nil {
+			return "", false, nil
+		}
+		return *v, true, nil
+	}
+	if o, ok := tx.reads[id]; ok {
+		return o.value, o.found, nil
+	}
+	raw, err := tx.db.client.HGet(ctx, key, field).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return "", false, err
+	}
+	tx.reads[id] = observed{value: raw, found: err == nil}
+	return raw, err == nil, nil
+}
+
+func (tx *txState) queue(cmd func(ctx context.Context, p redis.Pipeliner)) {
+	tx.cmds = append(tx.cmds, cmd)
+}
+
+func (tx *txState) touch(m *schema.Model, id string) {
+	ids := tx.touched[m.Name]
+	if ids == nil {
+		ids = make(map[string]bool)
+		tx.touched[m.Name] = ids
+	}
+	ids[id] = true
+}
+
+// put buffers a write of the struct v, assigning an ID from the model
+// sequence when needed and updating index sets and unique hashes.
+func (tx *txState) put(ctx context.Context, m *schema.Model, v reflect.Value, mode record.Mode) error {
+	d := tx.db
+	// Sequence values are never reused, even if the transaction fails.
+	err := record.AssignID(m, v, mode, func() (any, error) { return d.client.Incr(ctx, d.seqKey(m)).Result() })
+	if err != nil {
+		return err
+	}
+
+	idField := m.ID.Value(v)
+	id := keyString(idField)
+	key := d.recordKey(m, id)
+	oldRaw, exists, err := tx.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := mode.Check(m, id, exists); err != nil {
+		return err
+	}
+
+	var old reflect.Value
+	if exists {
+		old = m.New()
+		if err := json.Unmarshal([]byte(oldRaw), old.Interface()); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range m.Indexes {
+		if !f.Unique {
+			continue
+		}
+		value := keyString(f.Value(v))
+		owner, claimed, err := tx.hget(ctx, d.uniqueKey(m, f), value)
+		if err != nil {
+			return err
+		}
+		if !claimed || owner == id {
+			continue
+		}
+		// The owner may have expired; its claim is then stale.
+		if _, alive, err := tx.get(ctx, d.recordKey(m, owner)); err != nil {
+			return err
+		} else if alive {
+			return fmt.Errorf("%w: %s.%s must be unique", dbase.ErrAlreadyExists, m.Name, f.Name)
+		}
+	}
+
+	raw, err := json.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Errorf("dbase/redis: encode %s: %w", m.Name, err)
+	}
+	if exists {
+		tx.unindex(m, old, id, v)
+	}
+	tx.index(m, v, id)
+
+	ttl := d.ttl(ctx)
+	data := string(raw)
+	tx.queue(func(ctx context.Context, p redis.Pipeliner) {
+		p.Set(ctx, key, data, ttl)
+		p.SAdd(ctx, d.idsKey(m), id)
+	})
+	tx.records[key] = &data
+	tx.touch(m, id)
+	return nil
+}
+
+// delete buffers the removal of a record and its index entries.
+func (tx *txState) delete(ctx context.Context, m *schema.Model, id string) error {
+	d := tx.db
+	key := d.recordKey(m, id)
+	raw, found, err := tx.get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return dbase.ErrNotFound
+	}
+	old := m.New()
+	if err := json.Unmarshal([]byte(raw), old.Interface()); err != nil {
+		return err
+	}
+
+	tx.unindex(m, old, id, reflect.Value{})
+	tx.queue(func(ctx context.Context, p redis.Pipeliner) {
+		p.Del(ctx, key)
+		p.SRem(ctx, d.idsKey(m), id)
+	})
+	tx.records[key] = nil
+	tx.touch(m, id)
+	return nil
+}
+
+// index buffers the index entries of v.
+func (tx *txState) index(m *schema.Model, v reflect.Value, id string) {
+	d := tx.db
+	for _, f := range m.Indexes {
+		value := keyString(f.Value(v))
+		if f.Unique {
+			ukey := d.uniqueKey(m, f)
+			owner := id
+			tx.hashes[ukey+"\x00"+value] = &owner
+			tx.queue(func(ctx context.Context, p redis.Pipeliner) { p.HSet(ctx, ukey, value, id) })
+			continue
+		}
+		ikey := d.indexKey(m, f, value)
+		tx.queue(func(ctx context.Context, p redis.Pipeliner) { p.SAdd(ctx, ikey, id) })
+	}
+}
+
+// unindex buffers the removal of the index entries of old that differ in
+// next (or all of them when next is invalid).
+func (tx *txState) unindex(m *schema.Model, old reflect.Value, id string, next reflect.Value) {
+	d := tx.db
+	for _, f := range m.Indexes {
+		value := keyString(f.Value(old))
+		if next.IsValid() && keyString(f.Value(next)) == value {
+			continue
+		}
+		if f.Unique {
+			ukey := d.uniqueKey(m, f)
+			tx.hashes[ukey+"\x00"+value] = nil
+			tx.queue(func(ctx context.Context, p redis.Pipeliner) { p.HDel(ctx, ukey, value) })
+			continue
+		}
+		ikey := d.indexKey(m, f, value)
+		tx.queue(func(ctx context.Context, p redis.Pipeliner) { p.SRem(ctx, ikey, id) })
+	}
+}
+
+// commit applies the buffered writes atomically, failing with
+// [dbase.ErrTxFailed] if any key read by the transaction has changed.
+func (tx *txState) commit(ctx context.Context) error {
+	if len(tx.cmds) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tx.reads))
+	seen := make(map[string]bool)
+	for id := range tx.reads {
+		key, _, _ := strings.Cut(id, "\x00")
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	apply := func(rtx *redis.Tx) error {
+		for id, o := range tx.reads {
+			key, field, isHash := strings.Cut(id, "\x00")
+			var cmd *redis.StringCmd
+			if isHash {
+				cmd = rtx.HGet(ctx, key, field)
+			} else {
+				cmd = rtx.Get(ctx, key)
+			}
+			raw, err := cmd.Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if (err == nil) != o.found || raw != o.value {
+				return errConflict
+			}
+		}
+		_, err := rtx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			for _, cmd := range tx.cmds {
+				cmd(ctx, p)
+			}
+			return nil
+		})
+		return err
+	}
+
+	err := tx.db.client.Watch(ctx, apply, keys...)
+	if errors.Is(err, errConflict) || errors.Is(err, redis.TxFailedErr) {
+		return fmt.Errorf("%w: %v", dbase.ErrTxFailed, err)
+	}
+	return err
+}
@@ -0,0 +1,332 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Factory is a function that creates a [Database] from a [Config].
+type Factory func(cfg *Config) (Database, error)
+
+// DriverOption declares a property of a driver when it is registered.
+type DriverOption func(*driver)
+
+// Requires declares the [Config] fields, by Go name such as "Path" or
+// "DSN", that the driver cannot open without. [Config.Validate] reports
+// them when empty.
+func Requires(fields ...string) DriverOption {
+	return func(d *driver) {
+		d.required = append(d.required, fields...)
+	}
+}
+
+// WithDescription sets the one-line description listed by [Lookup] and
+// the dbase tool.
+func WithDescription(text string) DriverOption {
+	return func(d *driver) {
+		d.description = text
+	}
+}
+
+// WithAliases declares other names the driver can be opened by, such as
+// "pg" for "postgres". [ParseURL] accepts them as URL schemes too.
+func WithAliases(names ...string) DriverOption {
+	return func(d *driver) {
+		d.aliases = append(d.aliases, names...)
+	}
+}
+
+// driver is a registered driver and its declared configuration schema.
+type driver struct {
+	factory     Factory
+	description string
+	aliases     []string
+	required    []string
+	options     reflect.Type // struct type of the options; nil if undeclared
+
+	capabilities *Capabilities // nil if undeclared
+}
+
+// DriverInfo describes a registered driver, as returned by [Lookup].
+type DriverInfo struct {
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description,omitempty"`
+
+	// Required lists the config keys the driver needs, such as "dsn".
+	Required []string `json:"required,omitempty"`
+
+	// Options lists the keys of the driver's options, sorted.
+	Options []string `json:"options,omitempty"`
+
+	// Capabilities is nil if the driver declares none.
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+}
+
+// Registry holds database drivers by name. The package-level [Register],
+// [Open] and related functions use a default registry, which the driver
+// packages add themselves to; [NewRegistry] creates an independent one.
+type Registry struct {
+	mu      sync.RWMutex
+	drivers map[string]*driver
+	aliases map[string]string // alias to driver name
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{drivers: make(map[string]*driver), aliases: make(map[string]string)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// Register makes a database driver available by the provided name.
+// This is typically called from the driver package's init() function.
+// It panics if the name or an alias is already taken or if an option names
+// an unknown [Config] field.
+func Register(name string, factory Factory, opts ...DriverOption) {
+	defaultRegistry.Register(name, factory, opts...)
+}
+
+// Unregister removes the driver name and its aliases from the default
+// registry; see [Registry.Unregister].
+func Unregister(name string) bool {
+	return defaultRegistry.Unregister(name)
+}
+
+// Drivers returns the names of the registered drivers, sorted.
+func Drivers() []string {
+	return defaultRegistry.Drivers()
+}
+
+// Lookup describes the registered driver with the given name or alias.
+func Lookup(name string) (DriverInfo, bool) {
+	return defaultRegistry.Lookup(name)
+}
+
+// Register adds a driver to r; see the package-level [Register].
+func (r *Registry) Register(name string, factory Factory, opts ...DriverOption) {
+	d := &driver{factory: factory}
+	for _, opt := range opts {
+		opt(d)
+	}
+	for _, field := range d.required {
+		if _, ok := reflect.TypeFor[Config]().FieldByName(field); !ok {
+			panic(fmt.Sprintf("dbase: driver %q requires unknown config field %q", name, field))
+		}
+	}
+	if d.options != nil && d.options.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("dbase: driver %q options must be a struct, not %s", name, d.options))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, n := range append([]string{name}, d.aliases...) {
+		if _, exists := r.drivers[n]; exists {
+			panic(fmt.Sprintf("dbase: driver %q already registered", n))
+		}
+		if target, exists := r.aliases[n]; exists {
+			panic(fmt.Sprintf("dbase: driver name %q already registered as an alias of %q", n, target))
+		}
+	}
+	r.drivers[name] = d
+	for _, alias := range d.aliases {
+		r.aliases[alias] = name
+	}
+}
+
+// Unregister removes the driver name and its aliases from r and reports
+// whether it was registered. Databases already opened are not affected.
+// It is meant for tests; see dbasetest.RegisterDriver.
+func (r *Registry) Unregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.drivers[name]
+	if !ok {
+		return false
+	}
+	delete(r.drivers, name)
+	for _, alias := range d.aliases {
+		delete(r.aliases, alias)
+	}
+	return true
+}
+
+// Drivers returns the names of the drivers in r, sorted.
+func (r *Registry) Drivers() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Sorted(maps.Keys(r.drivers))
+}
+
+// Lookup describes the driver in r with the given name or alias.
+func (r *Registry) Lookup(name string) (DriverInfo, bool) {
+	name, d := r.driver(name)
+	if d == nil {
+		return DriverInfo{}, false
+	}
+	info := DriverInfo{
+		Name:        name,
+		Aliases:     slices.Clone(d.aliases),
+		Description: d.description,
+	}
+	for _, field := range d.required {
+		info.Required = append(info.Required, configKey(field))
+	}
+	if d.options != nil {
+		info.Options = slices.Sorted(maps.Keys(optionFields(d.options)))
+	}
+	if d.capabilities != nil {
+		c := *d.capabilities
+		c.Operators = slices.Clone(c.Operators)
+		info.Capabilities = &c
+	}
+	return info, true
+}
+
+// driver returns the driver registered under name or alias, and its name;
+// d is nil if there is none.
+func (r *Registry) driver(name string) (string, *driver) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if target, ok := r.aliases[name]; ok {
+		name = target
+	}
+	return name, r.drivers[name]
+}
+
+// Validate checks cfg against its driver in r: the driver must exist, the
+// fields it requires must be set, its options must be known and well-typed
+// (see [WithOptions]), and the replica policy and pool settings must be
+// valid. Every problem found is reported in one error wrapping
+// [ErrInvalidConfig].
+func (r *Registry) Validate(cfg *Config) error {
+	var problems []string
+	_, d := r.driver(cfg.Type)
+
+	switch {
+	case cfg.Type == "":
+		problems = append(problems, "type is required")
+	case d == nil:
+		msg := fmt.Sprintf("unknown driver %q", cfg.Type)
+		if s := suggest(cfg.Type, r.Drivers()); s != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", s)
+		} else {
+			msg += " (forgotten import?)"
+		}
+		problems = append(problems, msg)
+	default:
+		v := reflect.ValueOf(cfg).Elem()
+		for _, name := range d.required {
+			if v.FieldByName(name).IsZero() {
+				problems = append(problems, fmt.Sprintf("%s is required by driver %q", configKey(name), cfg.Type))
+			}
+		}
+		if d.options != nil {
+			problems = append(problems, decodeOptions(cfg, reflect.New(d.options).Elem())...)
+		}
+	}
+
+	switch cfg.ReplicaPolicy {
+	case "", ReplicaRandom, ReplicaRoundRobin:
+	default:
+		problems = append(problems, fmt.Sprintf("replica_policy %q is not %q or %q",
+			cfg.ReplicaPolicy, ReplicaRandom, ReplicaRoundRobin))
+	}
+	if p := cfg.Pool; p != nil {
+		if p.MaxOpenConns < 0 || p.MaxIdleConns < 0 || p.ConnMaxLifetime < 0 || p.ConnMaxIdleTime < 0 {
+			problems = append(problems, "pool settings must not be negative")
+		}
+	}
+	if r := cfg.Retry; r != nil {
+		if r.MaxWait < 0 || r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+			problems = append(problems, "retry settings must not be negative")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidConfig, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Open is like [Registry.OpenContext] with a background context.
+func (r *Registry) Open(cfg *Config) (Database, error) {
+	return r.OpenContext(context.Background(), cfg)
+}
+
+// OpenContext checks cfg with [Registry.Validate] and opens it with the
+// driver in r, retrying as configured by [Config.Retry] until ctx is done.
+// An alias in cfg.Type is replaced by the driver name in the config passed
+// to the driver. With [Config.Lazy] it returns at once and the driver is
+// opened on first use instead.
+func (r *Registry) OpenContext(ctx context.Context, cfg *Config) (Database, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("dbase: config must not be nil")
+	}
+	if err := r.Validate(cfg); err != nil {
+		return nil, err
+	}
+
+	name, d := r.driver(cfg.Type)
+	if name != cfg.Type {
+		c := *cfg
+		c.Type = name
+		cfg = &c
+	}
+	if cfg.Lazy {
+		return &lazyDB{
+			cfg:  cfg,
+			caps: d.capabilities,
+			open: func(ctx context.Context) (Database, error) { return connect(ctx, d.factory, cfg) },
+		}, nil
+	}
+	return connect(ctx, d.factory, cfg)
+}
+
+// configKey returns the file key of the Config field name, e.g. "dsn".
+func configKey(field string) string {
+	f, _ := reflect.TypeFor[Config]().FieldByName(field)
+	key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return key
+}
+
+// suggest returns the name closest to s if it is within two edits.
+func suggest(s string, names []string) string {
+	best, bestDist := "", 3
+	for _, name := range names {
+		if d := editDistance(s, name); d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
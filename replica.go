@@ -0,0 +1,27 @@
+package dbase
+
+import "context"
+
+// Replica selection policies for [Config.ReplicaPolicy].
+const (
+	// ReplicaRandom picks a random replica for every read. It is the default.
+	ReplicaRandom = "random"
+
+	// ReplicaRoundRobin cycles through the replicas in order.
+	ReplicaRoundRobin = "round_robin"
+)
+
+type primaryKey struct{}
+
+// UsePrimary returns a context that routes reads made with it to the
+// primary instead of a replica. Use it to read your own writes when
+// replication lag matters. Drivers without replicas ignore it.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryOnly reports whether ctx was returned by [UsePrimary].
+func PrimaryOnly(ctx context.Context) bool {
+	v, _ := ctx.Value(primaryKey{}).(bool)
+	return v
+}
@@ -0,0 +1,30 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+)
+
+// Sequencer is implemented by drivers whose ID sequences do not advance
+// when records are written with explicit IDs, as [Copy] does. Without it
+// the next Create of such a driver would reuse a copied ID.
+type Sequencer interface {
+	// SyncSequence moves the ID sequence of model past the highest stored
+	// ID. It never moves a sequence back.
+	SyncSequence(ctx context.Context, model any) error
+}
+
+// SyncSequence moves the ID sequence of model in db past the highest stored
+// ID. It returns [ErrNotSupported] if the driver does not implement
+// [Sequencer], which means its sequences keep up on their own.
+func SyncSequence(ctx context.Context, db Database, model any) error {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return err
+	}
+	s, ok := db.(Sequencer)
+	if !ok {
+		return fmt.Errorf("%w: %s has no sequences to sync", ErrNotSupported, db.Driver())
+	}
+	return s.SyncSequence(ctx, model)
+}
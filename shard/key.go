@@ -0,0 +1,111 @@
+package shard
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// Op describes the operation being routed.
+type Op struct {
+	// Model is the model passed to the operation, or the results slice
+	// pointer for Find.
+	Model any
+
+	// ID is the primary key passed to Get and Delete; nil otherwise.
+	ID any
+
+	// Query is the query passed to Find, FindOne, Count and Exists.
+	Query *dbase.Query
+}
+
+// KeyFunc returns the shard key of an operation and whether one could be
+// derived. Operations without a key fan out to every shard when they can
+// (Get, Delete and queries) and fail with [ErrNoKey] otherwise.
+type KeyFunc func(ctx context.Context, op Op) (any, bool)
+
+type keyCtx struct{}
+
+// WithKey returns a context that routes every operation made with it to
+// the shard of key, overriding the [KeyFunc]. It is the way to pick the
+// shard of a [DB.Transaction] or of a Create whose key is assigned by the
+// database.
+func WithKey(ctx context.Context, key any) context.Context {
+	return context.WithValue(ctx, keyCtx{}, key)
+}
+
+// ByID shards records by primary key. IDs must be assigned by the
+// application, since the shard of a zero ID is unknown.
+func ByID() KeyFunc {
+	return func(_ context.Context, op Op) (any, bool) {
+		if op.ID != nil {
+			return op.ID, true
+		}
+		m, err := schema.Of(op.Model)
+		if err != nil {
+			return nil, false
+		}
+		return fieldKey(m, m.ID, op)
+	}
+}
+
+// ByField shards records by the value of the named field. Get and Delete
+// by primary key cannot see the field, since the model passed to them may
+// hold another record, and look the record up on every shard.
+func ByField(name string) KeyFunc {
+	return func(_ context.Context, op Op) (any, bool) {
+		if op.ID != nil {
+			return nil, false
+		}
+		m, err := schema.Of(op.Model)
+		if err != nil {
+			return nil, false
+		}
+		f := m.Field(name)
+		if f == nil {
+			return nil, false
+		}
+		return fieldKey(m, f, op)
+	}
+}
+
+// ByTenant shards records by the tenant stored in the context with
+// [dbase.WithTenant], so that each tenant lives on exactly one shard.
+func ByTenant() KeyFunc {
+	return func(ctx context.Context, _ Op) (any, bool) {
+		return dbase.TenantFromContext(ctx)
+	}
+}
+
+// fieldKey reads field f from the model of op, or from a query that pins
+// f to a single value with an AND-only equality condition.
+func fieldKey(m *schema.Model, f *schema.Field, op Op) (any, bool) {
+	if op.Query != nil {
+		return queryKey(m, f, op.Query)
+	}
+	v, err := schema.Struct(op.Model)
+	if err != nil {
+		return nil, false
+	}
+	fv := f.Value(v)
+	if !fv.IsValid() || fv.IsZero() {
+		return nil, false
+	}
+	return reflect.Indirect(fv).Interface(), true
+}
+
+func queryKey(m *schema.Model, f *schema.Field, q *dbase.Query) (any, bool) {
+	var key any
+	found := false
+	for _, c := range q.Conditions {
+		if c.Or {
+			return nil, false
+		}
+		if c.Operator == dbase.OpEqual && m.Field(c.Field) == f {
+			key, found = c.Value, true
+		}
+	}
+	return key, found
+}
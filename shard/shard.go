@@ -0,0 +1,433 @@
+// Package shard provides a [dbase.Database] that splits data across several
+// underlying databases.
+//
+// Every operation is routed by a shard key derived with a [KeyFunc] (see
+// [ByID], [ByField] and [ByTenant]) or set explicitly with [WithKey]. The
+// key is hashed to pick one of the shards, so the list of shards passed to
+// [New] must keep its order and length for data to stay reachable.
+//
+// Queries without a key fan out to all shards: Find merges the results in
+// OrderBy order and applies Limit and Offset globally, Count adds up the
+// per-shard counts. Transactions are confined to a single shard.
+//
+//	db, err := shard.New(shard.ByTenant(), eu, us)
+//	err = db.Create(dbase.WithTenant(ctx, "acme"), &invoice)
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/eval"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+var (
+	// ErrNoKey is returned when a write cannot be routed because no shard
+	// key could be derived for it.
+	ErrNoKey = errors.New("dbase/shard: no shard key")
+
+	// ErrCrossShard is returned by [DB.Transaction] when the transaction
+	// has no shard key, and by operations inside a transaction that belong
+	// to a different shard.
+	ErrCrossShard = fmt.Errorf("%w: cross-shard transaction", dbase.ErrNotSupported)
+)
+
+// DB implements [dbase.Database] on top of a fixed list of shards.
+// Transaction-scoped instances carry the shard's transaction.
+type DB struct {
+	shards []dbase.Database
+	key    KeyFunc
+	tx     dbase.Database // non-nil inside a transaction
+	pinned int            // shard of tx
+}
+
+// New returns a DB routing operations across shards with key.
+func New(key KeyFunc, shards ...dbase.Database) (*DB, error) {
+	if key == nil {
+		return nil, errors.New("dbase/shard: key function must not be nil")
+	}
+	if len(shards) == 0 {
+		return nil, errors.New("dbase/shard: no shards")
+	}
+	return &DB{shards: shards, key: key}, nil
+}
+
+// Shards returns the underlying databases in routing order.
+func (d *DB) Shards() []dbase.Database { return d.shards }
+
+// Driver implements [dbase.Database].
+func (d *DB) Driver() string { return "shard" }
+
+// Capabilities implements [dbase.Capable] with what every shard supports.
+// Nested transactions join the enclosing one.
+func (d *DB) Capabilities() dbase.Capabilities {
+	c := dbase.CapabilitiesOf(d.shards[0])
+	c.Operators = slices.Clone(c.Operators)
+	for _, db := range d.shards[1:] {
+		s := dbase.CapabilitiesOf(db)
+		c.Operators = slices.DeleteFunc(c.Operators, func(op dbase.Operator) bool { return !s.Supports(op) })
+		c.Or = c.Or && s.Or
+		c.PartialUpdate = c.PartialUpdate && s.PartialUpdate
+		c.AtomicTransactions = c.AtomicTransactions && s.AtomicTransactions
+		c.Aggregation = c.Aggregation && s.Aggregation
+	}
+	c.NestedTransactions = dbase.NestedJoin
+	c.Replicas = false
+	return c
+}
+
+// index maps a shard key to a shard.
+func (d *DB) index(key any) int {
+	h := fnv.New64a()
+	_, _ = fmt.Fprint(h, key)
+	return int(h.Sum64() % uint64(len(d.shards)))
+}
+
+// route returns the database that op must run on, or nil when op has no
+// shard key and must fan out.
+func (d *DB) route(ctx context.Context, op Op) (dbase.Database, error) {
+	key, ok := ctx.Value(keyCtx{}), true
+	if key == nil {
+		key, ok = d.key(ctx, op)
+	}
+	if d.tx != nil {
+		if ok && d.index(key) != d.pinned {
+			return nil, ErrCrossShard
+		}
+		return d.tx, nil
+	}
+	if !ok {
+		return nil, nil
+	}
+	return d.shards[d.index(key)], nil
+}
+
+// write routes a write, which needs a shard key.
+func (d *DB) write(ctx context.Context, model any) (dbase.Database, error) {
+	db, err := d.route(ctx, Op{Model: model})
+	if err == nil && db == nil {
+		err = fmt.Errorf("%w for %T", ErrNoKey, model)
+	}
+	return db, err
+}
+
+func (d *DB) Create(ctx context.Context, model any) error {
+	db, err := d.write(ctx, model)
+	if err != nil {
+		return err
+	}
+	return db.Create(ctx, model)
+}
+
+func (d *DB) Get(ctx context.Context, model any, id any) error {
+	db, err := d.route(ctx, Op{Model: model, ID: id})
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		return db.Get(ctx, model, id)
+	}
+	_, err = d.locate(ctx, model, id)
+	return err
+}
+
+// locate fetches the record id into model from the first shard that has
+// it and returns that shard.
+func (d *DB) locate(ctx context.Context, model any, id any) (dbase.Database, error) {
+	for _, db := range d.shards {
+		err := db.Get(ctx, model, id)
+		if err == nil {
+			return db, nil
+		}
+		if !errors.Is(err, dbase.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, dbase.ErrNotFound
+}
+
+func (d *DB) Update(ctx context.Context, model any) error {
+	db, err := d.write(ctx, model)
+	if err != nil {
+		return err
+	}
+	return db.Update(ctx, model)
+}
+
+func (d *DB) UpdateFields(ctx context.Context, model any, fields ...string) error {
+	db, err := d.write(ctx, model)
+	if err != nil {
+		return err
+	}
+	return db.UpdateFields(ctx, model, fields...)
+}
+
+func (d *DB) Save(ctx context.Context, model any) error {
+	db, err := d.write(ctx, model)
+	if err != nil {
+		return err
+	}
+	return db.Save(ctx, model)
+}
+
+func (d *DB) Delete(ctx context.Context, model any, id any) error {
+	db, err := d.route(ctx, Op{Model: model, ID: id})
+	if err != nil {
+		return err
+	}
+	if db == nil {
+		// Find the owning shard first so that delete hooks run once.
+		m, err := schema.Of(model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+		}
+		if db, err = d.locate(ctx, m.New().Interface(), id); err != nil {
+			return err
+		}
+	}
+	return db.Delete(ctx, model, id)
+}
+
+func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
+	db, err := d.route(ctx, Op{Model: results, Query: query})
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		return db.Find(ctx, results, query)
+	}
+	return d.fanOutFind(ctx, results, query)
+}
+
+// fanOutFind queries every shard for enough rows to fill the requested
+// page, merges them in query order and cuts the page out of the result.
+func (d *DB) fanOutFind(ctx context.Context, results any, query *dbase.Query) error {
+	out, isPtr, err := schema.Slice(results)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	m, err := schema.Of(results)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+
+	var page dbase.Query
+	if query != nil {
+		page = *query
+	}
+	limit, offset := page.Limit, page.Offset
+	page.Offset = 0
+	if limit > 0 {
+		page.Limit = limit + offset
+	}
+
+	var rows []reflect.Value
+	for _, db := range d.shards {
+		part := reflect.New(out.Type())
+		if err := db.Find(ctx, part.Interface(), &page); err != nil {
+			return err
+		}
+		for i := range part.Elem().Len() {
+			rows = append(rows, part.Elem().Index(i))
+		}
+	}
+
+	eval.Sort(rows, page.OrderBy, func(v reflect.Value) eval.Getter {
+		if isPtr {
+			v = v.Elem()
+		}
+		return eval.StructGetter(m, v)
+	})
+	if page.Unique {
+		// Each shard dropped its own duplicates; drop those across shards.
+		fields, err := eval.Fields(m, page.Fields)
+		if err != nil {
+			return err
+		}
+		rows = eval.Distinct(rows, m, fields)
+	}
+	rows = eval.Page(rows, limit, offset)
+
+	merged := reflect.MakeSlice(out.Type(), 0, len(rows))
+	merged = reflect.Append(merged, rows...)
+	out.Set(merged)
+	return nil
+}
+
+func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error {
+	db, err := d.route(ctx, Op{Model: result, Query: query})
+	if err != nil {
+		return err
+	}
+	if db != nil {
+		return db.FindOne(ctx, result, query)
+	}
+
+	v, err := schema.Struct(result)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	var first dbase.Query
+	if query != nil {
+		first = *query
+	}
+	first.Limit = 1
+	rows := reflect.New(reflect.SliceOf(v.Type()))
+	if err := d.fanOutFind(ctx, rows.Interface(), &first); err != nil {
+		return err
+	}
+	if rows.Elem().Len() == 0 {
+		return dbase.ErrNotFound
+	}
+	v.Set(rows.Elem().Index(0))
+	return nil
+}
+
+func (d *DB) Count(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	db, err := d.route(ctx, Op{Model: model, Query: query})
+	if err != nil {
+		return 0, err
+	}
+	if db != nil {
+		return db.Count(ctx, model, query)
+	}
+	if query != nil && query.Unique {
+		return d.countDistinct(ctx, model, query)
+	}
+	var total int64
+	for _, db := range d.shards {
+		n, err := db.Count(ctx, model, query)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// countDistinct counts the distinct records across shards, which a sum of
+// the per-shard counts would overstate.
+func (d *DB) countDistinct(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	m, err := schema.Of(model)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	results := reflect.New(reflect.SliceOf(reflect.PointerTo(m.Type)))
+	q := *query
+	q.Limit, q.Offset = 0, 0
+	if err := d.fanOutFind(ctx, results.Interface(), &q); err != nil {
+		return 0, err
+	}
+	return int64(results.Elem().Len()), nil
+}
+
+func (d *DB) Exists(ctx context.Context, model any, query *dbase.Query) (bool, error) {
+	db, err := d.route(ctx, Op{Model: model, Query: query})
+	if err != nil {
+		return false, err
+	}
+	if db != nil {
+		return db.Exists(ctx, model, query)
+	}
+	for _, db := range d.shards {
+		ok, err := db.Exists(ctx, model, query)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Transaction runs fn in a transaction on a single shard, chosen by the
+// key of ctx (see [WithKey] and [ByTenant]). It fails with [ErrCrossShard]
+// when ctx has no key. Inside fn, operations whose key maps to another
+// shard fail with [ErrCrossShard] as well; queries without a key only see
+// the transaction's shard.
+func (d *DB) Transaction(ctx context.Context, fn func(tx dbase.Database) error) error {
+	if d.tx != nil {
+		return fn(d)
+	}
+	key, ok := ctx.Value(keyCtx{}), true
+	if key == nil {
+		key, ok = d.key(ctx, Op{})
+	}
+	if !ok {
+		return ErrCrossShard
+	}
+	i := d.index(key)
+	return d.shards[i].Transaction(ctx, func(tx dbase.Database) error {
+		return fn(&DB{shards: d.shards, key: d.key, tx: tx, pinned: i})
+	})
+}
+
+// Migrate runs the migration on every shard.
+func (d *DB) Migrate(ctx context.Context, models ...any) error {
+	if d.tx != nil {
+		return d.tx.Migrate(ctx, models...)
+	}
+	for i, db := range d.shards {
+		if err := db.Migrate(ctx, models...); err != nil {
+			return fmt.Errorf("dbase/shard: migrate shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Close closes every shard.
+func (d *DB) Close() error {
+	var errs []error
+	for _, db := range d.shards {
+		errs = append(errs, db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Shutdown implements [dbase.Shutdowner] by shutting down every shard
+// concurrently with ctx.
+func (d *DB) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(d.shards))
+	var wg sync.WaitGroup
+	for i, db := range d.shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = dbase.Shutdown(ctx, db)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Ping pings every shard.
+func (d *DB) Ping(ctx context.Context) error {
+	for i, db := range d.shards {
+		if err := db.Ping(ctx); err != nil {
+			return fmt.Errorf("dbase/shard: ping shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SyncSequence implements [dbase.Sequencer] by syncing the sequence of
+// model on every shard.
+func (d *DB) SyncSequence(ctx context.Context, model any) error {
+	for i, db := range d.shards {
+		if err := dbase.SyncSequence(ctx, db, model); err != nil {
+			return fmt.Errorf("dbase/shard: sync sequence on shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+var (
+	_ dbase.Database   = (*DB)(nil)
+	_ dbase.Shutdowner = (*DB)(nil)
+	_ dbase.Sequencer  = (*DB)(nil)
+)
@@ -0,0 +1,134 @@
+package shard_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/dbasetest"
+	"github.com/nuln/dbase/jsonfile"
+	"github.com/nuln/dbase/shard"
+)
+
+func openShards(t *testing.T, n int) []dbase.Database {
+	t.Helper()
+	shards := make([]dbase.Database, n)
+	for i := range shards {
+		db, err := jsonfile.New(t.TempDir(), jsonfile.Options{})
+		require.NoError(t, err)
+		shards[i] = db
+	}
+	return shards
+}
+
+func TestShard(t *testing.T) {
+	// A constant key keeps the suite on one shard, which is what its
+	// sequence-assigned IDs and unkeyed transactions need.
+	constant := func(context.Context, shard.Op) (any, bool) { return "all", true }
+	db, err := shard.New(constant, openShards(t, 2)...)
+	if err != nil {
+		t.Fatalf("failed to open shards: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	dbasetest.Suite(t, db)
+}
+
+type Order struct {
+	ID       string `storm:"id"`
+	Customer string `storm:"index"`
+	Total    int
+}
+
+func TestShardFanOut(t *testing.T) {
+	ctx := context.Background()
+	shards := openShards(t, 3)
+	db, err := shard.New(shard.ByID(), shards...)
+	require.NoError(t, err)
+	require.NoError(t, db.Migrate(ctx, &Order{}))
+
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	for i, id := range ids {
+		require.NoError(t, db.Create(ctx, &Order{ID: id, Customer: "c" + id, Total: 10 * (i + 1)}))
+	}
+	used := 0
+	for _, s := range shards {
+		n, err := s.Count(ctx, &Order{}, nil)
+		require.NoError(t, err)
+		if n > 0 {
+			used++
+		}
+	}
+	assert.Greater(t, used, 1, "records should be spread across shards")
+
+	var page []Order
+	require.NoError(t, db.Find(ctx, &page, dbase.NewQuery().OrderByDesc("Total").SetLimit(3).SetOffset(2)))
+	require.Len(t, page, 3)
+	assert.Equal(t, []int{60, 50, 40}, []int{page[0].Total, page[1].Total, page[2].Total})
+
+	count, err := db.Count(ctx, &Order{}, dbase.Gt("Total", 35))
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, count)
+
+	var one Order
+	require.NoError(t, db.FindOne(ctx, &one, dbase.NewQuery().OrderByAsc("Total")))
+	assert.Equal(t, "a", one.ID)
+
+	var got Order
+	require.NoError(t, db.Get(ctx, &got, "e"))
+	assert.Equal(t, 50, got.Total)
+
+	byCustomer, err := shard.New(shard.ByField("Customer"), shards...)
+	require.NoError(t, err)
+	assert.ErrorIs(t, byCustomer.Update(ctx, &Order{ID: "e", Total: 1}), shard.ErrNoKey)
+	require.NoError(t, byCustomer.Get(ctx, &got, "e"), "Get by ID falls back to a lookup on every shard")
+	assert.Equal(t, "e", got.ID)
+	for _, id := range ids {
+		stale := Order{ID: id, Customer: "c" + id}
+		require.NoError(t, byCustomer.Get(ctx, &stale, "e"), "the field left in the model must not route Get")
+		assert.Equal(t, "e", stale.ID)
+	}
+
+	require.NoError(t, db.Delete(ctx, &Order{}, "e"))
+	assert.ErrorIs(t, db.Get(ctx, &got, "e"), dbase.ErrNotFound)
+
+	assert.ErrorIs(t, byCustomer.Delete(ctx, Order{}, "d"), dbase.ErrInvalidModel)
+	assert.ErrorIs(t, byCustomer.Delete(ctx, nil, "d"), dbase.ErrInvalidModel)
+	require.NoError(t, byCustomer.Delete(ctx, &Order{}, "d"))
+	assert.ErrorIs(t, db.Get(ctx, &got, "d"), dbase.ErrNotFound)
+}
+
+func TestShardTransactions(t *testing.T) {
+	ctx := context.Background()
+	db, err := shard.New(shard.ByTenant(), openShards(t, 4)...)
+	require.NoError(t, err)
+
+	err = db.Transaction(ctx, func(dbase.Database) error { return nil })
+	assert.ErrorIs(t, err, shard.ErrCrossShard)
+
+	acme := dbase.WithTenant(ctx, "acme")
+	require.NoError(t, db.Transaction(acme, func(tx dbase.Database) error {
+		return tx.Create(acme, &Order{ID: "1", Total: 5})
+	}))
+	exists, err := db.Exists(acme, &Order{}, dbase.Eq("ID", "1"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Find a tenant that lives on another shard.
+	var other context.Context
+	for _, name := range []string{"globex", "initech", "umbrella", "hooli", "vandelay"} {
+		c := dbase.WithTenant(ctx, name)
+		if ok, _ := db.Exists(c, &Order{}, dbase.Eq("ID", "1")); !ok {
+			other = c
+			break
+		}
+	}
+	require.NotNil(t, other)
+	err = db.Transaction(acme, func(tx dbase.Database) error {
+		return tx.Create(other, &Order{ID: "2"})
+	})
+	assert.ErrorIs(t, err, shard.ErrCrossShard)
+}
@@ -0,0 +1,21 @@
+package dbase
+
+import "context"
+
+// Shutdowner is implemented by drivers that close gracefully. Shutdown
+// makes new operations fail with [ErrClosed], waits until the operations
+// and transactions in flight have finished or ctx is done, and then
+// closes the database. It returns ctx's error if it stopped waiting.
+// Close is Shutdown without a deadline.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Shutdown closes db gracefully if the driver implements [Shutdowner],
+// and with Close otherwise.
+func Shutdown(ctx context.Context, db Database) error {
+	if s, ok := db.(Shutdowner); ok {
+		return s.Shutdown(ctx)
+	}
+	return db.Close()
+}
@@ -0,0 +1,94 @@
+package dbase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// Stats are runtime statistics of a database, for dashboards and the
+// dbase tool. Fields a driver cannot report are left zero.
+type Stats struct {
+	Driver string `json:"driver"`
+
+	// Pool holds the connection pool statistics of client-server
+	// databases.
+	Pool *PoolStats `json:"pool,omitempty"`
+
+	// Bolt holds the page and transaction statistics of bolt files.
+	Bolt *BoltStats `json:"bolt,omitempty"`
+
+	// Size is the storage used on disk or by the server, in bytes.
+	Size int64 `json:"size,omitempty"`
+
+	// Records maps model names to their record counts; see [StatsOf].
+	Records map[string]int64 `json:"records,omitempty"`
+}
+
+// PoolStats are connection pool statistics, as in sql.DBStats. SQL
+// drivers add up the pools of the primary and its replicas.
+type PoolStats struct {
+	MaxOpen int `json:"max_open"` // 0 means unlimited
+	Open    int `json:"open"`
+	InUse   int `json:"in_use"`
+	Idle    int `json:"idle"`
+
+	// WaitCount and WaitDuration count the waits for a free connection.
+	WaitCount    int64         `json:"wait_count"`
+	WaitDuration time.Duration `json:"wait_duration"`
+
+	// Timeouts counts the waits that timed out, where the driver tracks
+	// them.
+	Timeouts int64 `json:"timeouts,omitempty"`
+}
+
+// BoltStats are the statistics of a bolt file, as in bbolt.Stats.
+type BoltStats struct {
+	FreePages    int `json:"free_pages"`
+	PendingPages int `json:"pending_pages"`
+	FreeAlloc    int `json:"free_alloc"` // bytes in free pages
+	OpenTx       int `json:"open_tx"`
+	Tx           int `json:"tx"`          // read transactions started
+	PageWrites   int `json:"page_writes"` // by committed transactions
+}
+
+// StatsReporter is implemented by drivers that report runtime statistics.
+type StatsReporter interface {
+	// Stats returns the statistics of the database, without Records.
+	Stats(ctx context.Context) (*Stats, error)
+}
+
+// StatsOf returns the statistics of db, with the record count of each of
+// models in Records. It returns [ErrNotSupported] if the driver does not
+// implement [StatsReporter].
+func StatsOf(ctx context.Context, db Database, models ...any) (*Stats, error) {
+	db, err := connected(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	r, ok := db.(StatsReporter)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s cannot report statistics", ErrNotSupported, db.Driver())
+	}
+	stats, err := r.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) > 0 {
+		stats.Records = make(map[string]int64, len(models))
+	}
+	for _, model := range models {
+		m, err := schema.Of(model)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidModel, err)
+		}
+		n, err := db.Count(ctx, model, nil)
+		if err != nil {
+			return nil, fmt.Errorf("dbase: count %s: %w", m.Name, err)
+		}
+		stats.Records[m.Name] = n
+	}
+	return stats, nil
+}
@@ -0,0 +1,18 @@
+package dbase
+
+import "context"
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying tenantID. Wrappers that partition
+// data by tenant read it with [TenantFromContext].
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant stored in ctx by [WithTenant].
+// It reports false when ctx carries no tenant or an empty one.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	return id, id != ""
+}
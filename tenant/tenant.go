@@ -0,0 +1,406 @@
+// Package tenant scopes a [dbase.Database] to the tenant stored in the
+// context with [dbase.WithTenant].
+//
+// In the default shared mode ([New]) all tenants live in the same tables
+// or buckets and every model with a tenant field (TenantID by default) is
+// scoped automatically: Create and Save stamp the field, queries get a
+// tenant condition, and Get, Update and Delete only see records of the
+// current tenant. Models without the field pass through unchanged.
+//
+//	db := tenant.New(base, tenant.Options{})
+//	ctx = dbase.WithTenant(ctx, "acme")
+//	db.Find(ctx, &invoices, nil) // only acme's invoices
+//
+// In isolated mode ([Isolated]) each tenant gets its own database view,
+// such as a bolt bucket per tenant (see bolt.DB.From).
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/internal/schema"
+)
+
+// ErrNoTenant is returned when a tenant-scoped model is accessed with a
+// context that carries no tenant.
+var ErrNoTenant = errors.New("dbase/tenant: no tenant in context")
+
+// Options configures shared-mode scoping.
+type Options struct {
+	// Field is the Go name of the tenant field. It must be a string field.
+	// Defaults to "TenantID".
+	Field string
+}
+
+// DB implements [dbase.Database] by scoping an underlying database to the
+// tenant of each call's context.
+type DB struct {
+	db    dbase.Database
+	field string
+
+	// open returns the view of a tenant in isolated mode; nil in shared mode.
+	open func(tenantID string) (dbase.Database, error)
+}
+
+// New returns a DB that keeps all tenants in db and tells their records
+// apart by the tenant field.
+func New(db dbase.Database, opts Options) *DB {
+	if opts.Field == "" {
+		opts.Field = "TenantID"
+	}
+	return &DB{db: db, field: opts.Field}
+}
+
+// Isolated returns a DB that runs every operation on the view of the
+// context's tenant returned by open. Views are not closed by the DB; open is
+// called for each operation and should be cheap. root is used for Ping and
+// Close.
+//
+//	tenant.Isolated(boltDB, func(id string) (dbase.Database, error) {
+//	    return boltDB.From("tenants", id), nil
+//	})
+func Isolated(root dbase.Database, open func(tenantID string) (dbase.Database, error)) *DB {
+	return &DB{db: root, open: open}
+}
+
+// Driver implements [dbase.Database].
+func (d *DB) Driver() string { return d.db.Driver() }
+
+// Capabilities implements [dbase.Capable] with those of the underlying
+// database.
+func (d *DB) Capabilities() dbase.Capabilities { return dbase.CapabilitiesOf(d.db) }
+
+// scope holds the tenant field of a model and the value of the current
+// tenant converted to its type.
+type scope struct {
+	model *schema.Model
+	field *schema.Field
+	value reflect.Value
+}
+
+// scopeOf returns the scope of model for ctx, or nil when the model is not
+// tenant-scoped. The database to use is returned as well; in isolated mode
+// it is the tenant's view and scope is always nil.
+func (d *DB) scopeOf(ctx context.Context, model any) (dbase.Database, *scope, error) {
+	id, ok := dbase.TenantFromContext(ctx)
+	if d.open != nil {
+		if !ok {
+			return nil, nil, ErrNoTenant
+		}
+		db, err := d.open(id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dbase/tenant: open %s: %w", id, err)
+		}
+		return db, nil, nil
+	}
+
+	m, err := schema.Of(model)
+	if err != nil {
+		return d.db, nil, nil // let the driver report invalid models
+	}
+	f := m.Field(d.field)
+	if f == nil {
+		return d.db, nil, nil
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s is tenant-scoped", ErrNoTenant, m.Name)
+	}
+	value, err := schema.Convert(id, f.Type)
+	if err != nil || f.Type.Kind() != reflect.String {
+		return nil, nil, fmt.Errorf("%w: %s.%s must be a string", dbase.ErrInvalidModel, m.Name, f.Name)
+	}
+	return d.db, &scope{model: m, field: f, value: value}, nil
+}
+
+// stamp sets the tenant field of model.
+func (s *scope) stamp(model any) error {
+	v, err := schema.Struct(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	s.field.Value(v).Set(s.value)
+	return nil
+}
+
+// owns reports whether the struct v belongs to the tenant.
+func (s *scope) owns(v reflect.Value) bool {
+	return s.field.Value(v).String() == s.value.String()
+}
+
+// query returns q with the tenant condition added to every OR group, so
+// that it applies whichever group matches.
+func (s *scope) query(q *dbase.Query) *dbase.Query {
+	cond := dbase.Condition{Field: s.field.Name, Operator: dbase.OpEqual, Value: s.value.Interface()}
+	scoped := &dbase.Query{}
+	if q != nil {
+		*scoped = *q
+	}
+	if len(scoped.Conditions) == 0 {
+		scoped.Conditions = []dbase.Condition{cond}
+		return scoped
+	}
+	scoped.Conditions = make([]dbase.Condition, 0, 2*len(q.Conditions))
+	for i, c := range q.Conditions {
+		scoped.Conditions = append(scoped.Conditions, c)
+		if i == 0 || c.Or {
+			scoped.Conditions = append(scoped.Conditions, cond)
+		}
+	}
+	return scoped
+}
+
+// load reads record id into a fresh value and fails with
+// [dbase.ErrNotFound] unless it belongs to the tenant.
+func (s *scope) load(ctx context.Context, db dbase.Database, id any) (reflect.Value, error) {
+	rec := s.model.New()
+	if err := db.Get(ctx, rec.Interface(), id); err != nil {
+		return reflect.Value{}, err
+	}
+	if !s.owns(rec.Elem()) {
+		return reflect.Value{}, dbase.ErrNotFound
+	}
+	return rec.Elem(), nil
+}
+
+// check fails with [dbase.ErrNotFound] unless the stored version of model
+// belongs to the tenant.
+func (s *scope) check(ctx context.Context, db dbase.Database, model any) error {
+	v, err := schema.Struct(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	_, err = s.load(ctx, db, s.model.ID.Interface(v))
+	return err
+}
+
+// sameID fails with [dbase.ErrInvalidModel] when model carries an ID other
+// than id: ownership is checked for id, so the driver must not delete by
+// the ID in model instead.
+func (s *scope) sameID(model any, id any) error {
+	v, err := schema.Struct(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	have := s.model.ID.Value(v)
+	if have.IsZero() {
+		return nil
+	}
+	want, err := schema.Convert(id, have.Type())
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	if !have.Equal(want) {
+		return fmt.Errorf("%w: %s has ID %v, not %v", dbase.ErrInvalidModel, s.model.Name, have.Interface(), id)
+	}
+	return nil
+}
+
+func (d *DB) Create(ctx context.Context, model any) error {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		if err := s.stamp(model); err != nil {
+			return err
+		}
+	}
+	return db.Create(ctx, model)
+}
+
+func (d *DB) Get(ctx context.Context, model any, id any) error {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return db.Get(ctx, model, id)
+	}
+	v, err := schema.Struct(model)
+	if err != nil {
+		return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+	}
+	rec, err := s.load(ctx, db, id)
+	if err != nil {
+		return err
+	}
+	v.Set(rec)
+	return nil
+}
+
+func (d *DB) Update(ctx context.Context, model any) error {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		if err := s.check(ctx, db, model); err != nil {
+			return err
+		}
+		if err := s.stamp(model); err != nil {
+			return err
+		}
+	}
+	return db.Update(ctx, model)
+}
+
+func (d *DB) UpdateFields(ctx context.Context, model any, fields ...string) error {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		if err := s.check(ctx, db, model); err != nil {
+			return err
+		}
+		if err := s.stamp(model); err != nil {
+			return err
+		}
+	}
+	return db.UpdateFields(ctx, model, fields...)
+}
+
+// Save creates or updates model. An existing record with the same ID that
+// belongs to another tenant is reported as [dbase.ErrAlreadyExists].
+func (d *DB) Save(ctx context.Context, model any) error {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		v, err := schema.Struct(model)
+		if err != nil {
+			return fmt.Errorf("%w: %v", dbase.ErrInvalidModel, err)
+		}
+		if id := s.model.ID.Value(v); !id.IsZero() {
+			existing := s.model.New()
+			err := db.Get(ctx, existing.Interface(), id.Interface())
+			switch {
+			case err == nil && !s.owns(existing.Elem()):
+				return fmt.Errorf("%w: %s %v", dbase.ErrAlreadyExists, s.model.Name, id.Interface())
+			case err != nil && !errors.Is(err, dbase.ErrNotFound):
+				return err
+			}
+		}
+		s.field.Value(v).Set(s.value)
+	}
+	return db.Save(ctx, model)
+}
+
+func (d *DB) Delete(ctx context.Context, model any, id any) error {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		if err := s.sameID(model, id); err != nil {
+			return err
+		}
+		if _, err := s.load(ctx, db, id); err != nil {
+			return err
+		}
+	}
+	return db.Delete(ctx, model, id)
+}
+
+func (d *DB) Find(ctx context.Context, results any, query *dbase.Query) error {
+	db, s, err := d.scopeOf(ctx, results)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		query = s.query(query)
+	}
+	return db.Find(ctx, results, query)
+}
+
+func (d *DB) FindOne(ctx context.Context, result any, query *dbase.Query) error {
+	db, s, err := d.scopeOf(ctx, result)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		query = s.query(query)
+	}
+	return db.FindOne(ctx, result, query)
+}
+
+func (d *DB) Count(ctx context.Context, model any, query *dbase.Query) (int64, error) {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return 0, err
+	}
+	if s != nil {
+		query = s.query(query)
+	}
+	return db.Count(ctx, model, query)
+}
+
+func (d *DB) Exists(ctx context.Context, model any, query *dbase.Query) (bool, error) {
+	db, s, err := d.scopeOf(ctx, model)
+	if err != nil {
+		return false, err
+	}
+	if s != nil {
+		query = s.query(query)
+	}
+	return db.Exists(ctx, model, query)
+}
+
+// Transaction runs fn in a transaction of the underlying database, scoped
+// the same way. In isolated mode fn receives a transaction of the tenant's
+// view.
+func (d *DB) Transaction(ctx context.Context, fn func(tx dbase.Database) error) error {
+	if d.open != nil {
+		db, _, err := d.scopeOf(ctx, nil)
+		if err != nil {
+			return err
+		}
+		return db.Transaction(ctx, fn)
+	}
+	return d.db.Transaction(ctx, func(tx dbase.Database) error {
+		return fn(&DB{db: tx, field: d.field})
+	})
+}
+
+// Migrate migrates the underlying database. In isolated mode it migrates
+// the view of the context's tenant.
+func (d *DB) Migrate(ctx context.Context, models ...any) error {
+	if d.open == nil {
+		return d.db.Migrate(ctx, models...)
+	}
+	db, _, err := d.scopeOf(ctx, nil)
+	if err != nil {
+		return err
+	}
+	return db.Migrate(ctx, models...)
+}
+
+func (d *DB) Close() error { return d.db.Close() }
+
+// Shutdown implements [dbase.Shutdowner] by shutting down the underlying
+// database.
+func (d *DB) Shutdown(ctx context.Context) error { return dbase.Shutdown(ctx, d.db) }
+
+func (d *DB) Ping(ctx context.Context) error { return d.db.Ping(ctx) }
+
+// Stats implements [dbase.StatsReporter] with the statistics of the
+// underlying database, across all tenants.
+func (d *DB) Stats(ctx context.Context) (*dbase.Stats, error) { return dbase.StatsOf(ctx, d.db) }
+
+// SyncSequence implements [dbase.Sequencer] with the underlying database,
+// whose sequences all tenants share.
+func (d *DB) SyncSequence(ctx context.Context, model any) error {
+	return dbase.SyncSequence(ctx, d.db, model)
+}
+
+var (
+	_ dbase.Database      = (*DB)(nil)
+	_ dbase.StatsReporter = (*DB)(nil)
+	_ dbase.Shutdowner    = (*DB)(nil)
+	_ dbase.Sequencer     = (*DB)(nil)
+)
@@ -0,0 +1,127 @@
+package tenant_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/nuln/dbase"
+	"github.com/nuln/dbase/bolt"
+	"github.com/nuln/dbase/dbasetest"
+	"github.com/nuln/dbase/gorm"
+	"github.com/nuln/dbase/tenant"
+)
+
+func TestTenant(t *testing.T) {
+	base, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open bolt: %v", err)
+	}
+	defer func() { _ = base.Close() }()
+
+	// Models without a tenant field pass through unchanged.
+	dbasetest.Suite(t, tenant.New(base, tenant.Options{}))
+}
+
+type Invoice struct {
+	ID       uint   `gorm:"primaryKey" storm:"id,increment"`
+	TenantID string `storm:"index"`
+	Number   string
+	Amount   int
+}
+
+func TestTenantScoping(t *testing.T) {
+	boltDB, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	gormDB, err := gorm.New("sqlite", sqlite.Open(":memory:"))
+	require.NoError(t, err)
+
+	for _, base := range []dbase.Database{boltDB, gormDB} {
+		t.Run(base.Driver(), func(t *testing.T) {
+			defer func() { _ = base.Close() }()
+			testScoping(t, tenant.New(base, tenant.Options{}))
+		})
+	}
+}
+
+func testScoping(t *testing.T, db dbase.Database) {
+	ctx := context.Background()
+	acme, globex := dbase.WithTenant(ctx, "acme"), dbase.WithTenant(ctx, "globex")
+	require.NoError(t, db.Migrate(ctx, &Invoice{}))
+
+	assert.ErrorIs(t, db.Create(ctx, &Invoice{Number: "x"}), tenant.ErrNoTenant)
+	_, err := db.Count(ctx, &Invoice{}, nil)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+
+	a1 := &Invoice{Number: "A-1", Amount: 10}
+	require.NoError(t, db.Create(acme, a1))
+	assert.Equal(t, "acme", a1.TenantID)
+	require.NoError(t, db.Create(acme, &Invoice{Number: "A-2", Amount: 20}))
+	g1 := &Invoice{Number: "G-1", Amount: 10, TenantID: "acme"}
+	require.NoError(t, db.Create(globex, g1))
+	assert.Equal(t, "globex", g1.TenantID, "Create stamps the context's tenant")
+
+	var found []Invoice
+	require.NoError(t, db.Find(acme, &found, nil))
+	assert.Len(t, found, 2)
+	or := dbase.Eq("Amount", 10).Or("Amount", dbase.OpEqual, 20)
+	if dbase.CapabilitiesOf(db).Or {
+		require.NoError(t, db.Find(globex, &found, or))
+		for _, inv := range found {
+			assert.Equal(t, "globex", inv.TenantID, "OR conditions must not escape the tenant")
+		}
+	} else {
+		assert.ErrorIs(t, db.Find(globex, &found, or), dbase.ErrNotSupported)
+	}
+
+	count, err := db.Count(acme, &Invoice{}, dbase.Eq("Amount", 10))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+
+	var got Invoice
+	assert.ErrorIs(t, db.Get(acme, &got, g1.ID), dbase.ErrNotFound)
+	assert.Zero(t, got, "a foreign record must not be copied into the model")
+	require.NoError(t, db.Get(globex, &got, g1.ID))
+
+	assert.ErrorIs(t, db.Update(acme, &Invoice{ID: g1.ID, Number: "stolen"}), dbase.ErrNotFound)
+	assert.ErrorIs(t, db.Save(acme, &Invoice{ID: g1.ID, Number: "stolen"}), dbase.ErrAlreadyExists)
+	assert.ErrorIs(t, db.Delete(acme, &Invoice{}, g1.ID), dbase.ErrNotFound)
+	assert.ErrorIs(t, db.Delete(acme, &Invoice{ID: g1.ID}, a1.ID), dbase.ErrInvalidModel,
+		"the model ID must not differ from the ID whose ownership is checked")
+	require.NoError(t, db.Get(acme, &got, a1.ID))
+	require.NoError(t, db.Get(globex, &got, g1.ID))
+	assert.Equal(t, "G-1", got.Number)
+
+	require.NoError(t, db.Transaction(globex, func(tx dbase.Database) error {
+		return tx.Delete(globex, &Invoice{}, g1.ID)
+	}))
+	exists, err := db.Exists(globex, &Invoice{}, nil)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestTenantIsolatedBuckets(t *testing.T) {
+	ctx := context.Background()
+	base, err := bolt.New(filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	db := tenant.Isolated(base, func(id string) (dbase.Database, error) {
+		return base.From("tenants", id), nil
+	})
+	defer func() { _ = db.Close() }()
+
+	assert.ErrorIs(t, db.Create(ctx, &dbasetest.TestModel{Name: "x"}), tenant.ErrNoTenant)
+
+	acme, globex := dbase.WithTenant(ctx, "acme"), dbase.WithTenant(ctx, "globex")
+	require.NoError(t, db.Create(acme, &dbasetest.TestModel{Name: "Alice", Email: "a@acme"}))
+	require.NoError(t, db.Create(globex, &dbasetest.TestModel{Name: "Bob", Email: "a@acme"}),
+		"unique constraints are per tenant bucket")
+
+	var users []dbasetest.TestModel
+	require.NoError(t, db.Find(globex, &users, nil))
+	require.Len(t, users, 1)
+	assert.Equal(t, "Bob", users[0].Name)
+}